@@ -0,0 +1,278 @@
+/*
+	wrpl-inspector: War Thunder replay inspection software
+	Copyright (C) 2025 flexcoral
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published
+	by the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/AllenDang/cimgui-go/imgui"
+	"github.com/maxsupermanhd/wrpl-inspector/wrpl"
+	"github.com/rs/zerolog/log"
+)
+
+// packetAnnotation is a note attached either to one packet of the replay
+// (Replay hash and Packet index in the packet stream) or to every packet
+// of given type starting with Signature
+type packetAnnotation struct {
+	Replay    string   `json:"replay,omitempty"`
+	Packet    int      `json:"packet,omitempty"`
+	Type      int      `json:"type"`
+	Signature string   `json:"signature,omitempty"`
+	Note      string   `json:"note"`
+	Tags      []string `json:"tags,omitempty"`
+
+	signature []byte
+}
+
+func (a *packetAnnotation) IsSignature() bool {
+	return a.Signature != ""
+}
+
+func (a *packetAnnotation) matches(text string) bool {
+	text = strings.ToLower(text)
+	if strings.Contains(strings.ToLower(a.Note), text) {
+		return true
+	}
+	for _, t := range a.Tags {
+		if strings.Contains(strings.ToLower(t), text) {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *packetAnnotation) String() string {
+	ret := a.Note
+	if len(a.Tags) > 0 {
+		ret = "[" + strings.Join(a.Tags, ", ") + "] " + ret
+	}
+	return ret
+}
+
+type annotationsFile struct {
+	Annotations []*packetAnnotation `json:"annotations"`
+}
+
+// replayAnnotations are annotations of one open replay, stored in a
+// sidecar file next to it
+type replayAnnotations struct {
+	path        string
+	hash        string
+	list        []*packetAnnotation
+	packetIndex map[*wrpl.WRPLRawPacket]int
+	err         error
+
+	// editor state
+	newNote      string
+	newTags      string
+	newSigLength int32
+}
+
+func annotationsSidecarPath(src replaySource) string {
+	switch src.Kind {
+	case replaySourceFile:
+		return src.Path + ".notes.json"
+	case replaySourceFolder:
+		return filepath.Join(src.Path, "notes.json")
+	}
+	return ""
+}
+
+func loadReplayAnnotations(rpl *parsedReplay) *replayAnnotations {
+	ret := &replayAnnotations{
+		path:         annotationsSidecarPath(rpl.Source),
+		hash:         rpl.Replay.Header.Hash(),
+		packetIndex:  rpl.packetIndex,
+		newSigLength: 4,
+	}
+	if ret.path == "" {
+		return ret
+	}
+	buf, err := os.ReadFile(ret.path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			ret.err = err
+		}
+		return ret
+	}
+	f := annotationsFile{}
+	err = json.Unmarshal(buf, &f)
+	if err != nil {
+		ret.err = fmt.Errorf("decoding %s: %w", ret.path, err)
+		return ret
+	}
+	for _, a := range f.Annotations {
+		if a.IsSignature() {
+			a.signature, err = hex.DecodeString(a.Signature)
+			if err != nil {
+				log.Warn().Err(err).Str("path", ret.path).Str("signature", a.Signature).Msg("bad annotation signature")
+			}
+		}
+		if ret.owns(a) {
+			ret.list = append(ret.list, a)
+		}
+	}
+	return ret
+}
+
+// owns is true for annotations that are loaded into the list and replaced by
+// it on save, others (notes of other replays and signatures that can not be
+// decoded) are written back as they are
+func (ra *replayAnnotations) owns(a *packetAnnotation) bool {
+	if a.IsSignature() {
+		_, err := hex.DecodeString(a.Signature)
+		return err == nil
+	}
+	return a.Replay == ra.hash
+}
+
+// save keeps annotations of other replays that share the sidecar file
+func (ra *replayAnnotations) save() error {
+	if ra.path == "" {
+		return errors.New("replay has no location to store annotations")
+	}
+	f := annotationsFile{}
+	buf, err := os.ReadFile(ra.path)
+	if err == nil {
+		err = json.Unmarshal(buf, &f)
+		if err != nil {
+			return fmt.Errorf("decoding %s: %w", ra.path, err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	f.Annotations = slices.DeleteFunc(f.Annotations, ra.owns)
+	f.Annotations = append(f.Annotations, ra.list...)
+	buf, err = json.MarshalIndent(f, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(ra.path, buf, 0644)
+}
+
+// forPacket returns annotations of the packet itself and of signatures it matches
+func (ra *replayAnnotations) forPacket(pk *wrpl.WRPLRawPacket) []*packetAnnotation {
+	if ra == nil {
+		return nil
+	}
+	idx, inStream := ra.packetIndex[pk]
+	ret := []*packetAnnotation{}
+	for _, a := range ra.list {
+		if a.IsSignature() {
+			if int(pk.PacketType) == a.Type && bytes.HasPrefix(pk.PacketPayload, a.signature) {
+				ret = append(ret, a)
+			}
+		} else if inStream && a.Packet == idx {
+			ret = append(ret, a)
+		}
+	}
+	return ret
+}
+
+func (ra *replayAnnotations) summary(pk *wrpl.WRPLRawPacket) string {
+	notes := []string{}
+	for _, a := range ra.forPacket(pk) {
+		notes = append(notes, a.String())
+	}
+	return strings.Join(notes, "; ")
+}
+
+func (ra *replayAnnotations) matches(pk *wrpl.WRPLRawPacket, text string) bool {
+	for _, a := range ra.forPacket(pk) {
+		if a.matches(text) {
+			return true
+		}
+	}
+	return false
+}
+
+func uiShowPacketAnnotations(ra *replayAnnotations, pk *wrpl.WRPLRawPacket) {
+	if ra == nil {
+		return
+	}
+	if ra.err != nil {
+		imgui.TextUnformatted("Annotations error: " + ra.err.Error())
+	}
+	toDelete := -1
+	for i, a := range ra.forPacket(pk) {
+		imgui.PushIDInt(int32(i))
+		if imgui.SmallButton("x") {
+			toDelete = slices.Index(ra.list, a)
+		}
+		imgui.SameLine()
+		if a.IsSignature() {
+			imgui.TextUnformatted("signature " + a.Signature + ": " + a.String())
+		} else {
+			imgui.TextUnformatted("packet: " + a.String())
+		}
+		imgui.PopID()
+	}
+	if toDelete >= 0 {
+		ra.list = slices.Delete(ra.list, toDelete, toDelete+1)
+		ra.err = ra.save()
+	}
+	idx, inStream := ra.packetIndex[pk]
+	imgui.SetNextItemWidth(imgui.ContentRegionAvail().X * 0.4)
+	imgui.InputTextWithHint("##annotation note", "note", &ra.newNote, 0, nil)
+	imgui.SameLine()
+	imgui.SetNextItemWidth(imgui.ContentRegionAvail().X * 0.3)
+	imgui.InputTextWithHint("##annotation tags", "tags, comma separated", &ra.newTags, 0, nil)
+	add := func(a *packetAnnotation) {
+		if !a.IsSignature() {
+			a.Replay = ra.hash
+		}
+		a.Note = ra.newNote
+		for _, t := range strings.Split(ra.newTags, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				a.Tags = append(a.Tags, t)
+			}
+		}
+		ra.list = append(ra.list, a)
+		ra.newNote = ""
+		ra.newTags = ""
+		ra.err = ra.save()
+	}
+	if inStream {
+		imgui.SameLine()
+		if imgui.Button("note packet") {
+			add(&packetAnnotation{Packet: idx, Type: int(pk.PacketType)})
+		}
+	}
+	imgui.SameLine()
+	sig := pk.PacketPayload[:min(len(pk.PacketPayload), int(ra.newSigLength))]
+	// empty signature would be saved as a packet note of no replay
+	imgui.BeginDisabledV(len(sig) == 0)
+	if imgui.Button("note signature") {
+		add(&packetAnnotation{Type: int(pk.PacketType), Signature: hex.EncodeToString(sig), signature: bytes.Clone(sig)})
+	}
+	imgui.EndDisabled()
+	imgui.SameLine()
+	imgui.SetNextItemWidth(90)
+	if imgui.InputInt("bytes##signature length", &ra.newSigLength) {
+		ra.newSigLength = max(1, ra.newSigLength)
+	}
+}
@@ -1,9 +1,12 @@
 package danet
 
 import (
+	"errors"
 	"io"
 )
 
+var ErrCompressedOverflow = errors.New("compressed integer overflows 64 bits")
+
 type BitReader struct {
 	Data      []byte
 	BitOffset int
@@ -25,8 +28,8 @@ func (bs *BitReader) ReadBits(bits int) ([]byte, error) {
 	if bits == 0 {
 		return []byte{}, nil
 	}
-	bitlen := bits2bytes(bs.BitOffset + bits)
-	if bitlen > len(bs.Data) {
+	// also guards against offsets moved out of data and overflowing sums
+	if bits < 0 || bs.BitOffset < 0 || bits > bytes2bits(len(bs.Data))-bs.BitOffset {
 		return nil, io.EOF
 		// return nil, fmt.Errorf("bitstream bitlen %d >= data len %d: %w", bs.BitOffset+bits, len(bs.Data)*8, io.EOF)
 	}
@@ -83,6 +86,27 @@ func (bs *BitReader) Read(dst []byte) (n int, err error) {
 	return
 }
 
+// ReadRemaining reads all remaining whole bytes, trailing partial byte is discarded
+func (bs *BitReader) ReadRemaining() []byte {
+	n := (len(bs.Data)*8 - bs.BitOffset) / 8
+	if n <= 0 {
+		return []byte{}
+	}
+	r, err := bs.ReadBytes(n)
+	if err != nil {
+		return []byte{}
+	}
+	return r
+}
+
+func (bs *BitReader) ReadBit() (bool, error) {
+	r, err := bs.ReadBits(1)
+	if err != nil {
+		return false, err
+	}
+	return r[0] != 0, nil
+}
+
 func (bs *BitReader) ReadLenStr() (string, error) {
 	l, err := bs.ReadByte()
 	if err != nil {
@@ -106,6 +130,9 @@ func (bs *BitReader) ReadCompressed() (uint64, error) {
 		if (a[0] & (1 << 7)) == 0 {
 			break
 		}
+		if count*7 >= 64 {
+			return 0, ErrCompressedOverflow
+		}
 	}
 	return v, nil
 }
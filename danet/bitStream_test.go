@@ -0,0 +1,46 @@
+package danet
+
+import (
+	"testing"
+)
+
+// FuzzBitReader runs sequence of reads encoded in ops over data, reads
+// must fail with an error instead of panicking when data runs out
+func FuzzBitReader(f *testing.F) {
+	f.Add([]byte{0x01, 0x13, 0x25, 0x37}, []byte{0xff, 0x00, 0xaa, 0x55, 0x80, 0x01})
+	f.Add([]byte{0x47, 0x50, 0x60, 0x70}, []byte{0x02, 'h', 'i', 0x81, 0x81, 0x01})
+	f.Add([]byte{0x8f, 0x0f}, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01})
+	f.Fuzz(func(t *testing.T, ops []byte, data []byte) {
+		r := NewBitReader(data)
+		for _, op := range ops {
+			n := int(op & 0x0f)
+			switch op >> 4 {
+			case 0:
+				b, err := r.ReadBits(n)
+				if err == nil && len(b) != bits2bytes(n) {
+					t.Fatalf("read %d bits into %d bytes", n, len(b))
+				}
+			case 1:
+				r.ReadBytes(n)
+			case 2:
+				r.ReadByte()
+			case 3:
+				r.ReadBit()
+			case 4:
+				r.ReadLenStr()
+			case 5:
+				r.ReadCompressed()
+			case 6:
+				r.AlignToByteBoundary()
+			case 7:
+				r.ReadRemaining()
+			case 8:
+				r.IgnoreBits(n)
+			case 9:
+				r.IgnoreBits(-n)
+			default:
+				r.Read(make([]byte, n))
+			}
+		}
+	})
+}
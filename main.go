@@ -20,7 +20,6 @@ package main
 
 import (
 	"bytes"
-	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
@@ -32,7 +31,6 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
-	"reflect"
 	"regexp"
 	"slices"
 	"strconv"
@@ -61,12 +59,16 @@ var (
 
 	humanizeTime = true
 
+	ecsNamesPath = flag.String("ecsnames", "ecsnames.txt", "list of candidate ecs component and type names")
+	ecsNameDict  = wrpl.NewECSNameDict()
+
 	showDemoWindowImgui  bool
 	showDemoWindowImplot bool
 )
 
 type parsedReplay struct {
 	LoadedFrom   string
+	Source       replaySource
 	FileContents []byte
 	Replay       *wrpl.WRPL
 
@@ -76,8 +78,25 @@ type parsedReplay struct {
 
 	ECSMessages []*wrpl.WRPLRawPacket
 
+	ecsWorld       *wrpl.ECSWorld
+	ecsWorldState  *wrpl.ECSWorldState
+	ecsWorldTime   int32
+	ecsWorldFilter string
+
 	uiPacketInspect *uiPacketInspectData
 
+	annotations *replayAnnotations
+
+	// index of packet in Replay.Packets
+	packetIndex      map[*wrpl.WRPLRawPacket]int
+	reflectionTables map[*wrpl.WRPLRawPacket]*reflectionTable
+
+	coverage *coverageView
+
+	chat *chatView
+
+	lineups []*wrpl.Lineup
+
 	PinnedFindings []pinnedFinding
 
 	ParsedPacketsCurrentName int32
@@ -89,6 +108,7 @@ type parsedReplay struct {
 
 type pinnedFinding struct {
 	Packets                  []*wrpl.WRPLRawPacket
+	Subset                   int32
 	ViewingPacketListingMode int32
 	InitialID                int32
 	CurrentID                int32
@@ -99,6 +119,13 @@ func main() {
 	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
 	flag.Parse()
 
+	loadECSNames()
+
+	if flag.Arg(0) == "serve" {
+		runServe(flag.Args()[1:])
+		return
+	}
+
 	var err error
 	log.Info().Msg("making backend")
 	imBackend, err = backend.CreateBackend(glfwbackend.NewGLFWBackend())
@@ -147,32 +174,25 @@ func main() {
 		if st.IsDir() {
 			continue
 		}
-		replayBytes, err := os.ReadFile(loadPath)
-		if err != nil {
-			log.Warn().Err(err).Str("path", loadPath).Msg("failed to open")
-			continue
-		}
-		wrpl, err := wrpl.ReadWRPL(bytes.NewReader(replayBytes), true, true, true)
-		log.Err(err).Str("path", loadPath).Msg("loading replay")
-		if err != nil {
-			continue
+		log.Err(openSingleReplayFile(loadPath)).Str("path", loadPath).Msg("loading replay")
+	}
+	if *workspacePath != "" {
+		err = loadWorkspace(*workspacePath)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Err(err).Str("path", *workspacePath).Msg("loading workspace")
 		}
-		addReplayTab(&parsedReplay{
-			LoadedFrom:   loadPath,
-			FileContents: replayBytes,
-			Replay:       wrpl,
-			beData: &uiByteInterpreterData{
-				beFilter:        "^00035843d03f00fe01(........)",
-				beInterpretType: 4,
-			},
-		})
 	}
 	imBackend.Run(loop)
 
+	if *workspacePath != "" {
+		log.Err(saveWorkspace(*workspacePath)).Str("path", *workspacePath).Msg("saving workspace")
+	}
+
 	implot.DestroyContext()
 }
 
 func loop() {
+	reloadPacketSchema()
 	isOpen := true
 	viewport := imgui.MainViewport()
 	imgui.SetNextWindowPos(viewport.WorkPos())
@@ -198,7 +218,7 @@ func loop() {
 		isPinnedOpen := true
 		imgui.SetNextWindowSizeV(imgui.Vec2{X: 1300, Y: 700}, imgui.CondFirstUseEver)
 		if imgui.BeginV("pinned packet "+strconv.Itoa(pinID), &isPinnedOpen, imgui.WindowFlagsNoCollapse) {
-			uiShowPacket(ppk)
+			uiShowPacket(ppk, nil)
 			imgui.End()
 		}
 		if !isPinnedOpen {
@@ -238,7 +258,7 @@ func uiShowMainWindow() {
 				isPinnedOpen := true
 				imgui.SetNextWindowSizeV(imgui.Vec2{X: 1300, Y: 700}, imgui.CondFirstUseEver)
 				if imgui.BeginV("pinned packet "+strconv.Itoa(int(finding.InitialID)), &isPinnedOpen, imgui.WindowFlagsNoCollapse) {
-					uiShowPacketListInspect(finding.Packets, &v.PinnedFindings[ii].CurrentID, &v.PinnedFindings[ii].ViewingPacketListingMode)
+					uiShowPacketListInspect(v, finding.Packets, &v.PinnedFindings[ii].CurrentID, &v.PinnedFindings[ii].ViewingPacketListingMode)
 					imgui.End()
 				}
 				if !isPinnedOpen {
@@ -390,6 +410,10 @@ func uiShowBrowseTab() {
 		imgui.TextUnformatted("Error: " + wrplDiscoveryInputErr.Error())
 	}
 
+	uiShowWorkspaceControls()
+	uiShowLibrarySearch()
+	uiShowLibraryStats()
+
 	imgui.TextUnformatted(fmt.Sprintf("Found %d replay files", len(wrplDiscoveryFound)))
 	imgui.SameLine()
 	uiHelpMarker("Searched following locations:\n" + strings.Join(wrplDiscoveryDirs, "\n") + "\n\nAlso will detect directories in work dir that start with \"replay\"")
@@ -449,25 +473,36 @@ func uiShowBrowseTab() {
 	}
 }
 
-func addReplayTab(rpl *parsedReplay) {
+// addReplayTab returns already open tab if replay with the same hash is open
+func addReplayTab(rpl *parsedReplay) *parsedReplay {
 	openReplaysLock.Lock()
 	defer openReplaysLock.Unlock()
 	h := rpl.Replay.Header.Hash()
 	for _, v := range openReplays {
 		if v.Replay.Header.Hash() == h {
-			return
+			return v
 		}
 	}
 	if rpl.beData == nil {
 		rpl.beData = &uiByteInterpreterData{
-			// beFilter:        "^00035843d03f00fe01(........)",
-			beFilter:        "^00ff0f81(........)",
-			beInterpretType: 4,
+			beFilter: defaultByteInterpFilter,
 		}
 	}
 	if rpl.uiPacketInspect == nil {
 		rpl.uiPacketInspect = &uiPacketInspectData{}
 	}
+	if rpl.packetIndex == nil {
+		rpl.packetIndex = make(map[*wrpl.WRPLRawPacket]int, len(rpl.Replay.Packets))
+		for i, pk := range rpl.Replay.Packets {
+			rpl.packetIndex[pk] = i
+		}
+	}
+	if rpl.annotations == nil {
+		rpl.annotations = loadReplayAnnotations(rpl)
+	}
+	if rpl.Replay.Parsed != nil {
+		ecsNameDict.ResolveECS(rpl.Replay.Parsed.ECS)
+	}
 	if rpl.ParsingFailedPackets == nil {
 		for _, pk := range rpl.Replay.Packets {
 			if pk == nil {
@@ -523,50 +558,75 @@ func addReplayTab(rpl *parsedReplay) {
 			if !ok {
 				continue
 			}
-			for _, msgd := range pk1.Messages {
+			addECSMessage := func(name string, payload []byte, msg any) {
 				rpl.ECSMessages = append(rpl.ECSMessages, &wrpl.WRPLRawPacket{
 					CurrentTime:   pk.CurrentTime,
 					PacketType:    pk1.Control,
-					PacketPayload: msgd.Data,
+					PacketPayload: payload,
 					Parsed: &wrpl.ParsedPacket{
-						Name: "ecs",
-						Data: msgd,
+						Name: name,
+						Data: msg,
 					},
 				})
 			}
+			for _, msgd := range pk1.Messages {
+				addECSMessage("ecs", msgd.Data, msgd)
+			}
+			for _, msgd := range pk1.Replications {
+				addECSMessage("ecs replication", msgd.Data, msgd)
+			}
+			for _, msgd := range pk1.EntityMessages {
+				addECSMessage("ecs entity message", msgd.Data, msgd)
+			}
+			for _, msgd := range pk1.Destructions {
+				addECSMessage("ecs destruction", nil, msgd)
+			}
 		}
 	}
 	openReplays = append([]*parsedReplay{rpl}, openReplays...)
+	return rpl
 }
 
 func openSingleReplayFile(filePath string) error {
-	replayBytes, err := os.ReadFile(filePath)
-	if err != nil {
-		return err
-	}
-	wrpl, err := wrpl.ReadWRPL(bytes.NewReader(replayBytes), true, true, true)
-	if err != nil {
-		return err
-	}
-	addReplayTab(&parsedReplay{
-		LoadedFrom:   filePath,
-		FileContents: replayBytes,
-		Replay:       wrpl,
-	})
-	return nil
+	_, err := openReplaySource(replaySource{Kind: replaySourceFile, Path: filePath})
+	return err
 }
 
 func openSegmentedReplayFolder(folderPath string) error {
-	rpl, err := wrpl.ReadPartedWRPLFolder(folderPath)
-	if err != nil {
-		return err
+	_, err := openReplaySource(replaySource{Kind: replaySourceFolder, Path: folderPath})
+	return err
+}
+
+func openReplaySource(src replaySource) (*parsedReplay, error) {
+	switch src.Kind {
+	case replaySourceFile:
+		replayBytes, err := os.ReadFile(src.Path)
+		if err != nil {
+			return nil, err
+		}
+		wrpl, err := wrpl.ReadWRPL(bytes.NewReader(replayBytes), true, true, true)
+		if err != nil {
+			return nil, err
+		}
+		return addReplayTab(&parsedReplay{
+			LoadedFrom:   src.Path,
+			Source:       src,
+			FileContents: replayBytes,
+			Replay:       wrpl,
+		}), nil
+	case replaySourceFolder:
+		rpl, err := wrpl.ReadPartedWRPLFolder(src.Path)
+		if err != nil {
+			return nil, err
+		}
+		return addReplayTab(&parsedReplay{
+			LoadedFrom:   "opened session dir " + src.Path,
+			Source:       src,
+			FileContents: []byte("see files at " + src.Path),
+			Replay:       rpl,
+		}), nil
 	}
-	addReplayTab(&parsedReplay{
-		LoadedFrom:   "opened session dir " + folderPath,
-		FileContents: []byte("see files at " + folderPath),
-		Replay:       rpl,
-	})
-	return nil
+	return nil, fmt.Errorf("unknown replay source kind %q", src.Kind)
 }
 
 func fetchServerReplay(sessionNumberStr string) error {
@@ -611,6 +671,7 @@ func fetchServerReplay(sessionNumberStr string) error {
 	}
 	addReplayTab(&parsedReplay{
 		LoadedFrom:   "downloaded session " + sessionNumberStr,
+		Source:       replaySource{Kind: replaySourceFolder, Path: sessionReplaysDir},
 		FileContents: []byte("see fetched replays at " + sessionReplaysDir),
 		Replay:       rpl,
 	})
@@ -651,10 +712,26 @@ func uiShowParsedReplay(rpl *parsedReplay) {
 			uiShowParsed(rpl)
 			imgui.EndTabItem()
 		}
+		if imgui.BeginTabItem("coverage") {
+			uiShowCoverage(rpl)
+			imgui.EndTabItem()
+		}
 		if imgui.BeginTabItem("slot info") {
 			uiShowSlotInfo(rpl)
 			imgui.EndTabItem()
 		}
+		if imgui.BeginTabItem("roster") {
+			uiShowRoster(rpl)
+			imgui.EndTabItem()
+		}
+		if imgui.BeginTabItem("lineups") {
+			uiShowLineups(rpl)
+			imgui.EndTabItem()
+		}
+		if imgui.BeginTabItem("chat") {
+			uiShowChat(rpl)
+			imgui.EndTabItem()
+		}
 		if imgui.BeginTabItem("ecs") {
 			uiShowECS(rpl)
 			imgui.EndTabItem()
@@ -672,6 +749,28 @@ func uiShowECS(rpl *parsedReplay) {
 		imgui.TextUnformatted("ecs is nil")
 		return
 	}
+	if imgui.BeginTabBar("## ecs views") {
+		if imgui.BeginTabItem("templates") {
+			uiShowECSTemplates(rpl)
+			imgui.EndTabItem()
+		}
+		if imgui.BeginTabItem("entities") {
+			uiShowECSEntities(rpl)
+			imgui.EndTabItem()
+		}
+		if imgui.BeginTabItem("components") {
+			uiShowECSComponents(rpl)
+			imgui.EndTabItem()
+		}
+		if imgui.BeginTabItem("world") {
+			uiShowECSWorld(rpl)
+			imgui.EndTabItem()
+		}
+		imgui.EndTabBar()
+	}
+}
+
+func uiShowECSTemplates(rpl *parsedReplay) {
 	keys := slices.Sorted(maps.Keys(rpl.Replay.Parsed.ECS.TemplateDefs))
 	tableFlags := imgui.TableFlagsRowBg | imgui.TableFlagsBordersV | imgui.TableFlagsBordersOuterH | imgui.TableFlagsSizingFixedFit | imgui.TableFlagsScrollY | imgui.TableFlagsScrollX
 	if imgui.BeginTableV("ecs templates", 3, tableFlags, imgui.Vec2{}, 0) {
@@ -687,16 +786,201 @@ func uiShowECS(rpl *parsedReplay) {
 			imgui.TableNextColumn()
 			imgui.TextUnformatted(v.Name)
 			imgui.TableNextColumn()
-			compTypes := make([]uint32, len(v.Components))
+			compTypes := make([]string, len(v.Components))
 			for ii, vv := range v.Components {
-				compTypes[ii] = rpl.Replay.Parsed.ECS.ComponentDefs[vv].Type
+				compTypes[ii] = formatECSHash(rpl.Replay.Parsed.ECS.ComponentDefs[vv].Type)
 			}
-			imgui.TextUnformatted(fmt.Sprint(compTypes))
+			imgui.TextUnformatted(strings.Join(compTypes, " "))
+		}
+		imgui.EndTable()
+	}
+}
+
+func uiShowECSEntities(rpl *parsedReplay) {
+	imgui.TextUnformatted(fmt.Sprintf("%d entity messages", len(rpl.ECSMessages)))
+	templateName := func(id wrpl.ECSTemplateID) string {
+		if templ, ok := rpl.Replay.Parsed.ECS.TemplateDefs[id]; ok {
+			return templ.Name
+		}
+		return strconv.Itoa(int(id))
+	}
+	tableFlags := imgui.TableFlagsRowBg | imgui.TableFlagsBordersV | imgui.TableFlagsBordersOuterH | imgui.TableFlagsSizingFixedFit | imgui.TableFlagsScrollY | imgui.TableFlagsScrollX
+	if imgui.BeginTableV("ecs entities", 6, tableFlags, imgui.Vec2{}, 0) {
+		imgui.TableSetupScrollFreeze(0, 1)
+		imgui.TableSetupColumn("time")
+		imgui.TableSetupColumn("kind")
+		imgui.TableSetupColumn("eid")
+		imgui.TableSetupColumn("template")
+		imgui.TableSetupColumn("values")
+		imgui.TableSetupColumn("error")
+		imgui.TableHeadersRow()
+		clipper := imgui.NewListClipper()
+		clipper.Begin(int32(len(rpl.ECSMessages)))
+		for clipper.Step() {
+			for i := clipper.DisplayStart(); i < clipper.DisplayEnd(); i++ {
+				pk := rpl.ECSMessages[i]
+				imgui.TableNextRow()
+				imgui.TableNextColumn()
+				imgui.TextUnformatted(pk.Time().String())
+				imgui.TableNextColumn()
+				imgui.TextUnformatted(pk.Parsed.Name)
+				switch msg := pk.Parsed.Data.(type) {
+				case *wrpl.ECSMessage:
+					uiTableRowStrings(strconv.FormatUint(msg.EID, 10), templateName(msg.Template), formatECSValues(msg.Values), msg.DecodeError)
+				case *wrpl.ECSReplication:
+					uiTableRowStrings(strconv.FormatUint(msg.EID, 10), templateName(msg.Template), formatECSValues(msg.Values), msg.DecodeError)
+				case *wrpl.ECSEntityMessage:
+					uiTableRowStrings(strconv.FormatUint(msg.EID, 10), "", hex.EncodeToString(msg.Data), "")
+				case *wrpl.ECSDestruction:
+					uiTableRowStrings(strconv.FormatUint(msg.EID, 10), "", "", "")
+				}
+			}
+		}
+		clipper.End()
+		imgui.EndTable()
+	}
+}
+
+func uiShowECSWorld(rpl *parsedReplay) {
+	if rpl.ecsWorld == nil {
+		rpl.ecsWorld = wrpl.NewECSWorld(rpl.Replay, wrpl.DefaultECSWorldCheckpointInterval)
+	}
+	imgui.AlignTextToFramePadding()
+	imgui.TextUnformatted("Time")
+	imgui.SameLine()
+	imgui.SetNextItemWidth(imgui.ContentRegionAvail().X * 0.6)
+	timeChanged := imgui.SliderIntV("##world time", &rpl.ecsWorldTime, 0, int32(rpl.ecsWorld.Duration()), (time.Duration(rpl.ecsWorldTime) * time.Millisecond).String(), 0)
+	imgui.SameLine()
+	imgui.SetNextItemWidth(imgui.ContentRegionAvail().X)
+	imgui.InputTextWithHint("##world filter", "template filter", &rpl.ecsWorldFilter, 0, nil)
+	if rpl.ecsWorldState == nil || timeChanged {
+		rpl.ecsWorldState = rpl.ecsWorld.At(uint32(rpl.ecsWorldTime))
+	}
+	alive := []*wrpl.ECSEntityState{}
+	for _, e := range rpl.ecsWorldState.Alive() {
+		templName := ""
+		if templ, ok := rpl.Replay.Parsed.ECS.TemplateDefs[e.Template]; ok {
+			templName = templ.Name
+		}
+		if rpl.ecsWorldFilter != "" && !strings.Contains(templName, rpl.ecsWorldFilter) {
+			continue
+		}
+		alive = append(alive, e)
+	}
+	imgui.TextUnformatted(fmt.Sprintf("%d entities alive (%d shown)", len(rpl.ecsWorldState.Entities), len(alive)))
+	tableFlags := imgui.TableFlagsRowBg | imgui.TableFlagsBordersV | imgui.TableFlagsBordersOuterH | imgui.TableFlagsSizingFixedFit | imgui.TableFlagsScrollY | imgui.TableFlagsScrollX
+	if imgui.BeginTableV("ecs world", 5, tableFlags, imgui.Vec2{}, 0) {
+		imgui.TableSetupScrollFreeze(0, 1)
+		imgui.TableSetupColumn("eid")
+		imgui.TableSetupColumn("template")
+		imgui.TableSetupColumn("created")
+		imgui.TableSetupColumn("updated")
+		imgui.TableSetupColumn("values")
+		imgui.TableHeadersRow()
+		clipper := imgui.NewListClipper()
+		clipper.Begin(int32(len(alive)))
+		for clipper.Step() {
+			for i := clipper.DisplayStart(); i < clipper.DisplayEnd(); i++ {
+				e := alive[i]
+				templName := strconv.Itoa(int(e.Template))
+				if templ, ok := rpl.Replay.Parsed.ECS.TemplateDefs[e.Template]; ok {
+					templName = templ.Name
+				}
+				imgui.TableNextRow()
+				uiTableRowStrings(
+					strconv.FormatUint(e.EID, 10),
+					templName,
+					(time.Duration(e.CreatedAt) * time.Millisecond).String(),
+					(time.Duration(e.UpdatedAt) * time.Millisecond).String(),
+					formatECSValues(e.Values),
+				)
+			}
+		}
+		clipper.End()
+		imgui.EndTable()
+	}
+}
+
+func uiTableRowStrings(cols ...string) {
+	for _, c := range cols {
+		imgui.TableNextColumn()
+		imgui.TextUnformatted(c)
+	}
+}
+
+func uiShowECSComponents(rpl *parsedReplay) {
+	imgui.AlignTextToFramePadding()
+	imgui.TextUnformatted(fmt.Sprintf("%d known names from %q", ecsNameDict.Len(), *ecsNamesPath))
+	imgui.SameLine()
+	if imgui.Button("reload names") {
+		loadECSNames()
+		// openReplaysLock is held by the main window while drawing tabs
+		for _, v := range openReplays {
+			if v.Replay.Parsed != nil {
+				ecsNameDict.ResolveECS(v.Replay.Parsed.ECS)
+			}
+			v.lineups = nil
+		}
+	}
+	imgui.SameLine()
+	if imgui.Button("save unresolved") {
+		buf := &bytes.Buffer{}
+		log.Err(ecsNameDict.WriteUnresolved(buf)).Msg("writing unresolved ecs hashes")
+		log.Err(os.WriteFile("ecsnames_unresolved.txt", buf.Bytes(), 0644)).Msg("saving unresolved ecs hashes")
+	}
+	imgui.SameLine()
+	imgui.TextUnformatted(fmt.Sprintf("%d unresolved", len(ecsNameDict.Unresolved())))
+	keys := slices.Sorted(maps.Keys(rpl.Replay.Parsed.ECS.ComponentDefs))
+	tableFlags := imgui.TableFlagsRowBg | imgui.TableFlagsBordersV | imgui.TableFlagsBordersOuterH | imgui.TableFlagsSizingFixedFit | imgui.TableFlagsScrollY | imgui.TableFlagsScrollX
+	if imgui.BeginTableV("ecs components", 5, tableFlags, imgui.Vec2{}, 0) {
+		imgui.TableSetupScrollFreeze(0, 1)
+		imgui.TableSetupColumn("id")
+		imgui.TableSetupColumn("name hash")
+		imgui.TableSetupColumn("name")
+		imgui.TableSetupColumn("type hash")
+		imgui.TableSetupColumn("type")
+		imgui.TableHeadersRow()
+		for _, k := range keys {
+			v := rpl.Replay.Parsed.ECS.ComponentDefs[k]
+			imgui.TableNextRow()
+			imgui.TableNextColumn()
+			imgui.TextUnformatted(strconv.Itoa(int(k)))
+			imgui.TableNextColumn()
+			imgui.TextUnformatted(fmt.Sprintf("%08x", v.Name))
+			imgui.TableNextColumn()
+			imgui.TextUnformatted(v.ResolvedName)
+			imgui.TableNextColumn()
+			imgui.TextUnformatted(fmt.Sprintf("%08x", v.Type))
+			imgui.TableNextColumn()
+			imgui.TextUnformatted(v.ResolvedType)
 		}
 		imgui.EndTable()
 	}
 }
 
+func loadECSNames() {
+	d, err := wrpl.LoadECSNameDict(*ecsNamesPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Err(err).Str("path", *ecsNamesPath).Msg("loading ecs names")
+	}
+	ecsNameDict = d
+}
+
+func formatECSHash(h uint32) string {
+	if n, ok := ecsNameDict.Lookup(h); ok {
+		return n
+	}
+	return fmt.Sprintf("%08x", h)
+}
+
+func formatECSValues(values map[uint32]any) string {
+	ret := []string{}
+	for _, k := range slices.Sorted(maps.Keys(values)) {
+		ret = append(ret, formatECSHash(k)+"="+fmt.Sprint(values[k]))
+	}
+	return strings.Join(ret, " ")
+}
+
 func uiShowSlotInfo(rpl *parsedReplay) {
 	tableFlags := imgui.TableFlagsRowBg | imgui.TableFlagsBordersV | imgui.TableFlagsBordersOuterH | imgui.TableFlagsSizingFixedFit | imgui.TableFlagsScrollY | imgui.TableFlagsScrollX
 	if imgui.BeginTableV("playersTable", 7, tableFlags, imgui.Vec2{}, 0) {
@@ -732,73 +1016,48 @@ func uiShowSlotInfo(rpl *parsedReplay) {
 	}
 }
 
-func viewReflection(packets []*wrpl.WRPLRawPacket) {
-	if len(packets) == 0 {
-		imgui.TextUnformatted("no packets?")
-		return
-	}
-	if packets[0] == nil {
-		imgui.TextUnformatted("first packet nil?")
-		return
-	}
-	if packets[0].Parsed == nil {
-		imgui.TextUnformatted("first packet parsed nil?")
-		return
-	}
-	if packets[0].Parsed.Data == nil {
-		imgui.TextUnformatted("first packet parsed data nil?")
+func uiShowRoster(rpl *parsedReplay) {
+	if rpl.Replay.Parsed == nil || rpl.Replay.Parsed.Roster == nil {
+		imgui.TextUnformatted("roster is nil")
 		return
 	}
-	rfPkType := reflect.TypeOf(packets[0].Parsed.Data)
-	fieldNames := []string{}
-	fieldIndexes := []int{}
-	fieldCount := 0
-	for i := range rfPkType.NumField() {
-		if rfPkType.Field(i).Tag.Get("reflectViewHidden") != "true" {
-			fieldNames = append(fieldNames, rfPkType.Field(i).Name)
-			fieldIndexes = append(fieldIndexes, i)
-			fieldCount++
-		}
-	}
-	tableFlags := imgui.TableFlagsRowBg |
-		imgui.TableFlagsBordersV |
-		imgui.TableFlagsBordersOuterH |
-		imgui.TableFlagsSizingFixedFit |
-		// imgui.TableFlagsSortable |
-		// imgui.TableFlagsSortTristate |
-		imgui.TableFlagsReorderable |
-		imgui.TableFlagsResizable
-	if imgui.BeginTableV("##context", 2+int32(fieldCount), tableFlags, imgui.Vec2{X: 0, Y: 0}, 0) {
-		imgui.TableSetupColumn("num")
-		imgui.TableSetupColumn("time")
-		for _, n := range fieldNames {
-			imgui.TableSetupColumn(n)
-		}
+	imgui.TextUnformatted(fmt.Sprintf("%d participants", len(rpl.Replay.Parsed.Roster.Participants)))
+	imgui.SameLine()
+	uiHelpMarker("Players from slot init messages and results, eids are linked by ecs component values\nequal to player name or user id, vehicles come from kills and results.")
+	tableFlags := imgui.TableFlagsRowBg | imgui.TableFlagsBordersV | imgui.TableFlagsBordersOuterH | imgui.TableFlagsSizingFixedFit | imgui.TableFlagsScrollY | imgui.TableFlagsScrollX
+	if imgui.BeginTableV("rosterTable", 9, tableFlags, imgui.Vec2{}, 0) {
+		imgui.TableSetupScrollFreeze(0, 1)
+		imgui.TableSetupColumn("n")
+		imgui.TableSetupColumn("name")
+		imgui.TableSetupColumn("clan")
+		imgui.TableSetupColumn("id")
+		imgui.TableSetupColumn("slots")
+		imgui.TableSetupColumn("eids")
+		imgui.TableSetupColumn("team")
+		imgui.TableSetupColumn("squad")
+		imgui.TableSetupColumn("vehicles")
 		imgui.TableHeadersRow()
-		for i, pk := range packets {
-			imgui.TableNextRow()
-			imgui.TableNextColumn()
-			imgui.TextUnformatted(strconv.Itoa(i))
-			imgui.TableNextColumn()
-			imgui.TextUnformatted(pk.Time().String())
-			rfPkVal := reflect.ValueOf(pk.Parsed.Data)
-			for i := range fieldNames {
-				imgui.TableNextColumn()
-				rfPkField := rfPkVal.Field(fieldIndexes[i])
-				if rfPkField.Kind() == reflect.String {
-					imgui.TextUnformatted(rfPkField.String())
-				} else if rfPkField.Kind() == reflect.Bool {
-					if rfPkField.Bool() {
-						imgui.TextUnformatted("true")
-					} else {
-						imgui.TextUnformatted("false")
-					}
-				} else if rfPkField.CanUint() {
-					imgui.TextUnformatted(strconv.FormatUint(rfPkField.Uint(), 10))
-				} else {
-					imgui.TextUnformatted(rfPkVal.String())
-				}
+		for _, p := range rpl.Replay.Parsed.Roster.Participants {
+			slots := []string{}
+			for _, s := range p.Slots {
+				slots = append(slots, strconv.Itoa(int(s)))
+			}
+			eids := []string{}
+			for _, e := range p.Eids {
+				eids = append(eids, strconv.FormatUint(e, 10))
 			}
+			imgui.TableNextRow()
+			uiTableRowStrings(
+				strconv.Itoa(p.ID),
+				p.Name,
+				p.ClanTag,
+				strconv.Itoa(int(p.UserID)),
+				strings.Join(slots, " "),
+				strings.Join(eids, " "),
+				strconv.Itoa(p.Team),
+				strconv.Itoa(p.Squad),
+				strings.Join(p.Vehicles, ", "),
+			)
 		}
 		imgui.EndTable()
 	}
@@ -838,207 +1097,12 @@ func uiShowParsed(rpl *parsedReplay) {
 		packets := rpl.ParsedPackets[rpl.ParsedPacketsCurrentName]
 		// packetName := rpl.ParsedPacketNames[rpl.ParsedPacketsCurrentName]
 
-		viewReflection(packets)
+		viewReflection(rpl, packets)
 
 		imgui.EndChild()
 	}
 }
 
-var (
-	beInterpretTypeNames = []string{"uint8", "uint16", "uint32", "uint64", "float32", "float64"}
-)
-
-type uiByteInterpreterData struct {
-	beProcessed      bool
-	beFirstFit       bool
-	beFilter         string
-	beFilterRegex    *regexp.Regexp
-	beFilterErr      error
-	bePlotX          []float32
-	bePlotY          []float32
-	bePlotRaw        []string
-	bePlotRawFull    []string
-	beInterpretType  int32
-	beInterpretShift int32
-	bePlotIsScatter  bool
-	beShowTable      bool
-}
-
-func genByteInterp(rpl *parsedReplay) error {
-	dat := rpl.beData
-	dat.bePlotX = nil
-	dat.bePlotY = nil
-	dat.bePlotRaw = nil
-	dat.bePlotRawFull = nil
-	dat.beFilterRegex, dat.beFilterErr = regexp.Compile(dat.beFilter)
-	if dat.beFilterErr != nil {
-		return dat.beFilterErr
-	}
-	dat.bePlotX = []float32{}
-	dat.bePlotY = []float32{}
-	dat.bePlotRaw = []string{}
-	dat.bePlotRawFull = []string{}
-	for _, pk := range rpl.Replay.Packets {
-		hexpayload := hex.EncodeToString(pk.PacketPayload)
-		matches := dat.beFilterRegex.FindStringSubmatch(hexpayload)
-		if matches == nil {
-			continue
-		}
-		if len(matches) < 2 {
-			continue
-		}
-		dat.bePlotRawFull = append(dat.bePlotRawFull, hexpayload)
-		valY := matches[1]
-		bY, err := hex.DecodeString(valY)
-		if err != nil {
-			return err
-		}
-		dat.bePlotRaw = append(dat.bePlotRaw, valY)
-		switch dat.beInterpretType {
-		case 0:
-			dat.bePlotY = append(dat.bePlotY, float32(interpretBytes[uint8](bY, dat.beInterpretShift)))
-		case 1:
-			dat.bePlotY = append(dat.bePlotY, float32(interpretBytes[uint16](bY, dat.beInterpretShift)))
-		case 2:
-			dat.bePlotY = append(dat.bePlotY, float32(interpretBytes[uint32](bY, dat.beInterpretShift)))
-		case 3:
-			dat.bePlotY = append(dat.bePlotY, float32(interpretBytes[uint64](bY, dat.beInterpretShift)))
-		case 4:
-			dat.bePlotY = append(dat.bePlotY, float32(interpretBytes[float32](bY, dat.beInterpretShift)))
-		case 5:
-			dat.bePlotY = append(dat.bePlotY, float32(interpretBytes[float64](bY, dat.beInterpretShift)))
-		default:
-			return errors.ErrUnsupported
-		}
-		if len(matches) < 3 {
-			dat.bePlotX = append(dat.bePlotX, float32(pk.CurrentTime))
-			continue
-		}
-		valX := matches[2]
-		bX, err := hex.DecodeString(valX)
-		if err != nil {
-			return err
-		}
-		dat.bePlotRaw = append(dat.bePlotRaw, valX)
-		switch dat.beInterpretType {
-		case 0:
-			dat.bePlotX = append(dat.bePlotX, float32(interpretBytes[uint8](bX, dat.beInterpretShift)))
-		case 1:
-			dat.bePlotX = append(dat.bePlotX, float32(interpretBytes[uint16](bX, dat.beInterpretShift)))
-		case 2:
-			dat.bePlotX = append(dat.bePlotX, float32(interpretBytes[uint32](bX, dat.beInterpretShift)))
-		case 3:
-			dat.bePlotX = append(dat.bePlotX, float32(interpretBytes[uint64](bX, dat.beInterpretShift)))
-		case 4:
-			dat.bePlotX = append(dat.bePlotX, float32(interpretBytes[float32](bX, dat.beInterpretShift)))
-		case 5:
-			dat.bePlotX = append(dat.bePlotX, float32(interpretBytes[float64](bX, dat.beInterpretShift)))
-		default:
-			return errors.ErrUnsupported
-		}
-	}
-	return nil
-}
-
-func interpretBytes[T any](b []byte, shift int32) T {
-	b = ShiftBytes(b, int(shift))
-	var v T
-	binary.Read(bytes.NewReader(b), binary.LittleEndian, &v)
-	return v
-}
-
-func uiShowReplayPacketByteInterpreter(rpl *parsedReplay) {
-	dat := rpl.beData
-	if !dat.beProcessed {
-		// ^00035843d03f00fe01(....)
-		// ^00ff0f81(........)
-		dat.beProcessed = true
-		dat.beFirstFit = true
-		dat.beFilterErr = genByteInterp(rpl)
-		if dat.beFilterErr != nil {
-			dat.bePlotX = nil
-		}
-	}
-	if imgui.InputTextWithHint("regex filter", "", &dat.beFilter, 0, func(data imgui.InputTextCallbackData) int {
-		dat.beProcessed = false
-		return 0
-	}) {
-		dat.beProcessed = false
-	}
-	if imgui.InputInt("shift", &dat.beInterpretShift) {
-		dat.beProcessed = false
-	}
-	if imgui.ComboStrarr("##view mode", &dat.beInterpretType, beInterpretTypeNames, int32(len(beInterpretTypeNames))) {
-		dat.beProcessed = false
-	}
-	if dat.beFilterErr != nil {
-		imgui.TextUnformatted("Error: " + dat.beFilterErr.Error())
-		return
-	}
-	if imgui.Button("reprocess") {
-		dat.beProcessed = false
-	}
-	imgui.SameLine()
-	imgui.Checkbox("isScatter", &dat.bePlotIsScatter)
-	imgui.SameLine()
-	imgui.Checkbox("showTable", &dat.beShowTable)
-	imgui.SameLine()
-	imgui.TextUnformatted(fmt.Sprintf("%d samples", len(dat.bePlotX)))
-	imgui.SetNextItemWidth(imgui.ContentRegionAvail().X)
-	if dat.bePlotX == nil {
-		imgui.TextUnformatted("nil")
-	} else if len(dat.bePlotX) == 0 {
-		imgui.TextUnformatted("0 len")
-	} else {
-		if dat.beFirstFit {
-			dat.beFirstFit = false
-			implot.SetNextAxesToFit()
-		}
-		size := imgui.Vec2{X: -1, Y: -1}
-		if dat.beShowTable {
-			size = imgui.Vec2{X: -1, Y: 0}
-		}
-		if implot.BeginPlotV("##da values plot", size, 0) {
-			if dat.bePlotIsScatter {
-				implot.PlotScatterFloatPtrFloatPtr("val", &dat.bePlotX[0], &dat.bePlotY[0], int32(len(dat.bePlotX)))
-			} else {
-				implot.PlotLineFloatPtrFloatPtr("val", &dat.bePlotX[0], &dat.bePlotY[0], int32(len(dat.bePlotX)))
-			}
-			implot.EndPlot()
-		}
-		if dat.beShowTable && imgui.BeginChildStr("values table child") {
-			tableFlags := imgui.TableFlagsRowBg | imgui.TableFlagsBordersV | imgui.TableFlagsBordersOuterH | imgui.TableFlagsSizingFixedFit | imgui.TableFlagsScrollY | imgui.TableFlagsScrollX
-			if imgui.BeginTableV("values table", 4, tableFlags, imgui.Vec2{}, 0.0) {
-				imgui.TableSetupScrollFreeze(0, 1)
-				imgui.TableSetupColumn("X")
-				imgui.TableSetupColumn("Y")
-				imgui.TableSetupColumn("raw")
-				imgui.TableSetupColumn("packet")
-				imgui.TableHeadersRow()
-				clipper := imgui.NewListClipper()
-				clipper.Begin(int32(len(dat.bePlotX)))
-				for clipper.Step() {
-					for i := clipper.DisplayStart(); i < clipper.DisplayEnd(); i++ {
-						imgui.TableNextRow()
-						imgui.TableNextColumn()
-						imgui.TextUnformatted(fmt.Sprintf("%#v", dat.bePlotX[i]))
-						imgui.TableNextColumn()
-						imgui.TextUnformatted(fmt.Sprintf("%#v", dat.bePlotY[i]))
-						imgui.TableNextColumn()
-						imgui.TextUnformatted(fmt.Sprintf("%#v", dat.bePlotRaw[i]))
-						imgui.TableNextColumn()
-						imgui.TextUnformatted(fmt.Sprintf("%#v", dat.bePlotRawFull[i]))
-					}
-				}
-				clipper.End()
-				imgui.EndTable()
-			}
-
-			imgui.EndChild()
-		}
-	}
-}
-
 func uiShowReplaySummary(rpl *parsedReplay) {
 	imgui.TextUnformatted(rpl.LoadedFrom)
 	uiTextParam("Session:", fmt.Sprintf("%016x", rpl.Replay.Header.SessionID))
@@ -1049,6 +1113,9 @@ func uiShowReplaySummary(rpl *parsedReplay) {
 	uiTextParam("Start time:", time.Unix(int64(rpl.Replay.Header.StartTime), 0).Format(time.DateTime))
 	uiTextParam("Time limit:", strconv.Itoa(int(rpl.Replay.Header.TimeLimit)))
 	uiTextParam("Score limit:", strconv.Itoa(int(rpl.Replay.Header.ScoreLimit)))
+	for _, issue := range rpl.Replay.ContinuityIssues {
+		imgui.TextUnformatted("Continuity: " + issue)
+	}
 }
 
 type uiPacketInspectData struct {
@@ -1067,8 +1134,76 @@ type uiPacketInspectData struct {
 
 var (
 	uiPacketInspectSubsetNames = []string{"Packet stream", "Failed to parse", "Slot packets", "ECS packets"}
+	packetSearchModeNames      = []string{"regex hex", "regex bin", "regex plain", "prefix hex", "contains hex", "contains plain", "notes", "query"}
 )
 
+// newPacketMatcher compiles search term of given mode (index in packetSearchModeNames)
+func newPacketMatcher(mode int32, term string, annotations *replayAnnotations) (func(*wrpl.WRPLRawPacket) bool, error) {
+	var matchFn func([]byte) bool
+	var matchPacketFn func(*wrpl.WRPLRawPacket) bool
+	var err error
+	switch mode {
+	case 0:
+		var reg *regexp.Regexp
+		reg, err = regexp.Compile(term)
+		matchFn = func(b []byte) bool {
+			return reg.Match([]byte(hex.EncodeToString(b)))
+		}
+	case 1:
+		var reg *regexp.Regexp
+		reg, err = regexp.Compile(term)
+		matchFn = func(b []byte) bool {
+			bb := []byte{}
+			for _, v := range b {
+				bb = append(bb, strconv.FormatUint(uint64(v), 2)...)
+			}
+			return reg.Match(bb)
+		}
+	case 2:
+		var reg *regexp.Regexp
+		reg, err = regexp.Compile(term)
+		matchFn = reg.Match
+	case 3:
+		var prefix []byte
+		prefix, err = hex.DecodeString(strings.ReplaceAll(strings.ReplaceAll(strings.ReplaceAll(term, `^`, ""), `\x`, ""), " ", ""))
+		matchFn = func(b []byte) bool {
+			return bytes.HasPrefix(b, prefix)
+		}
+	case 4:
+		var needle []byte
+		needle, err = hex.DecodeString(strings.ReplaceAll(term, " ", ""))
+		matchFn = func(b []byte) bool {
+			return bytes.Contains(b, needle)
+		}
+	case 5:
+		matchFn = func(b []byte) bool {
+			return bytes.Contains(b, []byte(term))
+		}
+	case 6:
+		matchPacketFn = func(pk *wrpl.WRPLRawPacket) bool {
+			return annotations.matches(pk, term)
+		}
+	case 7:
+		var q *wrpl.Query
+		q, err = wrpl.ParseQuery(term)
+		if q != nil {
+			matchPacketFn = q.Match
+		}
+	default:
+		err = fmt.Errorf("unknown search mode %d", mode)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	if matchPacketFn != nil {
+		return matchPacketFn, nil
+	}
+	return func(pk *wrpl.WRPLRawPacket) bool {
+		return matchFn(pk.PacketPayload)
+	}, nil
+}
+
 func uiShowPacketInspect(rpl *parsedReplay) {
 	dat := rpl.uiPacketInspect
 	doSearch := false
@@ -1085,7 +1220,11 @@ func uiShowPacketInspect(rpl *parsedReplay) {
 	imgui.TextUnformatted("Search")
 	imgui.SameLine()
 	imgui.SetNextItemWidth(imgui.ContentRegionAvail().X * 0.75)
-	if imgui.InputTextWithHint("##searchbox", "", &dat.SearchTerm, 0, func(data imgui.InputTextCallbackData) int {
+	searchHint := ""
+	if dat.SearchMode == 7 {
+		searchHint = `type=mpi and name=kill and KillerVehicle ~ "tiger" and time between 5m and 10m`
+	}
+	if imgui.InputTextWithHint("##searchbox", searchHint, &dat.SearchTerm, 0, func(data imgui.InputTextCallbackData) int {
 		doSearch = true
 		return 0
 	}) {
@@ -1095,8 +1234,7 @@ func uiShowPacketInspect(rpl *parsedReplay) {
 	imgui.TextUnformatted("Mode")
 	imgui.SameLine()
 	imgui.SetNextItemWidth(imgui.ContentRegionAvail().X)
-	modesStrs := []string{"regex hex", "regex bin", "regex plain", "prefix hex", "contains hex", "contains plain"}
-	if imgui.ComboStrarr("##searchMode", &dat.SearchMode, modesStrs, int32(len(modesStrs))) {
+	if imgui.ComboStrarr("##searchMode", &dat.SearchMode, packetSearchModeNames, int32(len(packetSearchModeNames))) {
 		doSearch = true
 	}
 	if imgui.Checkbox("Filter type", &dat.EnableFilterByType) {
@@ -1113,71 +1251,21 @@ func uiShowPacketInspect(rpl *parsedReplay) {
 		dat.ResultGlobalIDs = []int32{}
 		dat.Results = []*wrpl.WRPLRawPacket{}
 
-		var matchFn func([]byte) bool
-		var err error
-		switch dat.SearchMode {
-		case 0:
-			var reg *regexp.Regexp
-			reg, err = regexp.Compile(dat.SearchTerm)
-			matchFn = func(b []byte) bool {
-				return reg.Match([]byte(hex.EncodeToString(b)))
-			}
-		case 1:
-			var reg *regexp.Regexp
-			reg, err = regexp.Compile(dat.SearchTerm)
-			matchFn = func(b []byte) bool {
-				bb := []byte{}
-				for _, v := range b {
-					bb = append(bb, strconv.FormatUint(uint64(v), 2)...)
-				}
-				return reg.Match(bb)
-			}
-		case 2:
-			var reg *regexp.Regexp
-			reg, err = regexp.Compile(dat.SearchTerm)
-			matchFn = reg.Match
-		case 3:
-			var term []byte
-			term, err = hex.DecodeString(strings.ReplaceAll(strings.ReplaceAll(strings.ReplaceAll(dat.SearchTerm, `^`, ""), `\x`, ""), " ", ""))
-			matchFn = func(b []byte) bool {
-				return bytes.HasPrefix(b, term)
-			}
-		case 4:
-			var term []byte
-			term, err = hex.DecodeString(strings.ReplaceAll(dat.SearchTerm, " ", ""))
-			matchFn = func(b []byte) bool {
-				return bytes.Contains(b, term)
-			}
-		case 5:
-			matchFn = func(b []byte) bool {
-				return bytes.Contains(b, []byte(dat.SearchTerm))
-			}
-		default:
-			log.Warn().Int32("format", dat.SearchMode).Msg("wrong search format")
-		}
-
+		matchFn, err := newPacketMatcher(dat.SearchMode, dat.SearchTerm, rpl.annotations)
 		if err != nil {
-			dat.Error = fmt.Errorf("decoding hex %q: %w", dat.SearchTerm, err)
+			dat.Error = fmt.Errorf("search %q: %w", dat.SearchTerm, err)
 			return
 		} else {
 			dat.Error = nil
 		}
 
-		subset := rpl.Replay.Packets
-		switch dat.Subset {
-		case 1:
-			subset = rpl.ParsingFailedPackets
-		case 2:
-			subset = rpl.SlotMessages
-		case 3:
-			subset = rpl.ECSMessages
-		}
+		subset := replaySubset(rpl, dat.Subset)
 
 		for i, pk := range subset {
 			if dat.EnableFilterByType && pk.PacketType != byte(dat.FilterByType) {
 				continue
 			}
-			if matchFn(pk.PacketPayload) {
+			if matchFn(pk) {
 				dat.Results = append(dat.Results, pk)
 				dat.ResultGlobalIDs = append(dat.ResultGlobalIDs, int32(i))
 			}
@@ -1196,6 +1284,7 @@ func uiShowPacketInspect(rpl *parsedReplay) {
 	if imgui.Button("search") {
 		rpl.PinnedFindings = append(rpl.PinnedFindings, pinnedFinding{
 			Packets:   slices.Clone(dat.Results),
+			Subset:    dat.Subset,
 			InitialID: dat.ViewPacketID,
 			CurrentID: dat.ViewPacketID,
 		})
@@ -1227,11 +1316,11 @@ func uiShowPacketInspect(rpl *parsedReplay) {
 	imgui.SameLine()
 	imgui.Checkbox("humanize time", &humanizeTime)
 
-	uiShowPacketListInspect(dat.Results, &dat.ViewPacketID, &dat.ViewMode)
+	uiShowPacketListInspect(rpl, dat.Results, &dat.ViewPacketID, &dat.ViewMode)
 }
 
-func uiShowPacketListInspect(packets []*wrpl.WRPLRawPacket, selected *int32, mode *int32) {
-	viewModes := []string{"hexdump", "context hex", "context plain", "context both", "amout/time", "len/time"}
+func uiShowPacketListInspect(rpl *parsedReplay, packets []*wrpl.WRPLRawPacket, selected *int32, mode *int32) {
+	viewModes := []string{"hexdump", "context hex", "context plain", "context both", "amout/time", "len/time", "field stats"}
 
 	imgui.AlignTextToFramePadding()
 	imgui.TextUnformatted("Mode")
@@ -1259,7 +1348,7 @@ func uiShowPacketListInspect(packets []*wrpl.WRPLRawPacket, selected *int32, mod
 	switch *mode {
 	case 0:
 		if *selected >= 0 && int(*selected) < len(packets) {
-			uiShowPacket(packets[*selected])
+			uiShowPacket(packets[*selected], rpl.annotations)
 		} else {
 			imgui.TextUnformatted("selection out of range")
 		}
@@ -1273,12 +1362,13 @@ func uiShowPacketListInspect(packets []*wrpl.WRPLRawPacket, selected *int32, mod
 			contextSize = int32(10)
 		}
 		tableFlags := imgui.TableFlagsRowBg | imgui.TableFlagsBordersV | imgui.TableFlagsBordersOuterH | imgui.TableFlagsSizingFixedFit | imgui.TableFlagsScrollX
-		if imgui.BeginTableV("##context", 5, tableFlags, imgui.Vec2{X: 0, Y: 0}, 0) {
+		if imgui.BeginTableV("##context", 6, tableFlags, imgui.Vec2{X: 0, Y: 0}, 0) {
 			imgui.TableSetupColumn("idx")
 			imgui.TableSetupColumn("time")
 			imgui.TableSetupColumn("dtime")
 			imgui.TableSetupColumn("t")
 			imgui.TableSetupColumn("content")
+			imgui.TableSetupColumn("notes")
 			imgui.TableHeadersRow()
 			for offset := range contextSize*2 + 1 {
 				i := *selected + offset - contextSize
@@ -1356,6 +1446,8 @@ func uiShowPacketListInspect(packets []*wrpl.WRPLRawPacket, selected *int32, mod
 						imgui.TextUnformatted(hex.EncodeToString(payload))
 						imgui.TextUnformatted(show)
 					}
+					imgui.TableNextColumn()
+					imgui.TextUnformatted(rpl.annotations.summary(pk))
 				} else {
 					imgui.TableNextColumn()
 					imgui.TextUnformatted("")
@@ -1373,6 +1465,8 @@ func uiShowPacketListInspect(packets []*wrpl.WRPLRawPacket, selected *int32, mod
 						imgui.TextUnformatted("")
 						imgui.TextUnformatted("")
 					}
+					imgui.TableNextColumn()
+					imgui.TextUnformatted("")
 				}
 			}
 			isHoverScroll = isHoverScroll || imgui.IsItemHovered()
@@ -1406,6 +1500,8 @@ func uiShowPacketListInspect(packets []*wrpl.WRPLRawPacket, selected *int32, mod
 			implot.PlotBarsFloatPtrFloatPtr("val", &plX[0], &plY[0], int32(len(plX)), 1.0)
 			implot.EndPlot()
 		}
+	case 6:
+		uiShowFieldStats(packets)
 	}
 	if isHoverScroll {
 		wh := imgui.CurrentIO().MouseWheel()
@@ -1417,7 +1513,7 @@ func uiShowPacketListInspect(packets []*wrpl.WRPLRawPacket, selected *int32, mod
 	}
 }
 
-func uiShowPacket(pk *wrpl.WRPLRawPacket) {
+func uiShowPacket(pk *wrpl.WRPLRawPacket, annotations *replayAnnotations) {
 	uiTextParam("Timestamp:", strconv.Itoa(int(pk.CurrentTime)))
 	imgui.SameLine()
 	imgui.TextUnformatted(pk.Time().String())
@@ -1443,12 +1539,12 @@ func uiShowPacket(pk *wrpl.WRPLRawPacket) {
 	if imgui.Button("spew") {
 		imgui.SetClipboardText(spew.Sdump(pk))
 	}
+	uiShowPacketAnnotations(annotations, pk)
 
 	if imgui.BeginTable("##packetlayout", 2) {
 		imgui.TableNextRow()
 		imgui.TableNextColumn()
-		d := hex.Dump(pk.PacketPayload)
-		imgui.InputTextMultiline("## packet hexdump", &d, imgui.ContentRegionAvail(), 0, nil)
+		uiShowHexView(pk)
 
 		imgui.TableNextColumn()
 
@@ -1462,46 +1558,20 @@ func uiShowPacket(pk *wrpl.WRPLRawPacket) {
 		} else {
 			uiShowParsedPacket(pk)
 		}
+		uiShowSchemaDecode(pk)
 
 		imgui.EndTable()
 	}
 }
 
-func ShiftBytes(b []byte, n int) []byte {
-	if len(b) == 0 {
-		return nil
-	}
-	totalBits := 8 * len(b)
-	n = ((n % totalBits) + totalBits) % totalBits
-	if n == 0 {
-		out := make([]byte, len(b))
-		copy(out, b)
-		return out
-	}
-	out := make([]byte, len(b))
-	byteShift := n / 8
-	bitShift := n % 8
-	invBitShift := 8 - bitShift
-	for i := range b {
-		srcIndex := i - byteShift
-		var v byte = 0
-		if srcIndex >= 0 && srcIndex < len(b) {
-			v = b[srcIndex] << uint(bitShift)
-		}
-		var carry byte = 0
-		srcIndex2 := srcIndex + 1
-		if bitShift != 0 && srcIndex2 >= 0 && srcIndex2 < len(b) {
-			carry = b[srcIndex2] >> uint(invBitShift)
-		}
-		out[i] = v | carry
-	}
-	return out
-}
-
 func uiShowParsedPacket(pk *wrpl.WRPLRawPacket) {
 	imgui.TextUnformatted("Packet name: " + pk.Parsed.Name)
 	data := spew.Sdump(pk.Parsed.Data)
-	imgui.InputTextMultiline("## parsed props", &data, imgui.ContentRegionAvail(), 0, nil)
+	size := imgui.ContentRegionAvail()
+	if pktSchema != nil {
+		size.Y /= 2
+	}
+	imgui.InputTextMultiline("## parsed props", &data, size, 0, nil)
 }
 
 func uiShowBigEditField(content string) {
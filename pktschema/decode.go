@@ -0,0 +1,294 @@
+/*
+	wrpl: War Thunder replay parsing library (golang)
+	Copyright (C) 2025 flexcoral
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published
+	by the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package pktschema
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/maxsupermanhd/wrpl-inspector/danet"
+	"github.com/pierrec/lz4/v4"
+)
+
+// maxBlobSize limits decompressed sub-blobs
+const maxBlobSize = 16 * 1024 * 1024
+
+// Field is a decoded value, BitStart and BitEnd are offsets in the buffer
+// the field was read from, for fields inside of compressed blocks (InBlob)
+// they point into decompressed data
+type Field struct {
+	Name     string
+	Kind     string
+	Value    any
+	BitStart int
+	BitEnd   int
+	InBlob   bool
+	Children []*Field
+}
+
+func (f *Field) String() string {
+	switch v := f.Value.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return hex.EncodeToString(v)
+	case string:
+		return strconv.Quote(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+type decoder struct {
+	r      *danet.BitReader
+	inBlob bool
+	scope  []map[string]any
+}
+
+// Decode reads packet payload according to definition, fields decoded
+// before error are returned along with it
+func (d *PacketDef) Decode(payload []byte) ([]*Field, error) {
+	dc := &decoder{r: danet.NewBitReader(payload)}
+	return dc.decodeFields(d.Fields)
+}
+
+func (dc *decoder) lookup(name string) (uint64, bool) {
+	for i := len(dc.scope) - 1; i >= 0; i-- {
+		v, ok := dc.scope[i][name]
+		if !ok {
+			continue
+		}
+		switch vv := v.(type) {
+		case uint64:
+			return vv, true
+		case int64:
+			return uint64(vv), true
+		case bool:
+			if vv {
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+	return 0, false
+}
+
+func (dc *decoder) decodeFields(defs []*FieldDef) (ret []*Field, err error) {
+	dc.scope = append(dc.scope, map[string]any{})
+	defer func() {
+		dc.scope = dc.scope[:len(dc.scope)-1]
+	}()
+	for _, def := range defs {
+		f, err := dc.decodeField(def)
+		if f != nil {
+			ret = append(ret, f)
+			if f.Name != "" {
+				dc.scope[len(dc.scope)-1][f.Name] = f.Value
+			}
+		}
+		if err != nil {
+			return ret, fmt.Errorf("line %d %s %s: %w", def.Line, def.Kind, def.Name, err)
+		}
+	}
+	return ret, nil
+}
+
+func (dc *decoder) decodeField(def *FieldDef) (f *Field, err error) {
+	f = &Field{
+		Name:     def.Name,
+		Kind:     def.Kind,
+		BitStart: dc.r.BitOffset,
+		InBlob:   dc.inBlob,
+	}
+	defer func() {
+		// skipped fields and not taken branches return no field
+		if f != nil {
+			f.BitEnd = dc.r.BitOffset
+		}
+	}()
+	if _, ok := numericKinds[def.Kind]; ok {
+		f.Value, err = dc.readNumeric(def.Kind, def.Size)
+		return
+	}
+	switch def.Kind {
+	case "bool":
+		f.Value, err = dc.r.ReadBit()
+	case "bits":
+		f.Value, err = ReadBitsUint(dc.r, def.Size)
+	case "varint":
+		f.Value, err = dc.r.ReadCompressed()
+	case "lenstr":
+		f.Value, err = dc.r.ReadLenStr()
+	case "bytes", "hex":
+		var b []byte
+		b, err = dc.r.ReadBytes(def.Size)
+		if def.Kind == "hex" {
+			f.Value = hex.EncodeToString(b)
+		} else {
+			f.Value = bytes.Clone(b)
+		}
+	case "skip":
+		if dc.r.BitOffset+def.Size*8 > len(dc.r.Data)*8 {
+			return f, io.ErrUnexpectedEOF
+		}
+		dc.r.IgnoreBytes(def.Size)
+		return nil, nil
+	case "align":
+		dc.r.AlignToByteBoundary()
+		return nil, nil
+	case "rest":
+		f.Value = bytes.Clone(dc.r.ReadRemaining())
+	case "if":
+		v, ok := dc.lookup(def.CondField)
+		if !ok {
+			return nil, fmt.Errorf("condition field %q is not decoded", def.CondField)
+		}
+		if (v == def.CondValue) == def.CondNeg {
+			return nil, nil
+		}
+		f.Name = ""
+		f.Children, err = dc.decodeFields(def.Children)
+	case "repeat":
+		count, cerr := strconv.ParseUint(def.Count, 0, 32)
+		if cerr != nil {
+			var ok bool
+			count, ok = dc.lookup(def.Count)
+			if !ok {
+				return f, fmt.Errorf("repeat count field %q is not decoded", def.Count)
+			}
+		}
+		if count > uint64(len(dc.r.Data)*8) {
+			return f, fmt.Errorf("repeat count %d is larger than the data", count)
+		}
+		for i := range count {
+			item := &Field{Name: strconv.Itoa(int(i)), Kind: "item", BitStart: dc.r.BitOffset, InBlob: dc.inBlob}
+			item.Children, err = dc.decodeFields(def.Children)
+			item.BitEnd = dc.r.BitOffset
+			f.Children = append(f.Children, item)
+			if err != nil {
+				return
+			}
+		}
+		f.Value = count
+	case "zstd", "lz4":
+		var blob []byte
+		blob, err = decompress(def.Kind, dc.r.ReadRemaining())
+		if err != nil {
+			return
+		}
+		f.Value = len(blob)
+		sub := &decoder{r: danet.NewBitReader(blob), inBlob: true, scope: dc.scope}
+		f.Children, err = sub.decodeFields(def.Children)
+	default:
+		err = fmt.Errorf("unknown field type %q", def.Kind)
+	}
+	return
+}
+
+func (dc *decoder) readNumeric(kind string, size int) (any, error) {
+	b, err := dc.r.ReadBytes(size)
+	if err != nil {
+		return nil, err
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	if strings.HasSuffix(kind, "be") {
+		order = binary.BigEndian
+	}
+	var u uint64
+	switch size {
+	case 1:
+		u = uint64(b[0])
+	case 2:
+		u = uint64(order.Uint16(b))
+	case 4:
+		u = uint64(order.Uint32(b))
+	case 8:
+		u = order.Uint64(b)
+	}
+	switch kind[0] {
+	case 'i':
+		shift := 64 - size*8
+		return int64(u<<shift) >> shift, nil
+	case 'f':
+		if size == 4 {
+			return float64(math.Float32frombits(uint32(u))), nil
+		}
+		return math.Float64frombits(u), nil
+	default:
+		return u, nil
+	}
+}
+
+// ReadBitsUint reads width bits as big-endian unsigned number
+func ReadBitsUint(r *danet.BitReader, width int) (uint64, error) {
+	b, err := r.ReadBits(width)
+	if err != nil {
+		return 0, err
+	}
+	// ReadBits returns whole bytes first and right-aligned remainder in the last byte
+	ret := uint64(0)
+	full := width / 8
+	for i := range full {
+		ret = ret<<8 | uint64(b[i])
+	}
+	if rem := width % 8; rem > 0 {
+		ret = ret<<rem | uint64(b[full])
+	}
+	return ret, nil
+}
+
+func decompress(kind string, data []byte) ([]byte, error) {
+	switch kind {
+	case "zstd":
+		dec, err := zstd.NewReader(nil, zstd.WithDecoderMaxMemory(maxBlobSize))
+		if err != nil {
+			return nil, err
+		}
+		defer dec.Close()
+		return dec.DecodeAll(data, nil)
+	case "lz4":
+		return uncompressLZ4(data)
+	}
+	return nil, fmt.Errorf("unknown compression %q", kind)
+}
+
+// uncompressLZ4 grows output buffer until the block fits, lz4 block does not
+// store decompressed size
+func uncompressLZ4(data []byte) ([]byte, error) {
+	size := max(len(data)*8, 64)
+	for {
+		out := make([]byte, min(size, maxBlobSize))
+		n, err := lz4.UncompressBlock(data, out)
+		if err == nil {
+			return out[:n], nil
+		}
+		if !errors.Is(err, lz4.ErrInvalidSourceShortBuffer) || len(out) >= maxBlobSize {
+			return nil, err
+		}
+		size *= 4
+	}
+}
@@ -0,0 +1,201 @@
+/*
+	wrpl: War Thunder replay parsing library (golang)
+	Copyright (C) 2025 flexcoral
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published
+	by the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package pktschema
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+)
+
+const decodeTestSchema = `
+packet sample {
+	bytes  sig 2
+	u16    le
+	u16be  be
+	i8     neg
+	f32    one
+	lenstr name
+	bits   nibble 4
+	bool   flag
+	align
+	varint v
+	if le == 0x0304 {
+		u8 yes
+	}
+	if le != 0x0304 {
+		u8 no
+	}
+	u8     n
+	repeat items n {
+		u8 x
+	}
+	hex    h 2
+	skip   1
+	zstd   blob {
+		u16 inner
+		rest tail
+	}
+}
+`
+
+func mustDecodeSchema(t *testing.T) *PacketDef {
+	s, err := Parse(decodeTestSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s.Packets[0]
+}
+
+// fieldValues flattens decoded fields to name: value, children of if
+// blocks are inlined and repeat items are named parent.index.child
+func fieldValues(fields []*Field, prefix string, ret map[string]any) map[string]any {
+	for _, f := range fields {
+		name := prefix + f.Name
+		if f.Name != "" {
+			ret[name] = f.Value
+			name += "."
+		}
+		fieldValues(f.Children, name, ret)
+	}
+	return ret
+}
+
+func TestDecode(t *testing.T) {
+	enc, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blob := enc.EncodeAll([]byte{0x07, 0x00, 0xaa}, nil)
+	enc.Close()
+	payload := []byte{
+		0x01, 0x02, // sig
+		0x04, 0x03, // le
+		0x00, 0x05, // be
+		0xff,                   // neg
+		0x00, 0x00, 0x80, 0x3f, // one
+		0x02, 'h', 'i', // name
+		0xff,       // nibble, flag, align
+		0xac, 0x02, // v = 300
+		0x09,             // yes
+		0x02, 0x0a, 0x0b, // n, items
+		0xbe, 0xef, // h
+		0x00, // skip
+	}
+	payload = append(payload, blob...)
+
+	fields, err := mustDecodeSchema(t).Decode(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := fieldValues(fields, "", map[string]any{})
+	want := map[string]any{
+		"sig":        []byte{0x01, 0x02},
+		"le":         uint64(0x0304),
+		"be":         uint64(5),
+		"neg":        int64(-1),
+		"one":        float64(1),
+		"name":       "hi",
+		"nibble":     uint64(0xf),
+		"flag":       true,
+		"v":          uint64(300),
+		"yes":        uint64(9),
+		"n":          uint64(2),
+		"items":      uint64(2),
+		"items.0":    nil,
+		"items.0.x":  uint64(0x0a),
+		"items.1":    nil,
+		"items.1.x":  uint64(0x0b),
+		"h":          "beef",
+		"blob":       3,
+		"blob.inner": uint64(7),
+		"blob.tail":  []byte{0xaa},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("decoded values\nwant %v\ngot  %v", want, got)
+	}
+
+	// spans of top level fields are bit offsets into the payload
+	for _, f := range fields {
+		if f.Name == "name" && (f.BitStart != 11*8 || f.BitEnd != 14*8) {
+			t.Errorf("name span %d..%d", f.BitStart, f.BitEnd)
+		}
+		if f.Name == "blob" && (f.InBlob || !f.Children[0].InBlob || f.Children[0].BitStart != 0 || f.Children[0].BitEnd != 16) {
+			t.Errorf("blob spans: %+v %+v", f, f.Children[0])
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	def := mustDecodeSchema(t)
+	// truncated in the middle of "be"
+	fields, err := def.Decode([]byte{0x01, 0x02, 0x04, 0x03, 0x00})
+	if err == nil || !strings.HasPrefix(err.Error(), "line 5 u16be be: ") {
+		t.Fatalf("want error at line 5, got %v", err)
+	}
+	if len(fields) != 3 || fields[1].Name != "le" {
+		t.Fatalf("fields decoded before error should be returned: %+v", fields)
+	}
+
+	for _, tc := range []struct {
+		src     string
+		payload []byte
+		err     string
+	}{
+		{"packet a {\n\tif missing == 1 {\n\t}\n}", nil, `line 2 if : condition field "missing" is not decoded`},
+		{"packet a {\n\trepeat r missing {\n\t}\n}", nil, `line 2 repeat r: repeat count field "missing" is not decoded`},
+		{"packet a {\n\tu8 n\n\trepeat r n {\n\t}\n}", []byte{0xff}, "line 3 repeat r: repeat count 255 is larger than the data"},
+		{"packet a {\n\tskip 2\n}", []byte{0x00}, "line 2 skip : unexpected EOF"},
+		{"packet a {\n\tzstd z {\n\t}\n}", []byte{0x01, 0x02}, "line 2 zstd z: "},
+		{"packet a {\n\tlz4 z {\n\t}\n}", []byte{0xff}, "line 2 lz4 z: "},
+	} {
+		s, err := Parse(tc.src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = s.Packets[0].Decode(tc.payload)
+		if err == nil || !strings.HasPrefix(err.Error(), tc.err) {
+			t.Errorf("%q: want error starting with %q, got %v", tc.src, tc.err, err)
+		}
+	}
+}
+
+func TestDecodeLZ4Growing(t *testing.T) {
+	// zeroes compress far better than the initial 8x guess
+	src := make([]byte, 64*1024)
+	blob := make([]byte, lz4.CompressBlockBound(len(src)))
+	n, err := lz4.CompressBlock(src, blob, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := Parse("packet a {\n\tlz4 z {\n\t\trest tail\n\t}\n}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields, err := s.Packets[0].Decode(blob[:n])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n*8 >= len(src) || fields[0].Value != len(src) {
+		t.Fatalf("compressed %d to %d, decoded %v bytes", len(src), n, fields[0].Value)
+	}
+}
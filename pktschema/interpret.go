@@ -0,0 +1,140 @@
+/*
+	wrpl: War Thunder replay parsing library (golang)
+	Copyright (C) 2025 flexcoral
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published
+	by the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package pktschema
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/maxsupermanhd/wrpl-inspector/danet"
+)
+
+// InterpretType is a way to read a number out of arbitrary bytes, used by
+// the byte interpreter
+type InterpretType struct {
+	Name      string
+	Size      int
+	BigEndian bool
+	// u - unsigned, i - signed, f - float, x - signed fixed point, b - bit field, v - varint
+	Kind byte
+}
+
+var InterpretTypes = []InterpretType{
+	{"uint8", 1, false, 'u'},
+	{"uint16", 2, false, 'u'},
+	{"uint32", 4, false, 'u'},
+	{"uint64", 8, false, 'u'},
+	{"int8", 1, false, 'i'},
+	{"int16", 2, false, 'i'},
+	{"int32", 4, false, 'i'},
+	{"int64", 8, false, 'i'},
+	{"float16", 2, false, 'f'},
+	{"float32", 4, false, 'f'},
+	{"float64", 8, false, 'f'},
+	{"uint16be", 2, true, 'u'},
+	{"uint32be", 4, true, 'u'},
+	{"uint64be", 8, true, 'u'},
+	{"int16be", 2, true, 'i'},
+	{"int32be", 4, true, 'i'},
+	{"int64be", 8, true, 'i'},
+	{"float16be", 2, true, 'f'},
+	{"float32be", 4, true, 'f'},
+	{"float64be", 8, true, 'f'},
+	{"fixed16", 2, false, 'x'},
+	{"fixed32", 4, false, 'x'},
+	{"bits", 0, false, 'b'},
+	{"varint", 0, false, 'v'},
+}
+
+// Decode reads value starting at bitOffset, bitWidth is used by bit fields
+// and fracBits by fixed point types
+func (t InterpretType) Decode(b []byte, bitOffset, bitWidth, fracBits int) (float64, error) {
+	r := danet.NewBitReader(b)
+	r.IgnoreBits(bitOffset)
+	switch t.Kind {
+	case 'v':
+		v, err := r.ReadCompressed()
+		return float64(v), err
+	case 'b':
+		if bitWidth <= 0 || bitWidth > 64 {
+			return 0, fmt.Errorf("bit width %d is out of range", bitWidth)
+		}
+		v, err := ReadBitsUint(r, bitWidth)
+		return float64(v), err
+	}
+	raw, err := r.ReadBytes(t.Size)
+	if err != nil {
+		return 0, err
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	if t.BigEndian {
+		order = binary.BigEndian
+	}
+	var u uint64
+	switch t.Size {
+	case 1:
+		u = uint64(raw[0])
+	case 2:
+		u = uint64(order.Uint16(raw))
+	case 4:
+		u = uint64(order.Uint32(raw))
+	case 8:
+		u = order.Uint64(raw)
+	}
+	shift := 64 - t.Size*8
+	switch t.Kind {
+	case 'i':
+		return float64(int64(u<<shift) >> shift), nil
+	case 'x':
+		return float64(int64(u<<shift)>>shift) / float64(uint64(1)<<max(0, min(62, fracBits))), nil
+	case 'f':
+		switch t.Size {
+		case 2:
+			return HalfToFloat(uint16(u)), nil
+		case 4:
+			return float64(math.Float32frombits(uint32(u))), nil
+		default:
+			return math.Float64frombits(u), nil
+		}
+	default:
+		return float64(u), nil
+	}
+}
+
+// HalfToFloat converts IEEE 754 half precision number
+func HalfToFloat(h uint16) float64 {
+	sign := 1.0
+	if h&0x8000 != 0 {
+		sign = -1
+	}
+	exp := int(h>>10) & 0x1f
+	frac := float64(h & 0x3ff)
+	switch exp {
+	case 0:
+		return sign * frac * math.Pow(2, -24)
+	case 0x1f:
+		if frac == 0 {
+			return math.Inf(int(sign))
+		}
+		return math.NaN()
+	default:
+		return sign * (1 + frac/1024) * math.Pow(2, float64(exp-15))
+	}
+}
@@ -0,0 +1,111 @@
+/*
+	wrpl: War Thunder replay parsing library (golang)
+	Copyright (C) 2025 flexcoral
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published
+	by the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package pktschema
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestHalfToFloat(t *testing.T) {
+	for _, tc := range []struct {
+		h    uint16
+		want float64
+	}{
+		{0x0000, 0},
+		{0x0001, math.Pow(2, -24)},        // smallest subnormal
+		{0x03ff, 1023 * math.Pow(2, -24)}, // largest subnormal
+		{0x0400, math.Pow(2, -14)},        // smallest normal
+		{0x3c00, 1},
+		{0x3555, 0.333251953125},
+		{0xc000, -2},
+		{0x7bff, 65504},
+		{0x7c00, math.Inf(1)},
+		{0xfc00, math.Inf(-1)},
+	} {
+		if got := HalfToFloat(tc.h); got != tc.want {
+			t.Errorf("%04x: got %v, want %v", tc.h, got, tc.want)
+		}
+	}
+	if got := HalfToFloat(0x8000); got != 0 || !math.Signbit(got) {
+		t.Errorf("8000: got %v, want -0", got)
+	}
+	for _, h := range []uint16{0x7c01, 0x7e00, 0xffff} {
+		if got := HalfToFloat(h); !math.IsNaN(got) {
+			t.Errorf("%04x: got %v, want NaN", h, got)
+		}
+	}
+}
+
+func TestInterpretTypeDecode(t *testing.T) {
+	for _, tc := range []struct {
+		typ       string
+		b         []byte
+		bitOffset int
+		bitWidth  int
+		fracBits  int
+		want      float64
+	}{
+		{"uint8", []byte{0xab, 0xcd}, 4, 0, 0, 0xbc},
+		{"uint16", []byte{0x01, 0x02}, 0, 0, 0, 0x0201},
+		{"uint16be", []byte{0x01, 0x02}, 0, 0, 0, 0x0102},
+		{"uint32", []byte{0x01, 0x02, 0x03, 0x04}, 0, 0, 0, 0x04030201},
+		{"int8", []byte{0xff}, 0, 0, 0, -1},
+		{"int16", []byte{0xfe, 0xff}, 0, 0, 0, -2},
+		{"int32be", []byte{0xff, 0xff, 0xff, 0xfd}, 0, 0, 0, -3},
+		{"int64", []byte{0xfc, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, 0, 0, 0, -4},
+		{"float16", []byte{0x00, 0x3c}, 0, 0, 0, 1},
+		{"float16be", []byte{0xc0, 0x00}, 0, 0, 0, -2},
+		{"float32", []byte{0x00, 0x00, 0x80, 0x3f}, 0, 0, 0, 1},
+		{"float64be", []byte{0x40, 0x09, 0x21, 0xfb, 0x54, 0x44, 0x2d, 0x18}, 0, 0, 0, math.Pi},
+		{"fixed16", []byte{0x80, 0x01}, 0, 0, 8, 1.5},
+		{"fixed16", []byte{0x80, 0xff}, 0, 0, 8, -0.5},
+		{"fixed16", []byte{0x05, 0x00}, 0, 0, 0, 5},
+		{"fixed32", []byte{0x00, 0x00, 0xfd, 0xff}, 0, 0, 16, -3},
+		{"fixed32", []byte{0x00, 0x40, 0x00, 0x00}, 0, 0, 16, 0.25},
+		{"bits", []byte{0xab}, 4, 4, 0, 0xb},
+		{"bits", []byte{0xab, 0xcd}, 4, 12, 0, 0xbcd},
+		{"varint", []byte{0x05}, 0, 0, 0, 5},
+		{"varint", []byte{0xac, 0x02}, 0, 0, 0, 300},
+	} {
+		typ := InterpretTypes[slices.IndexFunc(InterpretTypes, func(it InterpretType) bool { return it.Name == tc.typ })]
+		got, err := typ.Decode(tc.b, tc.bitOffset, tc.bitWidth, tc.fracBits)
+		if err != nil || got != tc.want {
+			t.Errorf("%s % x: got %v %v, want %v", tc.typ, tc.b, got, err, tc.want)
+		}
+	}
+
+	for _, tc := range []struct {
+		typ      string
+		b        []byte
+		bitWidth int
+	}{
+		{"uint32", []byte{0x01, 0x02}, 0},
+		{"bits", []byte{0x01}, 0},
+		{"bits", []byte{0x01}, 65},
+		{"bits", []byte{0x01}, 9},
+		{"varint", []byte{0x80}, 0},
+	} {
+		typ := InterpretTypes[slices.IndexFunc(InterpretTypes, func(it InterpretType) bool { return it.Name == tc.typ })]
+		if v, err := typ.Decode(tc.b, 0, tc.bitWidth, 0); err == nil {
+			t.Errorf("%s % x width %d: expected error, got %v", tc.typ, tc.b, tc.bitWidth, v)
+		}
+	}
+}
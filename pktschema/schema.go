@@ -0,0 +1,354 @@
+/*
+	wrpl: War Thunder replay parsing library (golang)
+	Copyright (C) 2025 flexcoral
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published
+	by the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// Package pktschema implements small declarative language describing packet
+// layouts, used to try out packet structure guesses without writing parsers.
+//
+//	# comment
+//	packet award {
+//		match type 4
+//		match prefix 025878f0
+//		bytes  signature 4
+//		u8     awardType
+//		hex    always003e 2
+//		u8     player
+//		skip   3
+//		lenstr awardName
+//		if awardType == 0x10 {
+//			u32 extra
+//		}
+//		rest   rem
+//	}
+//
+// Field types:
+//
+//	u8 u16 u32 u64 i8 i16 i32 i64 f32 f64   little-endian numbers, add "be" suffix for big-endian (u32be)
+//	bits NAME WIDTH                         unsigned integer of WIDTH bits
+//	bool NAME                               single bit
+//	varint NAME                             7-bit compressed integer
+//	lenstr NAME                             string prefixed with byte length
+//	bytes NAME N / hex NAME N               N raw bytes (hex shows them as string)
+//	skip N                                  ignore N bytes
+//	align                                   skip to byte boundary
+//	rest NAME                               everything that is left
+//	if FIELD ==|!= VALUE { ... }            optional block
+//	repeat NAME COUNT|FIELD { ... }         repeated block
+//	zstd NAME { ... }                       decompress rest of data with zstd and parse it
+//	lz4 NAME { ... }                        decompress rest of data as lz4 block and parse it
+//
+// Packet is matched with "match type N", "match prefix HEX" and "match regex RE"
+// (regex is applied to hex encoded payload), all match lines must pass.
+package pktschema
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+type Schema struct {
+	Packets []*PacketDef
+}
+
+type PacketDef struct {
+	Name        string
+	MatchType   []byte
+	MatchPrefix [][]byte
+	MatchRegex  []*regexp.Regexp
+	Fields      []*FieldDef
+}
+
+type FieldDef struct {
+	Line  int
+	Kind  string
+	Name  string
+	Size  int
+	Count string
+	// condition of if blocks
+	CondField string
+	CondNeg   bool
+	CondValue uint64
+	Children  []*FieldDef
+}
+
+var (
+	numericKinds = map[string]int{
+		"u8": 1, "u16": 2, "u32": 4, "u64": 8,
+		"i8": 1, "i16": 2, "i32": 4, "i64": 8,
+		"f32": 4, "f64": 8,
+		"u16be": 2, "u32be": 4, "u64be": 8,
+		"i16be": 2, "i32be": 4, "i64be": 8,
+		"f32be": 4, "f64be": 8,
+	}
+)
+
+func ParseFile(path string) (*Schema, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(string(b))
+}
+
+func Parse(src string) (*Schema, error) {
+	p := &parser{lines: strings.Split(src, "\n")}
+	ret := &Schema{}
+	for {
+		ln, toks, ok := p.next()
+		if !ok {
+			break
+		}
+		if len(toks) != 3 || toks[0] != "packet" || toks[2] != "{" {
+			return nil, fmt.Errorf("line %d: expected \"packet NAME {\"", ln)
+		}
+		def := &PacketDef{Name: toks[1]}
+		err := p.parsePacketBody(def)
+		if err != nil {
+			return nil, err
+		}
+		ret.Packets = append(ret.Packets, def)
+	}
+	return ret, nil
+}
+
+type parser struct {
+	lines []string
+	pos   int
+}
+
+func (p *parser) next() (int, []string, bool) {
+	for p.pos < len(p.lines) {
+		l := p.lines[p.pos]
+		p.pos++
+		if i := strings.Index(l, "#"); i >= 0 {
+			l = l[:i]
+		}
+		toks := strings.Fields(l)
+		if len(toks) == 0 {
+			continue
+		}
+		return p.pos, toks, true
+	}
+	return p.pos, nil, false
+}
+
+func (p *parser) parsePacketBody(def *PacketDef) error {
+	for {
+		ln, toks, ok := p.next()
+		if !ok {
+			return fmt.Errorf("packet %q: unexpected end of schema", def.Name)
+		}
+		if toks[0] == "}" {
+			return nil
+		}
+		if toks[0] == "match" {
+			if len(toks) < 3 {
+				return fmt.Errorf("line %d: match needs kind and value", ln)
+			}
+			switch toks[1] {
+			case "type":
+				v, err := strconv.ParseUint(toks[2], 0, 8)
+				if err != nil {
+					return fmt.Errorf("line %d: match type: %w", ln, err)
+				}
+				def.MatchType = append(def.MatchType, byte(v))
+			case "prefix":
+				b, err := hex.DecodeString(strings.TrimPrefix(toks[2], "^"))
+				if err != nil {
+					return fmt.Errorf("line %d: match prefix: %w", ln, err)
+				}
+				def.MatchPrefix = append(def.MatchPrefix, b)
+			case "regex":
+				re, err := regexp.Compile(strings.Join(toks[2:], " "))
+				if err != nil {
+					return fmt.Errorf("line %d: match regex: %w", ln, err)
+				}
+				def.MatchRegex = append(def.MatchRegex, re)
+			default:
+				return fmt.Errorf("line %d: unknown match kind %q", ln, toks[1])
+			}
+			continue
+		}
+		f, err := p.parseField(ln, toks)
+		if err != nil {
+			return err
+		}
+		def.Fields = append(def.Fields, f)
+	}
+}
+
+// parseBlock reads fields up to closing brace, start is line the block was opened on
+func (p *parser) parseBlock(start int) ([]*FieldDef, error) {
+	ret := []*FieldDef{}
+	for {
+		ln, toks, ok := p.next()
+		if !ok {
+			return nil, fmt.Errorf("line %d: unterminated block", start)
+		}
+		if toks[0] == "}" {
+			return ret, nil
+		}
+		f, err := p.parseField(ln, toks)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, f)
+	}
+}
+
+func (p *parser) parseField(ln int, toks []string) (*FieldDef, error) {
+	f := &FieldDef{Line: ln, Kind: toks[0]}
+	argc := func(n int) error {
+		if len(toks) != n {
+			return fmt.Errorf("line %d: %s expects %d arguments, got %d", ln, f.Kind, n-1, len(toks)-1)
+		}
+		return nil
+	}
+	parseSize := func(s string) error {
+		v, err := strconv.ParseUint(s, 0, 31)
+		if err != nil {
+			return fmt.Errorf("line %d: %s size: %w", ln, f.Kind, err)
+		}
+		f.Size = int(v)
+		return nil
+	}
+	if size, ok := numericKinds[f.Kind]; ok {
+		f.Size = size
+		if err := argc(2); err != nil {
+			return nil, err
+		}
+		f.Name = toks[1]
+		return f, nil
+	}
+	switch f.Kind {
+	case "bool", "varint", "lenstr", "rest":
+		if err := argc(2); err != nil {
+			return nil, err
+		}
+		f.Name = toks[1]
+	case "align":
+		if err := argc(1); err != nil {
+			return nil, err
+		}
+	case "skip":
+		if err := argc(2); err != nil {
+			return nil, err
+		}
+		if err := parseSize(toks[1]); err != nil {
+			return nil, err
+		}
+	case "bits", "bytes", "hex":
+		if err := argc(3); err != nil {
+			return nil, err
+		}
+		f.Name = toks[1]
+		if err := parseSize(toks[2]); err != nil {
+			return nil, err
+		}
+		if f.Kind == "bits" && (f.Size == 0 || f.Size > 64) {
+			return nil, fmt.Errorf("line %d: bits width must be within 1..64", ln)
+		}
+	case "if":
+		if err := argc(5); err != nil {
+			return nil, err
+		}
+		if toks[4] != "{" {
+			return nil, fmt.Errorf("line %d: expected \"{\" after condition", ln)
+		}
+		f.CondField = toks[1]
+		switch toks[2] {
+		case "==":
+		case "!=":
+			f.CondNeg = true
+		default:
+			return nil, fmt.Errorf("line %d: unknown condition operator %q", ln, toks[2])
+		}
+		v, err := strconv.ParseUint(toks[3], 0, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: condition value: %w", ln, err)
+		}
+		f.CondValue = v
+	case "repeat":
+		if err := argc(4); err != nil {
+			return nil, err
+		}
+		if toks[3] != "{" {
+			return nil, fmt.Errorf("line %d: expected \"{\" after repeat count", ln)
+		}
+		f.Name = toks[1]
+		f.Count = toks[2]
+	case "zstd", "lz4":
+		if err := argc(3); err != nil {
+			return nil, err
+		}
+		if toks[2] != "{" {
+			return nil, fmt.Errorf("line %d: expected \"{\" after %s name", ln, f.Kind)
+		}
+		f.Name = toks[1]
+	default:
+		return nil, fmt.Errorf("line %d: unknown field type %q", ln, f.Kind)
+	}
+	if toks[len(toks)-1] == "{" {
+		children, err := p.parseBlock(ln)
+		if err != nil {
+			return nil, err
+		}
+		f.Children = children
+	}
+	return f, nil
+}
+
+// Match returns first packet definition that matches the packet
+func (s *Schema) Match(packetType byte, payload []byte) *PacketDef {
+	if s == nil {
+		return nil
+	}
+	var hexPayload string
+	for _, def := range s.Packets {
+		if len(def.MatchType) > 0 && !slices.Contains(def.MatchType, packetType) {
+			continue
+		}
+		matched := true
+		for _, prefix := range def.MatchPrefix {
+			if len(payload) < len(prefix) || string(payload[:len(prefix)]) != string(prefix) {
+				matched = false
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+		if len(def.MatchRegex) > 0 && hexPayload == "" {
+			hexPayload = hex.EncodeToString(payload)
+		}
+		for _, re := range def.MatchRegex {
+			if !re.MatchString(hexPayload) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return def
+		}
+	}
+	return nil
+}
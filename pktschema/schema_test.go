@@ -0,0 +1,99 @@
+/*
+	wrpl: War Thunder replay parsing library (golang)
+	Copyright (C) 2025 flexcoral
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published
+	by the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package pktschema
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	s, err := Parse(`# comment
+packet award { # trailing comment
+	match type 4
+	match prefix ^025878f0
+	match regex ^02.{6}
+	u8 awardType
+	if awardType != 0x10 {
+		repeat items 2 {
+			u8 x
+		}
+	}
+}
+
+packet other {
+	rest rem
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Packets) != 2 || s.Packets[0].Name != "award" || s.Packets[1].Name != "other" {
+		t.Fatalf("packets: %+v", s.Packets)
+	}
+	award := s.Packets[0]
+	if len(award.MatchType) != 1 || award.MatchType[0] != 4 || len(award.MatchPrefix) != 1 || len(award.MatchRegex) != 1 {
+		t.Fatalf("match: %+v", award)
+	}
+	cond := award.Fields[1]
+	if cond.Kind != "if" || cond.Line != 7 || cond.CondField != "awardType" || !cond.CondNeg || cond.CondValue != 0x10 {
+		t.Fatalf("if: %+v", cond)
+	}
+	if len(cond.Children) != 1 || cond.Children[0].Count != "2" || len(cond.Children[0].Children) != 1 {
+		t.Fatalf("repeat: %+v", cond.Children)
+	}
+
+	if s.Match(4, []byte{0x02, 0x58, 0x78, 0xf0, 0x01}) != award {
+		t.Error("award does not match")
+	}
+	if s.Match(4, []byte{0x02, 0x58, 0x78}) != s.Packets[1] {
+		t.Error("short payload should fall through to other")
+	}
+	if s.Match(3, []byte{0x02, 0x58, 0x78, 0xf0, 0x01}) != s.Packets[1] {
+		t.Error("wrong type should fall through to other")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, tc := range []struct {
+		src, err string
+	}{
+		{"u8 x", `line 1: expected "packet NAME {"`},
+		{"packet a {\n\tu8 x", `packet "a": unexpected end of schema`},
+		{"packet a {\n\tmatch type\n}", "line 2: match needs kind and value"},
+		{"packet a {\n\tmatch type 300\n}", "line 2: match type"},
+		{"packet a {\n\tmatch prefix zz\n}", "line 2: match prefix"},
+		{"packet a {\n\tmatch regex (\n}", "line 2: match regex"},
+		{"packet a {\n\tmatch size 4\n}", `line 2: unknown match kind "size"`},
+		{"packet a {\n\tu8\n}", "line 2: u8 expects 1 arguments, got 0"},
+		{"packet a {\n\n\tbytes b x\n}", "line 3: bytes size"},
+		{"packet a {\n\tbits b 65\n}", "line 2: bits width must be within 1..64"},
+		{"packet a {\n\tif x >= 1 {\n\t}\n}", `line 2: unknown condition operator ">="`},
+		{"packet a {\n\tif x == y {\n\t}\n}", "line 2: condition value"},
+		{"packet a {\n\tif x == 1 (\n\t}\n}", `line 2: expected "{" after condition`},
+		{"packet a {\n\trepeat r 2 {\n\t\tu8 x\n", "line 2: unterminated block"},
+		{"packet a {\n\tzstd z\n}", "line 2: zstd expects 2 arguments, got 1"},
+		{"packet a {\n\tfloat x\n}", `line 2: unknown field type "float"`},
+	} {
+		_, err := Parse(tc.src)
+		if err == nil || !strings.HasPrefix(err.Error(), tc.err) {
+			t.Errorf("%q: want error starting with %q, got %v", tc.src, tc.err, err)
+		}
+	}
+}
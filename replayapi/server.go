@@ -0,0 +1,595 @@
+/*
+	wrpl: War Thunder replay parsing library (golang)
+	Copyright (C) 2025 flexcoral
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published
+	by the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// Package replayapi serves parsed replays as JSON over HTTP so that
+// dashboards and bots can use them without embedding Go, it backs
+// "wrpl-inspector serve". Replays are addressed by hash of their header.
+//
+//	GET  /replays                            loaded replays
+//	POST /replays                            upload replay file, raw body or multipart field "replay"
+//	GET  /replays/{hash}                     replay summary
+//	GET  /replays/{hash}/header              decoded header
+//	GET  /replays/{hash}/settings            settings BLK
+//	GET  /replays/{hash}/results             results BLK
+//	GET  /replays/{hash}/packets             packets, see below
+//	GET  /replays/{hash}/players             roster participants
+//	GET  /replays/{hash}/chat                chat log
+//	GET  /replays/{hash}/events              kills, awards, chat, spawns and deaths ordered by time
+//	GET  /replays/{hash}/lineups             vehicle lives of every player
+//	GET  /replays/{hash}/trajectories/{eid}  movement of entity
+//
+// Packets are filtered with query parameters type (name or number), from
+// and to (milliseconds or duration like 5m), query (see wrpl.ParseQuery),
+// paged with offset and limit, payload=1 adds hex encoded payloads.
+// Errors are returned as {"error": "..."} with matching status code.
+package replayapi
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/maxsupermanhd/wrpl-inspector/wrpl"
+)
+
+const (
+	DefaultMaxUploadSize = 256 << 20
+	DefaultPacketsLimit  = 1000
+	MaxPacketsLimit      = 100000
+)
+
+type replayEntry struct {
+	hash    string
+	source  string
+	rpl     *wrpl.WRPL
+	lineups []*wrpl.Lineup
+}
+
+// Server keeps loaded replays, it is safe for concurrent use
+type Server struct {
+	// Names resolve ECS component names of added replays, needed to find
+	// vehicle entities for lineups, may be nil
+	Names *wrpl.ECSNameDict
+	// MaxUploadSize limits size of uploaded replay in bytes
+	MaxUploadSize int64
+
+	lock    sync.RWMutex
+	replays map[string]*replayEntry
+	order   []string
+}
+
+func NewServer() *Server {
+	return &Server{
+		MaxUploadSize: DefaultMaxUploadSize,
+		replays:       map[string]*replayEntry{},
+	}
+}
+
+// Add makes parsed replay available under hash of its header, source is
+// shown in the replay list. Returns hash and whether replay is new.
+func (s *Server) Add(source string, rpl *wrpl.WRPL) (string, bool) {
+	hash := rpl.Header.Hash()
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, ok := s.replays[hash]; ok {
+		return hash, false
+	}
+	if s.Names != nil && rpl.Parsed != nil {
+		s.Names.ResolveECS(rpl.Parsed.ECS)
+	}
+	s.replays[hash] = &replayEntry{
+		hash:    hash,
+		source:  source,
+		rpl:     rpl,
+		lineups: wrpl.NewLineups(rpl),
+	}
+	s.order = append(s.order, hash)
+	return hash, true
+}
+
+// LoadDir adds every battle found in dir (see wrpl.LibraryBattles),
+// server replay parts are joined. Returns errors of battles that failed.
+func (s *Server) LoadDir(dir string) []error {
+	battles, err := wrpl.LibraryBattles(dir)
+	if err != nil {
+		return []error{err}
+	}
+	errs := []error{}
+	for _, b := range battles {
+		rpl, err := wrpl.ReadBattle(b)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", strings.Join(b, ", "), err))
+			continue
+		}
+		s.Add(b[0], rpl)
+	}
+	return errs
+}
+
+func (s *Server) replay(hash string) *replayEntry {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.replays[hash]
+}
+
+// Handler returns http handler with all endpoints
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /replays", s.handleList)
+	mux.HandleFunc("POST /replays", s.handleUpload)
+	mux.HandleFunc("GET /replays/{hash}", s.withReplay(func(e *replayEntry, r *http.Request) (any, error) {
+		return newReplayInfo(e), nil
+	}))
+	mux.HandleFunc("GET /replays/{hash}/header", s.withReplay(func(e *replayEntry, r *http.Request) (any, error) {
+		return newHeaderInfo(&e.rpl.Header), nil
+	}))
+	mux.HandleFunc("GET /replays/{hash}/settings", s.withReplay(func(e *replayEntry, r *http.Request) (any, error) {
+		return e.rpl.Settings, nil
+	}))
+	mux.HandleFunc("GET /replays/{hash}/results", s.withReplay(func(e *replayEntry, r *http.Request) (any, error) {
+		return e.rpl.Results, nil
+	}))
+	mux.HandleFunc("GET /replays/{hash}/packets", s.withReplay(handlePackets))
+	mux.HandleFunc("GET /replays/{hash}/players", s.withReplay(handlePlayers))
+	mux.HandleFunc("GET /replays/{hash}/chat", s.withReplay(func(e *replayEntry, r *http.Request) (any, error) {
+		return wrpl.NewChatLog(e.source, e.rpl), nil
+	}))
+	mux.HandleFunc("GET /replays/{hash}/events", s.withReplay(handleEvents))
+	mux.HandleFunc("GET /replays/{hash}/lineups", s.withReplay(handleLineups))
+	mux.HandleFunc("GET /replays/{hash}/trajectories/{eid}", s.withReplay(handleTrajectory))
+	return mux
+}
+
+// errBadRequest marks errors caused by request parameters
+var errBadRequest = errors.New("bad request")
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		status = http.StatusInternalServerError
+		b, _ = json.Marshal(map[string]string{"error": "encoding response: " + err.Error()})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(append(b, '\n'))
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// withReplay looks up replay of the request, errors wrapping errBadRequest
+// are reported as 400, others as 500
+func (s *Server) withReplay(h func(*replayEntry, *http.Request) (any, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		e := s.replay(r.PathValue("hash"))
+		if e == nil {
+			writeError(w, http.StatusNotFound, fmt.Errorf("replay %q not found", r.PathValue("hash")))
+			return
+		}
+		ret, err := h(e, r)
+		if errors.Is(err, errBadRequest) {
+			writeError(w, http.StatusBadRequest, err)
+		} else if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+		} else {
+			writeJSON(w, http.StatusOK, ret)
+		}
+	}
+}
+
+type ReplayInfo struct {
+	Hash       string    `json:"hash"`
+	Source     string    `json:"source"`
+	Session    string    `json:"session"`
+	Server     bool      `json:"server"`
+	Level      string    `json:"level"`
+	BattleType string    `json:"battleType"`
+	Start      time.Time `json:"start"`
+	DurationMs int64     `json:"durationMs"`
+	Packets    int       `json:"packets"`
+	Players    int       `json:"players"`
+}
+
+func newReplayInfo(e *replayEntry) ReplayInfo {
+	h := &e.rpl.Header
+	ret := ReplayInfo{
+		Hash:       e.hash,
+		Source:     e.source,
+		Session:    h.SessionHEX(),
+		Server:     h.IsServer(),
+		Level:      h.Level(),
+		BattleType: h.BattleType(),
+		Start:      time.Unix(int64(h.StartTime), 0).UTC(),
+		Packets:    len(e.rpl.Packets),
+	}
+	if len(e.rpl.Packets) > 0 {
+		ret.DurationMs = int64(e.rpl.Packets[len(e.rpl.Packets)-1].CurrentTime)
+	}
+	if e.rpl.Parsed != nil && e.rpl.Parsed.Roster != nil {
+		ret.Players = len(e.rpl.Parsed.Roster.Participants)
+	}
+	return ret
+}
+
+type HeaderInfo struct {
+	Version          int32     `json:"version"`
+	Level            string    `json:"level"`
+	LevelSettings    string    `json:"levelSettings"`
+	BattleType       string    `json:"battleType"`
+	Environment      string    `json:"environment"`
+	Visibility       string    `json:"visibility"`
+	Difficulty       byte      `json:"difficulty"`
+	SessionType      uint32    `json:"sessionType"`
+	Session          string    `json:"session"`
+	Server           bool      `json:"server"`
+	ReplayPartNumber byte      `json:"replayPartNumber"`
+	LocName          string    `json:"locName"`
+	Start            time.Time `json:"start"`
+	TimeLimit        uint32    `json:"timeLimit"`
+	ScoreLimit       uint32    `json:"scoreLimit"`
+	BattleClass      string    `json:"battleClass"`
+	BattleKillStreak string    `json:"battleKillStreak"`
+}
+
+func headerString(b []byte) string {
+	return string(bytes.Trim(b, "\x00"))
+}
+
+func newHeaderInfo(h *wrpl.WRPLHeader) HeaderInfo {
+	return HeaderInfo{
+		Version:          h.Version,
+		Level:            h.Level(),
+		LevelSettings:    headerString(h.Raw_LevelSettings[:]),
+		BattleType:       h.BattleType(),
+		Environment:      headerString(h.Raw_Environment[:]),
+		Visibility:       headerString(h.Raw_Visibility[:]),
+		Difficulty:       h.Difficulty,
+		SessionType:      h.SessionType,
+		Session:          h.SessionHEX(),
+		Server:           h.IsServer(),
+		ReplayPartNumber: h.ReplayPartNumber,
+		LocName:          headerString(h.Raw_LocName[:]),
+		Start:            time.Unix(int64(h.StartTime), 0).UTC(),
+		TimeLimit:        h.TimeLimit,
+		ScoreLimit:       h.ScoreLimit,
+		BattleClass:      headerString(h.Raw_BattleClass[:]),
+		BattleKillStreak: headerString(h.Raw_BattleKillStreak[:]),
+	}
+}
+
+func (s *Server) handleList(w http.ResponseWriter, r *http.Request) {
+	s.lock.RLock()
+	ret := make([]ReplayInfo, 0, len(s.order))
+	for _, h := range s.order {
+		ret = append(ret, newReplayInfo(s.replays[h]))
+	}
+	s.lock.RUnlock()
+	writeJSON(w, http.StatusOK, ret)
+}
+
+func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
+	body := http.MaxBytesReader(w, r.Body, s.MaxUploadSize)
+	source := "upload"
+	var rd io.Reader = body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		r.Body = body
+		f, fh, err := r.FormFile("replay")
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("reading multipart field \"replay\": %w", err))
+			return
+		}
+		defer f.Close()
+		source = fh.Filename
+		rd = f
+	}
+	b, err := io.ReadAll(rd)
+	if err != nil {
+		status := http.StatusBadRequest
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		writeError(w, status, fmt.Errorf("reading replay: %w", err))
+		return
+	}
+	rpl, err := wrpl.ReadWRPL(bytes.NewReader(b), true, true, true)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("parsing replay: %w", err))
+		return
+	}
+	hash, added := s.Add(source, rpl)
+	status := http.StatusOK
+	if added {
+		status = http.StatusCreated
+	}
+	w.Header().Set("Location", "/replays/"+hash)
+	writeJSON(w, status, newReplayInfo(s.replay(hash)))
+}
+
+// parseTimeParam accepts milliseconds or duration
+func parseTimeParam(r *http.Request, name string, def uint32) (uint32, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, nil
+	}
+	if n, err := strconv.ParseUint(v, 10, 32); err == nil {
+		return uint32(n), nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 || d.Milliseconds() > math.MaxUint32 {
+		return 0, fmt.Errorf("%w: %s %q is not milliseconds or duration", errBadRequest, name, v)
+	}
+	return uint32(d.Milliseconds()), nil
+}
+
+func parseIntParam(r *http.Request, name string, def int) (int, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%w: %s %q is not a non-negative number", errBadRequest, name, v)
+	}
+	return n, nil
+}
+
+type PacketInfo struct {
+	Index    int             `json:"index"`
+	Time     uint32          `json:"time"`
+	Type     byte            `json:"type"`
+	TypeName string          `json:"typeName,omitempty"`
+	Size     int             `json:"size"`
+	Payload  string          `json:"payload,omitempty"`
+	Name     string          `json:"name,omitempty"`
+	Parsed   json.RawMessage `json:"parsed,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+type PacketsPage struct {
+	Total   int          `json:"total"`
+	Offset  int          `json:"offset"`
+	Packets []PacketInfo `json:"packets"`
+}
+
+func newPacketInfo(i int, pk *wrpl.WRPLRawPacket, payload bool) PacketInfo {
+	ret := PacketInfo{
+		Index:    i,
+		Time:     pk.CurrentTime,
+		Type:     pk.PacketType,
+		TypeName: wrpl.PacketType(pk.PacketType).Name(),
+		Size:     len(pk.PacketPayload),
+	}
+	if payload {
+		ret.Payload = hex.EncodeToString(pk.PacketPayload)
+	}
+	if pk.ParseError != nil && !errors.Is(pk.ParseError, wrpl.ErrUnknownPacket) {
+		ret.Error = pk.ParseError.Error()
+	}
+	if pk.Parsed != nil {
+		ret.Name = pk.Parsed.Name
+		b, err := json.Marshal(pk.Parsed.Data)
+		if err != nil {
+			// garbage floats of unknown fields are not valid json
+			ret.Error = strings.TrimPrefix(ret.Error+"; encoding parsed data: "+err.Error(), "; ")
+		} else {
+			ret.Parsed = b
+		}
+	}
+	return ret
+}
+
+func handlePackets(e *replayEntry, r *http.Request) (any, error) {
+	q := r.URL.Query()
+	from, err := parseTimeParam(r, "from", 0)
+	if err != nil {
+		return nil, err
+	}
+	to, err := parseTimeParam(r, "to", math.MaxUint32)
+	if err != nil {
+		return nil, err
+	}
+	offset, err := parseIntParam(r, "offset", 0)
+	if err != nil {
+		return nil, err
+	}
+	limit, err := parseIntParam(r, "limit", DefaultPacketsLimit)
+	if err != nil {
+		return nil, err
+	}
+	limit = min(limit, MaxPacketsLimit)
+	match := func(*wrpl.WRPLRawPacket) bool { return true }
+	if t := q.Get("type"); t != "" {
+		pt, err := wrpl.ParsePacketType(t)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", errBadRequest, err)
+		}
+		match = func(pk *wrpl.WRPLRawPacket) bool { return wrpl.PacketType(pk.PacketType) == pt }
+	}
+	var query *wrpl.Query
+	if qs := q.Get("query"); qs != "" {
+		query, err = wrpl.ParseQuery(qs)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", errBadRequest, err)
+		}
+	}
+	payload := q.Get("payload") == "1" || q.Get("payload") == "true"
+	ret := PacketsPage{Offset: offset, Packets: []PacketInfo{}}
+	for i, pk := range e.rpl.Packets {
+		if pk.CurrentTime < from || pk.CurrentTime > to || !match(pk) || query != nil && !query.Match(pk) {
+			continue
+		}
+		if ret.Total >= offset && len(ret.Packets) < limit {
+			ret.Packets = append(ret.Packets, newPacketInfo(i, pk, payload))
+		}
+		ret.Total++
+	}
+	return ret, nil
+}
+
+type PlayerInfo struct {
+	ID       int      `json:"id"`
+	Name     string   `json:"name"`
+	ClanTag  string   `json:"clanTag,omitempty"`
+	UserID   uint32   `json:"userId,omitempty"`
+	Title    string   `json:"title,omitempty"`
+	Slots    []int    `json:"slots"`
+	Eids     []uint64 `json:"eids"`
+	Team     int      `json:"team"`
+	Squad    int      `json:"squad"`
+	Vehicles []string `json:"vehicles"`
+}
+
+func handlePlayers(e *replayEntry, r *http.Request) (any, error) {
+	ret := []PlayerInfo{}
+	if e.rpl.Parsed == nil || e.rpl.Parsed.Roster == nil {
+		return ret, nil
+	}
+	for _, p := range e.rpl.Parsed.Roster.Participants {
+		pi := PlayerInfo{
+			ID:       p.ID,
+			Name:     p.Name,
+			ClanTag:  p.ClanTag,
+			UserID:   p.UserID,
+			Title:    p.Title,
+			Slots:    []int{},
+			Eids:     slices.Clone(p.Eids),
+			Team:     p.Team,
+			Squad:    p.Squad,
+			Vehicles: slices.Clone(p.Vehicles),
+		}
+		for _, s := range p.Slots {
+			pi.Slots = append(pi.Slots, int(s))
+		}
+		ret = append(ret, pi)
+	}
+	return ret, nil
+}
+
+type Event struct {
+	Time uint32 `json:"time"`
+	// Kind is kill, award, chat, spawn or death
+	Kind    string `json:"kind"`
+	Player  string `json:"player,omitempty"`
+	Vehicle string `json:"vehicle,omitempty"`
+	Text    string `json:"text,omitempty"`
+	// Enemy is set on chat messages of the enemy team
+	Enemy bool `json:"enemy,omitempty"`
+	// Packet is index of the packet event came from, -1 for events
+	// derived from lineups
+	Packet int `json:"packet"`
+}
+
+func handleEvents(e *replayEntry, r *http.Request) (any, error) {
+	ret := []Event{}
+	// lineup events go first so that spawn stays before kills at the same time
+	for _, l := range e.lineups {
+		for _, life := range l.Lives {
+			ret = append(ret, Event{Time: life.Start, Kind: "spawn", Player: l.Participant.String(), Vehicle: life.Vehicle, Packet: -1})
+			if life.Ended == wrpl.LifeEndDestroyed {
+				ret = append(ret, Event{Time: life.End, Kind: "death", Player: l.Participant.String(), Vehicle: life.Vehicle, Packet: -1})
+			}
+		}
+	}
+	for i, pk := range e.rpl.Packets {
+		if pk.Parsed == nil {
+			continue
+		}
+		switch d := pk.Parsed.Data.(type) {
+		case wrpl.ParsedPacketKill:
+			ret = append(ret, Event{Time: pk.CurrentTime, Kind: "kill", Player: d.Killer, Vehicle: d.KillerVehicle, Packet: i})
+		case wrpl.ParsedPacketAward:
+			ret = append(ret, Event{Time: pk.CurrentTime, Kind: "award", Player: d.PlayerName, Text: d.AwardName, Packet: i})
+		case wrpl.ParsedPacketChat:
+			if pk.ParseError != nil {
+				continue
+			}
+			ret = append(ret, Event{Time: pk.CurrentTime, Kind: "chat", Player: chatSender(e.rpl, d), Text: d.Content, Enemy: d.Enemy(), Packet: i})
+		}
+	}
+	slices.SortStableFunc(ret, func(a, b Event) int {
+		return cmp.Compare(a.Time, b.Time)
+	})
+	return ret, nil
+}
+
+// chatSender names resolved sender like the roster names players of other
+// events, unresolved senders are kept as written
+func chatSender(rpl *wrpl.WRPL, c wrpl.ParsedPacketChat) string {
+	if c.Player != nil && rpl.Parsed != nil {
+		if p := rpl.Parsed.Roster.ByName(c.Player.Name); p != nil {
+			return p.String()
+		}
+	}
+	return c.Sender
+}
+
+type LineupInfo struct {
+	Player     string              `json:"player"`
+	Lives      []*wrpl.VehicleLife `json:"lives"`
+	Unobserved []string            `json:"unobserved"`
+}
+
+func handleLineups(e *replayEntry, r *http.Request) (any, error) {
+	ret := []LineupInfo{}
+	for _, l := range e.lineups {
+		ret = append(ret, LineupInfo{Player: l.Participant.String(), Lives: l.Lives, Unobserved: l.Unobserved})
+	}
+	return ret, nil
+}
+
+type TrajectoryPoint struct {
+	Time uint32  `json:"time"`
+	X    float64 `json:"x"`
+	Y    float64 `json:"y"`
+	Z    float64 `json:"z"`
+}
+
+func handleTrajectory(e *replayEntry, r *http.Request) (any, error) {
+	eid, err := strconv.ParseUint(r.PathValue("eid"), 0, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: eid %q is not a number", errBadRequest, r.PathValue("eid"))
+	}
+	ret := []TrajectoryPoint{}
+	for _, pk := range e.rpl.Packets {
+		if pk.Parsed == nil {
+			continue
+		}
+		m, ok := pk.Parsed.Data.(wrpl.ParsedPacketMovement)
+		if !ok || m.Eid != eid {
+			continue
+		}
+		if math.IsNaN(m.X+m.Y+m.Z) || math.IsInf(m.X+m.Y+m.Z, 0) {
+			continue
+		}
+		ret = append(ret, TrajectoryPoint{Time: m.Time, X: m.X, Y: m.Y, Z: m.Z})
+	}
+	return ret, nil
+}
@@ -0,0 +1,222 @@
+/*
+	wrpl: War Thunder replay parsing library (golang)
+	Copyright (C) 2025 flexcoral
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published
+	by the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package replayapi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/maxsupermanhd/wrpl-inspector/wrpl"
+)
+
+func appendLenString(b []byte, s string) []byte {
+	return append(append(b, byte(len(s))), s...)
+}
+
+// samplePlayerInit is slot message introducing player in slot 1
+func samplePlayerInit() []byte {
+	msg := []byte{0x70, 0x00, 0x01, 0x08, 0x60}
+	msg = binary.LittleEndian.AppendUint32(msg, 123456)
+	msg = binary.LittleEndian.AppendUint32(msg, 0)
+	name := make([]byte, wrpl.SlotPlayerNameSize)
+	copy(name, "RealName")
+	msg = append(msg, name...)
+	msg = append(msg, make([]byte, wrpl.SlotPlayerSkipSize)...)
+	msg = appendLenString(msg, "[TAG]")
+	msg = appendLenString(msg, "")
+	msg = append(msg, 0xaa, 0xbb)
+	body := []byte{0x02, 0x58, 0x2d, 0xf0, 0x00}
+	body = binary.LittleEndian.AppendUint16(body, 1)
+	body = binary.LittleEndian.AppendUint16(body, uint16(len(msg)+1))
+	return append(append(body, 1), msg...)
+}
+
+func sampleMovement(eid byte, x, y, z float64) []byte {
+	ret := make([]byte, 40)
+	copy(ret, []byte{0xff, 0x0f, eid, 0x00, 0x00, 0xa3, 0xf0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x14})
+	binary.LittleEndian.PutUint64(ret[14:], math.Float64bits(x))
+	binary.LittleEndian.PutUint64(ret[22:], math.Float64bits(y))
+	binary.LittleEndian.PutUint64(ret[30:], math.Float64bits(z))
+	return ret
+}
+
+func sampleReplay(t *testing.T) []byte {
+	rpl := &wrpl.WRPL{}
+	copy(rpl.Header.Magic[:], []byte{0xe5, 0xac, 0x00, 0x10})
+	copy(rpl.Header.Raw_Level[:], "levels/avg_stalingrad.bin")
+	copy(rpl.Header.Raw_BattleType[:], "domination")
+	chat := appendLenString(nil, "RealName")
+	chat = appendLenString(chat, "hello")
+	chat = append(chat, 0x01, 0x01) // team channel, enemy
+	kill := append([]byte{0x02, 0x58, 0x58, 0xf0, 0x10, 0x00, 0xfe, 0x3f, 0x01, 0x00, 0x00, 0x00}, appendLenString(nil, "germ_tiger")...)
+	rpl.Packets = []*wrpl.WRPLRawPacket{
+		{CurrentTime: 0, PacketType: byte(wrpl.PacketTypeMPI), PacketPayload: samplePlayerInit()},
+		{CurrentTime: 100, PacketType: byte(wrpl.PacketTypeMPI), PacketPayload: sampleMovement(5, 1, 2, 3)},
+		{CurrentTime: 200, PacketType: byte(wrpl.PacketTypeChat), PacketPayload: chat},
+		{CurrentTime: 300, PacketType: byte(wrpl.PacketTypeMPI), PacketPayload: sampleMovement(5, 4, 5, 6)},
+		{CurrentTime: 350, PacketType: byte(wrpl.PacketTypeMPI), PacketPayload: sampleMovement(5, math.NaN(), 0, 0)},
+		{CurrentTime: 400, PacketType: byte(wrpl.PacketTypeMPI), PacketPayload: kill},
+	}
+	b, err := wrpl.WriteWRPL(rpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func get(t *testing.T, srv *httptest.Server, path string, status int, ret any) {
+	t.Helper()
+	resp, err := http.Get(srv.URL + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != status {
+		t.Fatalf("GET %s: want status %d, got %d", path, status, resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(ret); err != nil {
+		t.Fatalf("GET %s: %v", path, err)
+	}
+}
+
+func upload(t *testing.T, srv *httptest.Server, contentType string, body []byte, status int) ReplayInfo {
+	t.Helper()
+	resp, err := http.Post(srv.URL+"/replays", contentType, bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != status {
+		t.Fatalf("upload: want status %d, got %d", status, resp.StatusCode)
+	}
+	ret := ReplayInfo{}
+	if err := json.NewDecoder(resp.Body).Decode(&ret); err != nil {
+		t.Fatal(err)
+	}
+	return ret
+}
+
+func TestServer(t *testing.T) {
+	srv := httptest.NewServer(NewServer().Handler())
+	defer srv.Close()
+	sample := sampleReplay(t)
+
+	info := upload(t, srv, "application/octet-stream", sample, http.StatusCreated)
+	if info.Level != "levels/avg_stalingrad.bin" || info.BattleType != "domination" || info.Packets != 6 || info.Players != 1 || info.DurationMs != 400 {
+		t.Fatalf("replay info: %+v", info)
+	}
+	mp := &bytes.Buffer{}
+	mw := multipart.NewWriter(mp)
+	fw, err := mw.CreateFormFile("replay", "sample.wrpl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write(sample)
+	mw.Close()
+	if again := upload(t, srv, mw.FormDataContentType(), mp.Bytes(), http.StatusOK); again.Hash != info.Hash {
+		t.Fatalf("same replay got different hash %s", again.Hash)
+	}
+	errResp := map[string]string{}
+	get(t, srv, "/replays/nope/header", http.StatusNotFound, &errResp)
+	if errResp["error"] == "" {
+		t.Fatal("no error message")
+	}
+
+	list := []ReplayInfo{}
+	get(t, srv, "/replays", http.StatusOK, &list)
+	if len(list) != 1 || list[0].Source != "upload" {
+		t.Fatalf("list: %+v", list)
+	}
+	base := "/replays/" + info.Hash
+
+	header := HeaderInfo{}
+	get(t, srv, base+"/header", http.StatusOK, &header)
+	if header.Level != info.Level || header.Server {
+		t.Fatalf("header: %+v", header)
+	}
+
+	page := PacketsPage{}
+	get(t, srv, base+"/packets?type=mpi&from=100&to=350ms&limit=1&offset=1&payload=1", http.StatusOK, &page)
+	if page.Total != 3 || len(page.Packets) != 1 || page.Packets[0].Index != 3 || page.Packets[0].Name != "movement" || page.Packets[0].Payload == "" {
+		t.Fatalf("packets: %+v", page)
+	}
+	get(t, srv, base+"/packets?query=name%3Dkill", http.StatusOK, &page)
+	if page.Total != 1 || page.Packets[0].TypeName != "mpi" || page.Packets[0].Parsed == nil {
+		t.Fatalf("query: %+v", page)
+	}
+	get(t, srv, base+"/packets?type=nope", http.StatusBadRequest, &errResp)
+
+	players := []PlayerInfo{}
+	get(t, srv, base+"/players", http.StatusOK, &players)
+	if len(players) != 1 || players[0].Name != "RealName" || players[0].UserID != 123456 || len(players[0].Slots) != 1 || players[0].Slots[0] != 1 {
+		t.Fatalf("players: %+v", players)
+	}
+
+	chat := struct {
+		Messages []struct{ Sender, Content, Channel string }
+	}{}
+	get(t, srv, base+"/chat", http.StatusOK, &chat)
+	if len(chat.Messages) != 1 || chat.Messages[0].Content != "hello" || chat.Messages[0].Channel != "team" {
+		t.Fatalf("chat: %+v", chat)
+	}
+
+	events := []Event{}
+	get(t, srv, base+"/events", http.StatusOK, &events)
+	want := []Event{
+		{Time: 200, Kind: "chat", Player: "[TAG] RealName", Text: "hello", Enemy: true, Packet: 2},
+		{Time: 400, Kind: "spawn", Player: "[TAG] RealName", Vehicle: "germ_tiger", Packet: -1},
+		{Time: 400, Kind: "kill", Player: "[TAG] RealName", Vehicle: "germ_tiger", Packet: 5},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("events: %+v", events)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Errorf("event %d: want %+v got %+v", i, want[i], events[i])
+		}
+	}
+
+	trajectory := []TrajectoryPoint{}
+	get(t, srv, base+"/trajectories/5", http.StatusOK, &trajectory)
+	if len(trajectory) != 2 || trajectory[1] != (TrajectoryPoint{Time: 300, X: 4, Y: 5, Z: 6}) {
+		t.Fatalf("trajectory: %+v", trajectory)
+	}
+	get(t, srv, base+"/trajectories/x", http.StatusBadRequest, &errResp)
+}
+
+func TestUploadLimit(t *testing.T) {
+	s := NewServer()
+	s.MaxUploadSize = 16
+	srv := httptest.NewServer(s.Handler())
+	defer srv.Close()
+	resp, err := http.Post(srv.URL+"/replays", "application/octet-stream", bytes.NewReader(sampleReplay(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Fatalf("want status 413, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,74 @@
+/*
+	wrpl-inspector: War Thunder replay inspection software
+	Copyright (C) 2025 flexcoral
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published
+	by the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/maxsupermanhd/wrpl-inspector/replayapi"
+	"github.com/maxsupermanhd/wrpl-inspector/wrpl"
+	"github.com/rs/zerolog/log"
+)
+
+// runServe runs "wrpl-inspector serve", see replayapi package for endpoints
+func runServe(args []string) {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := flags.String("addr", "127.0.0.1:8080", "address to listen on")
+	maxUpload := flags.Int64("max-upload", replayapi.DefaultMaxUploadSize, "max size of uploaded replay in bytes")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: wrpl-inspector serve [flags] [replay.wrpl|replay-dir...]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	s := replayapi.NewServer()
+	s.Names = ecsNameDict
+	s.MaxUploadSize = *maxUpload
+	for _, p := range flags.Args() {
+		st, err := os.Stat(p)
+		if err != nil {
+			log.Err(err).Str("path", p).Msg("loading replays")
+			continue
+		}
+		if st.IsDir() {
+			for _, err := range s.LoadDir(p) {
+				log.Err(err).Str("path", p).Msg("loading replays")
+			}
+			continue
+		}
+		b, err := os.ReadFile(p)
+		if err != nil {
+			log.Err(err).Str("path", p).Msg("loading replay")
+			continue
+		}
+		rpl, err := wrpl.ReadWRPL(bytes.NewReader(b), true, true, true)
+		if err != nil {
+			log.Err(err).Str("path", p).Msg("loading replay")
+			continue
+		}
+		s.Add(p, rpl)
+	}
+	log.Info().Str("addr", *addr).Msg("serving replays")
+	log.Err(http.ListenAndServe(*addr, s.Handler())).Msg("serving replays")
+	os.Exit(1)
+}
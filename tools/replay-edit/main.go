@@ -2,23 +2,138 @@ package main
 
 import (
 	"bytes"
-	"encoding/hex"
+	"flag"
+	"fmt"
 	"os"
+	"strings"
 
 	"github.com/maxsupermanhd/wrpl-inspector/wrpl"
 )
 
+// listFlag collects repeated or comma separated values
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(s string) error {
+	*l = append(*l, strings.Split(s, ",")...)
+	return nil
+}
+
+var (
+	from        = flag.Duration("from", 0, "drop packets before this time")
+	to          = flag.Duration("to", 0, "drop packets from this time on (0 for till the end)")
+	shift       = flag.Duration("shift", 0, "add to time of every packet (can be negative)")
+	rebase      = flag.Bool("rebase", false, "shift time so that the window starts at zero")
+	dropQuery   = flag.String("drop", "", "drop packets matching the query")
+	dropResults = flag.Bool("drop-results", false, "remove results blk")
+	exact       = flag.Bool("exact", false, "keep original encoding of packets (single file only)")
+	anonymize   = flag.Bool("anonymize", false, "replace player names, user ids, clan tags and chat with pseudonyms")
+	mapping     = flag.String("mapping", "", "anonymization mapping file, loaded if it exists and saved after (implies -anonymize)")
+	keepChat    = flag.Bool("keep-chat", false, "keep chat messages when anonymizing (known names are still replaced)")
+	keepTypes   listFlag
+	dropTypes   listFlag
+	dropSigs    listFlag
+)
+
 func main() {
-	f := noerr(os.ReadFile("/home/max/.var/app/com.valvesoftware.Steam/.local/share/Steam/steamapps/common/War Thunder/Replays/2025.09.30 02.48.20.wrpl"))
-	rpl := noerr(wrpl.ReadWRPL(bytes.NewReader(f), true, true, true))
+	flag.Var(&keepTypes, "keep-type", "packet types kept outside of the time window (name or number, repeatable)")
+	flag.Var(&dropTypes, "drop-type", "packet types to drop (name or number, repeatable)")
+	flag.Var(&dropSigs, "drop-sig", "packet signatures to drop as type:hexprefix, e.g. mpi:025873f0 (repeatable)")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: replay-edit [flags] replay.wrpl|session-folder out.wrpl")
+		fmt.Fprintln(os.Stderr, "session folder is concatenated into one standalone replay")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	rpl := noerr(openEditable(flag.Arg(0)))
+	before := len(rpl.Packets)
 
-	out := noerr(wrpl.WriteWRPL(rpl))
+	keep := []wrpl.PacketSignature{}
+	for _, t := range keepTypes {
+		keep = append(keep, wrpl.PacketSignature{Type: noerr(wrpl.ParsePacketType(t))})
+	}
+	drop := []func(*wrpl.WRPLRawPacket) bool{}
+	for _, t := range dropTypes {
+		drop = append(drop, wrpl.PacketSignature{Type: noerr(wrpl.ParsePacketType(t))}.Match)
+	}
+	for _, s := range dropSigs {
+		drop = append(drop, noerr(wrpl.ParsePacketSignature(s)).Match)
+	}
+	if *dropQuery != "" {
+		drop = append(drop, noerr(wrpl.ParseQuery(*dropQuery)).Match)
+	}
 
-	must(os.WriteFile("out.wrpl", out, 0644))
+	if *from > 0 || *to > 0 {
+		rpl.Packets = wrpl.TrimPackets(rpl.Packets, uint32(from.Milliseconds()), uint32(to.Milliseconds()), func(pk *wrpl.WRPLRawPacket) bool {
+			for _, s := range keep {
+				if s.Match(pk) {
+					return true
+				}
+			}
+			return false
+		})
+	}
+	rpl.Packets = wrpl.DropPackets(rpl.Packets, func(pk *wrpl.WRPLRawPacket) bool {
+		for _, d := range drop {
+			if d(pk) {
+				return true
+			}
+		}
+		return false
+	})
+	delta := shift.Milliseconds()
+	if *rebase {
+		delta -= from.Milliseconds()
+	}
+	wrpl.ShiftTime(rpl.Packets, delta)
+	if *dropResults {
+		rpl.DropResults()
+	}
+	if *anonymize || *mapping != "" {
+		a := wrpl.NewAnonymizer()
+		if *mapping != "" {
+			a = noerr(wrpl.LoadAnonymizer(*mapping))
+		}
+		a.KeepChatContent = *keepChat
+		must(a.Anonymize(rpl))
+		if *mapping != "" {
+			must(a.Save(*mapping))
+		}
+	}
+
+	out := noerr(wrpl.WriteWRPL(rpl))
+	must(os.WriteFile(flag.Arg(1), out, 0644))
+	fmt.Printf("%s: %d of %d packets, %d bytes\n", flag.Arg(1), len(rpl.Packets), before, len(out))
+}
 
-	buf := &bytes.Buffer{}
-	must(wrpl.WritePackets(buf, rpl.Packets))
-	must(os.WriteFile("packets_rebuilt.bin", []byte(hex.Dump(buf.Bytes())), 0644))
+// openEditable joins session folders with ConcatParts so they can be written
+// back as one replay, -exact keeps original encoding of a single file
+func openEditable(p string) (*wrpl.WRPL, error) {
+	st, err := os.Stat(p)
+	if err != nil {
+		return nil, err
+	}
+	if st.IsDir() {
+		if *exact {
+			return nil, fmt.Errorf("-exact can't be used with session folders")
+		}
+		return wrpl.ConcatPartsFolder(p)
+	}
+	if *exact {
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		return wrpl.ReadWRPLExact(bytes.NewReader(b))
+	}
+	return wrpl.OpenReplay(p)
 }
 
 func must(err error) {
@@ -31,8 +146,3 @@ func noerr[T any](ret T, err error) T {
 	must(err)
 	return ret
 }
-
-func noerr2[T, T2 any](ret T, ret2 T2, err error) (T, T2) {
-	must(err)
-	return ret, ret2
-}
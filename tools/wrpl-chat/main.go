@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/maxsupermanhd/wrpl-inspector/wrpl"
+)
+
+var (
+	format = flag.String("format", "text", "output format: "+strings.Join(wrpl.ChatExportFormats, ", "))
+	output = flag.String("o", "", "output file (stdout if empty)")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: wrpl-chat [flags] replay.wrpl|session-folder...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	logs := []wrpl.ChatLog{}
+	for _, p := range flag.Args() {
+		rpl, err := wrpl.OpenReplay(p)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", p, err)
+			continue
+		}
+		logs = append(logs, wrpl.NewChatLog(p, rpl))
+	}
+	out := os.Stdout
+	if *output != "" {
+		out = noerr(os.Create(*output))
+		defer out.Close()
+	}
+	w := bufio.NewWriter(out)
+	must(wrpl.ExportChat(w, *format, logs))
+	must(w.Flush())
+}
+
+func must(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
+
+func noerr[T any](ret T, err error) T {
+	must(err)
+	return ret
+}
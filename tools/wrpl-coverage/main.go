@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/maxsupermanhd/wrpl-inspector/wrpl"
+)
+
+var (
+	outputJSON = flag.Bool("json", false, "print coverage as json")
+	sigLength  = flag.Int("sig", wrpl.DefaultCoverageSignatureLength, "signature length in bytes")
+	examples   = flag.Int("examples", 4, "example packet indices per signature")
+	top        = flag.Int("top", 30, "max signatures to print per replay (0 for all)")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: wrpl-coverage [flags] replay.wrpl|session-folder...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	total, parsed := 0, 0
+	for _, p := range flag.Args() {
+		rpl, err := wrpl.OpenReplay(p)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", p, err)
+			continue
+		}
+		c := wrpl.AnalyzeMPICoverage(rpl.Packets, *sigLength, *examples)
+		total += c.Total
+		parsed += c.Parsed
+		if *outputJSON {
+			must(json.NewEncoder(os.Stdout).Encode(map[string]any{
+				"replay":   p,
+				"percent":  c.Percent(),
+				"coverage": c,
+			}))
+			continue
+		}
+		fmt.Printf("%s: %d MPI packets, %d parsed, %d failed, %d unknown, coverage %.1f%%\n",
+			p, c.Total, c.Parsed, c.Failed, c.Unparsed(), c.Percent())
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "signature\tcount\tparsed\tfailed\tunknown\tlen min/avg/max\tcommon lengths\texamples\tnames/hint")
+		clusters := c.Clusters
+		if *top > 0 {
+			clusters = clusters[:min(len(clusters), *top)]
+		}
+		for _, cl := range clusters {
+			lengths := []string{}
+			for _, l := range cl.Lengths[:min(len(cl.Lengths), 4)] {
+				lengths = append(lengths, fmt.Sprintf("%dx%d", l.Length, l.Count))
+			}
+			ex := []string{}
+			for _, e := range cl.Examples {
+				ex = append(ex, fmt.Sprint(e))
+			}
+			names := strings.Join(cl.Names, ",")
+			if cl.Hint != "" {
+				names = strings.TrimPrefix(names+" ("+cl.Hint+")", " ")
+			}
+			fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%d/%.1f/%d\t%s\t%s\t%s\n",
+				cl.Signature, cl.Count, cl.Parsed, cl.Failed, cl.Unparsed(),
+				cl.MinLength, cl.AvgLength, cl.MaxLength,
+				strings.Join(lengths, " "), strings.Join(ex, ","),
+				names)
+		}
+		must(w.Flush())
+		if len(clusters) < len(c.Clusters) {
+			fmt.Printf("... %d more signatures\n", len(c.Clusters)-len(clusters))
+		}
+		fmt.Println()
+	}
+	if !*outputJSON && flag.NArg() > 1 && total > 0 {
+		fmt.Printf("total: %d MPI packets, %d parsed, coverage %.1f%%\n", total, parsed, float64(parsed)/float64(total)*100)
+	}
+}
+
+func must(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/maxsupermanhd/wrpl-inspector/wrpl"
+)
+
+var (
+	outputJSON = flag.Bool("json", false, "print matching packets as json")
+	maxPayload = flag.Int("payload", 64, "max payload bytes to print")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: wrpl-query [flags] QUERY replay.wrpl|session-folder...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() < 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	q, err := wrpl.ParseQuery(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	for _, p := range flag.Args()[1:] {
+		rpl, err := wrpl.OpenReplay(p)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", p, err)
+			continue
+		}
+		for i, pk := range rpl.Packets {
+			if !q.Match(pk) {
+				continue
+			}
+			if *outputJSON {
+				b, err := json.Marshal(map[string]any{
+					"replay": p,
+					"index":  i,
+					"packet": pk,
+				})
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "%s: packet %d: %v\n", p, i, err)
+					continue
+				}
+				fmt.Printf("%s\n", b)
+				continue
+			}
+			name := ""
+			if pk.Parsed != nil {
+				name = pk.Parsed.Name
+			}
+			payload := pk.PacketPayload[:min(len(pk.PacketPayload), *maxPayload)]
+			fmt.Printf("%s\t%d\t%s\t%d\t%s\t%s\n", p, i, pk.Time(), pk.PacketType, name, hex.EncodeToString(payload))
+		}
+	}
+}
+
+func must(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/maxsupermanhd/wrpl-inspector/wrpl"
+)
+
+var (
+	csvDir  = flag.String("csv", "", "write every report as csv file into this directory instead of printing")
+	report  = flag.String("report", "", "print only report with this name (summary, maps, battle types, vehicles, players, killer vehicles, kd over time)")
+	top     = flag.Int("top", 20, "max rows to print per report (0 for all)")
+	workers = flag.Int("workers", 0, "parsing workers (GOMAXPROCS if 0)")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: wrpl-stats [flags] replay-dir...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	battles := [][]string{}
+	for _, p := range flag.Args() {
+		b, err := wrpl.LibraryBattles(p)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", p, err)
+		}
+		battles = append(battles, b...)
+	}
+	stats := wrpl.NewStats()
+	must(wrpl.AggregateLibrary(context.Background(), battles, *workers, func(r wrpl.BattleReport) {
+		if r.Err != nil {
+			stats.Failed++
+			fmt.Fprintf(os.Stderr, "%s: %v\n", strings.Join(r.Paths, ", "), r.Err)
+			return
+		}
+		stats.Add(r.Summary)
+	}))
+	tables := stats.Tables()
+	if *report != "" {
+		tables = slices.DeleteFunc(tables, func(t *wrpl.StatsTable) bool { return t.Name != *report })
+		if len(tables) == 0 {
+			fmt.Fprintf(os.Stderr, "no report named %q\n", *report)
+			os.Exit(2)
+		}
+	}
+	if *csvDir != "" {
+		must(os.MkdirAll(*csvDir, 0755))
+		for _, t := range tables {
+			f := noerr(os.Create(filepath.Join(*csvDir, strings.ReplaceAll(t.Name, " ", "_")+".csv")))
+			must(t.WriteCSV(f))
+			must(f.Close())
+		}
+		return
+	}
+	for _, t := range tables {
+		fmt.Printf("%s:\n", t.Name)
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, strings.Join(t.Columns, "\t"))
+		rows := t.Rows
+		if *top > 0 {
+			rows = rows[:min(len(rows), *top)]
+		}
+		for _, r := range rows {
+			fmt.Fprintln(w, strings.Join(r, "\t"))
+		}
+		must(w.Flush())
+		if len(rows) < len(t.Rows) {
+			fmt.Printf("... %d more rows\n", len(t.Rows)-len(rows))
+		}
+		fmt.Println()
+	}
+}
+
+func must(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
+
+func noerr[T any](ret T, err error) T {
+	must(err)
+	return ret
+}
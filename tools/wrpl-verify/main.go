@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/maxsupermanhd/wrpl-inspector/wrpl"
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: wrpl-verify replay.wrpl|folder...")
+		fmt.Fprintln(os.Stderr, "reads every replay exactly, writes it back and reports first divergent byte")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	failed := false
+	for _, arg := range flag.Args() {
+		for _, p := range replayFiles(arg) {
+			b, err := os.ReadFile(p)
+			if err != nil {
+				fmt.Printf("%s: %v\n", p, err)
+				failed = true
+				continue
+			}
+			report, err := wrpl.VerifyRoundTrip(b)
+			if err != nil {
+				fmt.Printf("%s: %v\n", p, err)
+				failed = true
+				continue
+			}
+			fmt.Printf("%s: %s\n", p, report)
+			if !report.Identical() {
+				failed = true
+			}
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// replayFiles expands folders to .wrpl files inside of them
+func replayFiles(p string) []string {
+	st, err := os.Stat(p)
+	if err != nil || !st.IsDir() {
+		return []string{p}
+	}
+	ret := []string{}
+	must(filepath.WalkDir(p, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && strings.HasSuffix(path, ".wrpl") {
+			ret = append(ret, path)
+		}
+		return nil
+	}))
+	return ret
+}
+
+func must(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
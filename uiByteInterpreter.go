@@ -0,0 +1,337 @@
+/*
+	wrpl-inspector: War Thunder replay inspection software
+	Copyright (C) 2025 flexcoral
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published
+	by the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+
+	"github.com/AllenDang/cimgui-go/imgui"
+	"github.com/AllenDang/cimgui-go/implot"
+	"github.com/maxsupermanhd/wrpl-inspector/pktschema"
+)
+
+var (
+	beInterpretTypes      = pktschema.InterpretTypes
+	beInterpretTypeNames  = []string{}
+	beInterpretTypeFloat4 = int32(9)
+)
+
+func init() {
+	for _, t := range beInterpretTypes {
+		beInterpretTypeNames = append(beInterpretTypeNames, t.Name)
+	}
+}
+
+// beSeries is interpretation of one regex capture group
+type beSeries struct {
+	Group     string
+	Type      int32
+	BitOffset int32
+	BitWidth  int32
+	FracBits  int32
+	// values of this series are used as X axis for other series instead of packet time
+	IsX bool
+
+	plotX  []float32
+	plotY  []float32
+	errors int
+}
+
+type beRow struct {
+	time   uint32
+	values []string
+	raw    string
+}
+
+type uiByteInterpreterData struct {
+	beProcessed     bool
+	beFirstFit      bool
+	beFilter        string
+	beFilterRegex   *regexp.Regexp
+	beFilterErr     error
+	beSeries        []*beSeries
+	beRows          []beRow
+	bePlotIsScatter bool
+	beShowTable     bool
+}
+
+// syncSeries makes series list follow capture groups of the filter,
+// settings of groups with the same name are kept
+func (dat *uiByteInterpreterData) syncSeries() {
+	prev := map[string]*beSeries{}
+	for _, s := range dat.beSeries {
+		prev[s.Group] = s
+	}
+	dat.beSeries = []*beSeries{}
+	for i, name := range dat.beFilterRegex.SubexpNames() {
+		if i == 0 {
+			continue
+		}
+		if name == "" {
+			name = strconv.Itoa(i)
+		}
+		s, ok := prev[name]
+		if !ok {
+			s = &beSeries{Group: name, Type: beInterpretTypeFloat4, BitWidth: 8}
+		}
+		dat.beSeries = append(dat.beSeries, s)
+	}
+}
+
+func beDecode(s *beSeries, b []byte) (float64, error) {
+	if s.Type < 0 || int(s.Type) >= len(beInterpretTypes) {
+		return 0, errors.ErrUnsupported
+	}
+	return beInterpretTypes[s.Type].Decode(b, int(s.BitOffset), int(s.BitWidth), int(s.FracBits))
+}
+
+func genByteInterp(rpl *parsedReplay) error {
+	dat := rpl.beData
+	dat.beRows = nil
+	dat.beFilterRegex, dat.beFilterErr = regexp.Compile(dat.beFilter)
+	if dat.beFilterErr != nil {
+		return dat.beFilterErr
+	}
+	dat.syncSeries()
+	var xSeries *beSeries
+	for _, s := range dat.beSeries {
+		s.plotX = []float32{}
+		s.plotY = []float32{}
+		s.errors = 0
+		if s.IsX && xSeries == nil {
+			xSeries = s
+		}
+	}
+	dat.beRows = []beRow{}
+	for _, pk := range rpl.Replay.Packets {
+		hexpayload := hex.EncodeToString(pk.PacketPayload)
+		matches := dat.beFilterRegex.FindStringSubmatchIndex(hexpayload)
+		if matches == nil {
+			continue
+		}
+		row := beRow{time: pk.CurrentTime, raw: hexpayload, values: make([]string, len(dat.beSeries))}
+		vals := make([]float64, len(dat.beSeries))
+		ok := make([]bool, len(dat.beSeries))
+		for i, s := range dat.beSeries {
+			start, end := matches[(i+1)*2], matches[(i+1)*2+1]
+			if start < 0 {
+				continue
+			}
+			var b []byte
+			var err error
+			if (end-start)%2 != 0 {
+				// padding half byte would shift the value differently
+				// depending on endianness, use bit offset and bits type instead
+				err = fmt.Errorf("group captured odd number of nibbles (%d)", end-start)
+			} else {
+				b, err = hex.DecodeString(hexpayload[start:end])
+			}
+			if err == nil {
+				vals[i], err = beDecode(s, b)
+			}
+			if err != nil {
+				s.errors++
+				row.values[i] = err.Error()
+				continue
+			}
+			ok[i] = true
+			row.values[i] = strconv.FormatFloat(vals[i], 'g', -1, 64)
+		}
+		dat.beRows = append(dat.beRows, row)
+		x := float64(pk.CurrentTime)
+		if xSeries != nil {
+			xi := slices.Index(dat.beSeries, xSeries)
+			if !ok[xi] {
+				continue
+			}
+			x = vals[xi]
+		}
+		for i, s := range dat.beSeries {
+			if s == xSeries || !ok[i] {
+				continue
+			}
+			s.plotX = append(s.plotX, float32(x))
+			s.plotY = append(s.plotY, float32(vals[i]))
+		}
+	}
+	return nil
+}
+
+func uiShowReplayPacketByteInterpreter(rpl *parsedReplay) {
+	dat := rpl.beData
+	if !dat.beProcessed {
+		// ^00035843d03f00fe01(....)
+		// ^00ff0f81(........)
+		dat.beProcessed = true
+		dat.beFirstFit = true
+		dat.beFilterErr = genByteInterp(rpl)
+	}
+	if imgui.InputTextWithHint("regex filter", "", &dat.beFilter, 0, func(data imgui.InputTextCallbackData) int {
+		dat.beProcessed = false
+		return 0
+	}) {
+		dat.beProcessed = false
+	}
+	imgui.SameLine()
+	uiHelpMarker("Every capture group is a separate series, name groups with (?P<name>...) to keep their settings when regex changes")
+	uiShowByteInterpreterPresets(dat)
+	if dat.beFilterErr != nil {
+		imgui.TextUnformatted("Error: " + dat.beFilterErr.Error())
+		return
+	}
+	uiShowByteInterpreterSeries(dat)
+	if imgui.Button("reprocess") {
+		dat.beProcessed = false
+	}
+	imgui.SameLine()
+	imgui.Checkbox("isScatter", &dat.bePlotIsScatter)
+	imgui.SameLine()
+	imgui.Checkbox("showTable", &dat.beShowTable)
+	imgui.SameLine()
+	imgui.TextUnformatted(fmt.Sprintf("%d samples", len(dat.beRows)))
+	if len(dat.beRows) == 0 {
+		imgui.TextUnformatted("0 len")
+		return
+	}
+	if dat.beFirstFit {
+		dat.beFirstFit = false
+		implot.SetNextAxesToFit()
+	}
+	size := imgui.Vec2{X: -1, Y: -1}
+	if dat.beShowTable {
+		size = imgui.Vec2{X: -1, Y: 0}
+	}
+	if implot.BeginPlotV("##da values plot", size, 0) {
+		for _, s := range dat.beSeries {
+			if s.IsX || len(s.plotX) == 0 {
+				continue
+			}
+			if dat.bePlotIsScatter {
+				implot.PlotScatterFloatPtrFloatPtr(s.Group, &s.plotX[0], &s.plotY[0], int32(len(s.plotX)))
+			} else {
+				implot.PlotLineFloatPtrFloatPtr(s.Group, &s.plotX[0], &s.plotY[0], int32(len(s.plotX)))
+			}
+		}
+		implot.EndPlot()
+	}
+	if dat.beShowTable && imgui.BeginChildStr("values table child") {
+		tableFlags := imgui.TableFlagsRowBg | imgui.TableFlagsBordersV | imgui.TableFlagsBordersOuterH | imgui.TableFlagsSizingFixedFit | imgui.TableFlagsScrollY | imgui.TableFlagsScrollX
+		if imgui.BeginTableV("values table", int32(len(dat.beSeries)+2), tableFlags, imgui.Vec2{}, 0.0) {
+			imgui.TableSetupScrollFreeze(0, 1)
+			imgui.TableSetupColumn("time")
+			for _, s := range dat.beSeries {
+				imgui.TableSetupColumn(s.Group)
+			}
+			imgui.TableSetupColumn("packet")
+			imgui.TableHeadersRow()
+			clipper := imgui.NewListClipper()
+			clipper.Begin(int32(len(dat.beRows)))
+			for clipper.Step() {
+				for i := clipper.DisplayStart(); i < clipper.DisplayEnd(); i++ {
+					row := dat.beRows[i]
+					imgui.TableNextRow()
+					imgui.TableNextColumn()
+					imgui.TextUnformatted(strconv.Itoa(int(row.time)))
+					uiTableRowStrings(row.values...)
+					imgui.TableNextColumn()
+					imgui.TextUnformatted(row.raw)
+				}
+			}
+			clipper.End()
+			imgui.EndTable()
+		}
+		imgui.EndChild()
+	}
+}
+
+func uiShowByteInterpreterSeries(dat *uiByteInterpreterData) {
+	if len(dat.beSeries) == 0 {
+		imgui.TextUnformatted("regex has no capture groups")
+		return
+	}
+	tableFlags := imgui.TableFlagsRowBg | imgui.TableFlagsBordersV | imgui.TableFlagsBordersOuterH | imgui.TableFlagsSizingFixedFit
+	if !imgui.BeginTableV("##series", 7, tableFlags, imgui.Vec2{}, 0) {
+		return
+	}
+	imgui.TableSetupColumn("group")
+	imgui.TableSetupColumn("type")
+	imgui.TableSetupColumn("bit offset")
+	imgui.TableSetupColumn("bit width")
+	imgui.TableSetupColumn("fraction bits")
+	imgui.TableSetupColumn("X axis")
+	imgui.TableSetupColumn("samples")
+	imgui.TableHeadersRow()
+	for i, s := range dat.beSeries {
+		imgui.PushIDInt(int32(i))
+		imgui.TableNextRow()
+		imgui.TableNextColumn()
+		imgui.AlignTextToFramePadding()
+		imgui.TextUnformatted(s.Group)
+		imgui.TableNextColumn()
+		imgui.SetNextItemWidth(120)
+		if imgui.ComboStrarr("##type", &s.Type, beInterpretTypeNames, int32(len(beInterpretTypeNames))) {
+			dat.beProcessed = false
+		}
+		imgui.TableNextColumn()
+		imgui.SetNextItemWidth(90)
+		if imgui.InputInt("##bitoffset", &s.BitOffset) {
+			s.BitOffset = max(0, s.BitOffset)
+			dat.beProcessed = false
+		}
+		imgui.TableNextColumn()
+		if beInterpretTypes[s.Type].Kind == 'b' {
+			imgui.SetNextItemWidth(90)
+			if imgui.InputInt("##bitwidth", &s.BitWidth) {
+				s.BitWidth = max(1, min(64, s.BitWidth))
+				dat.beProcessed = false
+			}
+		}
+		imgui.TableNextColumn()
+		if beInterpretTypes[s.Type].Kind == 'x' {
+			imgui.SetNextItemWidth(90)
+			if imgui.InputInt("##fracbits", &s.FracBits) {
+				s.FracBits = max(0, min(62, s.FracBits))
+				dat.beProcessed = false
+			}
+		}
+		imgui.TableNextColumn()
+		if imgui.Checkbox("##isx", &s.IsX) {
+			if s.IsX {
+				for _, o := range dat.beSeries {
+					o.IsX = o == s
+				}
+			}
+			dat.beProcessed = false
+			dat.beFirstFit = true
+		}
+		imgui.TableNextColumn()
+		samples := strconv.Itoa(len(s.plotY))
+		if s.errors > 0 {
+			samples += fmt.Sprintf(" (%d errors)", s.errors)
+		}
+		imgui.TextUnformatted(samples)
+		imgui.PopID()
+	}
+	imgui.EndTable()
+}
@@ -0,0 +1,189 @@
+/*
+	wrpl-inspector: War Thunder replay inspection software
+	Copyright (C) 2025 flexcoral
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published
+	by the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AllenDang/cimgui-go/imgui"
+	"github.com/maxsupermanhd/wrpl-inspector/wrpl"
+	"github.com/rs/zerolog/log"
+)
+
+type chatView struct {
+	filter  string
+	hidden  map[wrpl.ChatChannel]bool
+	packets []*wrpl.WRPLRawPacket
+	chat    []*wrpl.ParsedPacketChat
+}
+
+var (
+	chatChannelColors = map[wrpl.ChatChannel]imgui.Vec4{
+		wrpl.ChatChannelTeam:   imgui.NewVec4(0.45, 0.65, 1, 1),
+		wrpl.ChatChannelSquad:  imgui.NewVec4(0.45, 0.85, 0.45, 1),
+		wrpl.ChatChannelSystem: imgui.NewVec4(0.6, 0.6, 0.6, 1),
+	}
+	chatEnemyColor     = imgui.NewVec4(0.95, 0.4, 0.4, 1)
+	chatExportFileExts = map[string]string{"text": "txt", "json": "json", "irc": "log"}
+)
+
+// newChatView pairs chat messages with their packets, only packets that
+// parsed without error end up in the chat
+func newChatView(rpl *parsedReplay) *chatView {
+	ret := &chatView{hidden: map[wrpl.ChatChannel]bool{}}
+	for _, pk := range rpl.Replay.Packets {
+		if wrpl.PacketType(pk.PacketType) != wrpl.PacketTypeChat || pk.Parsed == nil || pk.ParseError != nil {
+			continue
+		}
+		if len(ret.packets) < len(rpl.Replay.Parsed.Chat) {
+			ret.packets = append(ret.packets, pk)
+		}
+	}
+	ret.chat = rpl.Replay.Parsed.Chat[:len(ret.packets)]
+	return ret
+}
+
+func chatLogName(rpl *parsedReplay) string {
+	return rpl.Replay.Header.Describe() + " " + rpl.LoadedFrom
+}
+
+func uiShowChat(rpl *parsedReplay) {
+	if rpl.Replay.Parsed == nil {
+		imgui.TextUnformatted("parsed is nil")
+		return
+	}
+	if rpl.chat == nil {
+		rpl.chat = newChatView(rpl)
+	}
+	v := rpl.chat
+	imgui.SetNextItemWidth(250)
+	imgui.InputTextWithHint("##chat filter", "filter sender or message", &v.filter, 0, nil)
+	for _, c := range []wrpl.ChatChannel{wrpl.ChatChannelAll, wrpl.ChatChannelTeam, wrpl.ChatChannelSquad, wrpl.ChatChannelSystem} {
+		imgui.SameLine()
+		shown := !v.hidden[c]
+		if imgui.Checkbox(c.String(), &shown) {
+			v.hidden[c] = !shown
+		}
+	}
+
+	imgui.AlignTextToFramePadding()
+	imgui.TextUnformatted("copy as")
+	for _, f := range wrpl.ChatExportFormats {
+		imgui.SameLine()
+		if imgui.Button(f + "##chat copy") {
+			buf := &bytes.Buffer{}
+			err := wrpl.ExportChat(buf, f, []wrpl.ChatLog{wrpl.NewChatLog(chatLogName(rpl), rpl.Replay)})
+			if err != nil {
+				log.Err(err).Msg("exporting chat")
+			} else {
+				imgui.SetClipboardText(buf.String())
+			}
+		}
+	}
+	imgui.SameLine()
+	imgui.TextUnformatted("export all open replays")
+	for _, f := range wrpl.ChatExportFormats {
+		imgui.SameLine()
+		if imgui.Button(f + "##chat export") {
+			uiExportOpenChats(f)
+		}
+	}
+	imgui.SameLine()
+	uiHelpMarker("Export writes chat of every open replay to chat.txt, chat.json or chat.log,\nsame formats are written by tools/wrpl-chat.\nTeam chat is blue, squad green, system grey, enemy senders red.")
+
+	filter := strings.ToLower(v.filter)
+	shown := []int{}
+	for i, c := range v.chat {
+		if v.hidden[c.Channel] {
+			continue
+		}
+		if filter != "" && !strings.Contains(strings.ToLower(c.Sender+"\x00"+c.Content), filter) {
+			continue
+		}
+		shown = append(shown, i)
+	}
+
+	tableFlags := imgui.TableFlagsRowBg | imgui.TableFlagsBordersV | imgui.TableFlagsBordersOuterH | imgui.TableFlagsSizingFixedFit | imgui.TableFlagsScrollY | imgui.TableFlagsScrollX
+	if imgui.BeginTableV("##chat", 4, tableFlags, imgui.Vec2{}, 0) {
+		imgui.TableSetupScrollFreeze(0, 1)
+		imgui.TableSetupColumn("time")
+		imgui.TableSetupColumn("channel")
+		imgui.TableSetupColumn("sender")
+		imgui.TableSetupColumn("message")
+		imgui.TableHeadersRow()
+		clipper := imgui.NewListClipper()
+		clipper.Begin(int32(len(shown)))
+		for clipper.Step() {
+			for i := clipper.DisplayStart(); i < clipper.DisplayEnd(); i++ {
+				c := v.chat[shown[i]]
+				imgui.TableNextRow()
+				imgui.TableNextColumn()
+				if imgui.SelectableBoolV(c.Time().Truncate(time.Second).String()+"##"+strconv.Itoa(shown[i]), false, imgui.SelectableFlagsSpanAllColumns, imgui.NewVec2(0, 0)) {
+					openPacketInStream(rpl, v.packets[shown[i]])
+				}
+				imgui.SetItemTooltip("open packet in packet stream")
+				col, colored := chatChannelColors[c.Channel]
+				if colored {
+					imgui.PushStyleColorVec4(imgui.ColText, col)
+				}
+				imgui.TableNextColumn()
+				imgui.TextUnformatted(c.Channel.String())
+				imgui.TableNextColumn()
+				if c.Enemy() {
+					imgui.PushStyleColorVec4(imgui.ColText, chatEnemyColor)
+				}
+				imgui.TextUnformatted(wrpl.ChatSender(c))
+				if c.Enemy() {
+					imgui.PopStyleColor()
+				}
+				if c.Player != nil {
+					imgui.SetItemTooltip(strings.ReplaceAll(fmt.Sprintf("user id %d, title %q", c.Player.UserID, c.Player.Title), "%", "%%"))
+				}
+				imgui.TableNextColumn()
+				imgui.TextUnformatted(c.Content)
+				if colored {
+					imgui.PopStyleColor()
+				}
+			}
+		}
+		clipper.End()
+		imgui.EndTable()
+	}
+}
+
+// uiExportOpenChats is called with openReplaysLock held
+func uiExportOpenChats(format string) {
+	logs := []wrpl.ChatLog{}
+	for _, r := range openReplays {
+		logs = append(logs, wrpl.NewChatLog(chatLogName(r), r.Replay))
+	}
+	buf := &bytes.Buffer{}
+	err := wrpl.ExportChat(buf, format, logs)
+	if err != nil {
+		log.Err(err).Msg("exporting chat")
+		return
+	}
+	path := "chat." + chatExportFileExts[format]
+	log.Err(os.WriteFile(path, buf.Bytes(), 0644)).Str("path", path).Int("replays", len(logs)).Msg("export chat")
+}
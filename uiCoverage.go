@@ -0,0 +1,138 @@
+/*
+	wrpl-inspector: War Thunder replay inspection software
+	Copyright (C) 2025 flexcoral
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published
+	by the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/AllenDang/cimgui-go/imgui"
+	"github.com/maxsupermanhd/wrpl-inspector/wrpl"
+)
+
+type coverageView struct {
+	sigLength int32
+	coverage  *wrpl.Coverage
+	selected  int
+}
+
+func formatLengthCounts(lengths []wrpl.LengthCount, limit int) string {
+	ret := []string{}
+	for _, l := range lengths[:min(len(lengths), limit)] {
+		ret = append(ret, fmt.Sprintf("%dx%d", l.Length, l.Count))
+	}
+	if len(lengths) > limit {
+		ret = append(ret, "...")
+	}
+	return strings.Join(ret, " ")
+}
+
+func uiShowCoverage(rpl *parsedReplay) {
+	if rpl.coverage == nil {
+		rpl.coverage = &coverageView{sigLength: wrpl.DefaultCoverageSignatureLength}
+	}
+	v := rpl.coverage
+	imgui.AlignTextToFramePadding()
+	imgui.TextUnformatted("Signature length:")
+	imgui.SameLine()
+	imgui.SetNextItemWidth(90)
+	if imgui.InputInt("##coverage signature length", &v.sigLength) {
+		v.sigLength = max(1, v.sigLength)
+		v.coverage = nil
+	}
+	if v.coverage == nil {
+		v.coverage = wrpl.AnalyzeMPICoverage(rpl.Replay.Packets, int(v.sigLength), wrpl.DefaultCoverageExamples)
+		v.selected = 0
+	}
+	c := v.coverage
+	imgui.SameLine()
+	imgui.TextUnformatted(fmt.Sprintf("%d MPI packets, %d parsed, %d failed, %d unknown, coverage %.1f%%, %d signatures",
+		c.Total, c.Parsed, c.Failed, c.Unparsed(), c.Percent(), len(c.Clusters)))
+	imgui.SameLine()
+	uiHelpMarker("MPI packets grouped by leading payload bytes, signatures that are not parsed come first.\nSame analysis is printed by tools/wrpl-coverage.")
+	if len(c.Clusters) == 0 {
+		return
+	}
+	v.selected = min(v.selected, len(c.Clusters)-1)
+
+	tableFlags := imgui.TableFlagsRowBg | imgui.TableFlagsBordersV | imgui.TableFlagsBordersOuterH | imgui.TableFlagsSizingFixedFit | imgui.TableFlagsScrollY | imgui.TableFlagsScrollX
+	avail := imgui.ContentRegionAvail()
+	if imgui.BeginTableV("##coverage clusters", 10, tableFlags, imgui.NewVec2(0, avail.Y*0.7), 0) {
+		imgui.TableSetupScrollFreeze(0, 1)
+		imgui.TableSetupColumn("signature")
+		imgui.TableSetupColumn("count")
+		imgui.TableSetupColumn("parsed")
+		imgui.TableSetupColumn("failed")
+		imgui.TableSetupColumn("unknown")
+		imgui.TableSetupColumn("names")
+		imgui.TableSetupColumn("min len")
+		imgui.TableSetupColumn("avg len")
+		imgui.TableSetupColumn("max len")
+		imgui.TableSetupColumn("common lengths")
+		imgui.TableHeadersRow()
+		clipper := imgui.NewListClipper()
+		clipper.Begin(int32(len(c.Clusters)))
+		for clipper.Step() {
+			for i := clipper.DisplayStart(); i < clipper.DisplayEnd(); i++ {
+				cl := c.Clusters[i]
+				imgui.TableNextRow()
+				imgui.TableNextColumn()
+				if imgui.SelectableBoolV(cl.Signature+"##"+strconv.Itoa(int(i)), v.selected == int(i), imgui.SelectableFlagsSpanAllColumns, imgui.NewVec2(0, 0)) {
+					v.selected = int(i)
+				}
+				if cl.Hint != "" {
+					imgui.SetItemTooltip(strings.ReplaceAll(cl.Hint, "%", "%%"))
+				}
+				uiTableRowStrings(
+					strconv.Itoa(cl.Count),
+					strconv.Itoa(cl.Parsed),
+					strconv.Itoa(cl.Failed),
+					strconv.Itoa(cl.Unparsed()),
+					strings.Join(cl.Names, ", "),
+					strconv.Itoa(cl.MinLength),
+					strconv.FormatFloat(cl.AvgLength, 'f', 1, 64),
+					strconv.Itoa(cl.MaxLength),
+					formatLengthCounts(cl.Lengths, 6),
+				)
+			}
+		}
+		clipper.End()
+		imgui.EndTable()
+	}
+
+	cl := c.Clusters[v.selected]
+	imgui.TextUnformatted(fmt.Sprintf("Signature %s: %d packets, %d distinct lengths", cl.Signature, cl.Count, len(cl.Lengths)))
+	if cl.Hint != "" {
+		imgui.SameLine()
+		imgui.TextUnformatted("(" + cl.Hint + ")")
+	}
+	imgui.AlignTextToFramePadding()
+	imgui.TextUnformatted("Examples:")
+	for i, pi := range cl.Examples {
+		pk := rpl.Replay.Packets[pi]
+		imgui.SameLine()
+		imgui.PushIDInt(int32(i))
+		if imgui.SmallButton(fmt.Sprintf("#%d %s", pi, pk.Time())) {
+			openPacketInStream(rpl, pk)
+		}
+		imgui.PopID()
+	}
+	imgui.TextUnformatted("Lengths: " + formatLengthCounts(cl.Lengths, 32))
+}
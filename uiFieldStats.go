@@ -0,0 +1,180 @@
+/*
+	wrpl-inspector: War Thunder replay inspection software
+	Copyright (C) 2025 flexcoral
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published
+	by the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/AllenDang/cimgui-go/imgui"
+	"github.com/AllenDang/cimgui-go/implot"
+	"github.com/maxsupermanhd/wrpl-inspector/wrpl"
+)
+
+type fieldStatsKey struct {
+	first *wrpl.WRPLRawPacket
+	last  *wrpl.WRPLRawPacket
+	count int
+}
+
+type fieldStatsView struct {
+	stats        *wrpl.FieldStats
+	selected     int
+	correlations []wrpl.OffsetCorrelation
+	histX        []float32
+	histY        []float32
+}
+
+// packet lists are recreated on every search so cache is keyed by their
+// bounds and dropped when it grows
+var fieldStatsCache = map[fieldStatsKey]*fieldStatsView{}
+
+func getFieldStatsView(packets []*wrpl.WRPLRawPacket) *fieldStatsView {
+	key := fieldStatsKey{first: packets[0], last: packets[len(packets)-1], count: len(packets)}
+	v, ok := fieldStatsCache[key]
+	if ok {
+		return v
+	}
+	if len(fieldStatsCache) > 16 {
+		clear(fieldStatsCache)
+	}
+	v = &fieldStatsView{
+		stats:    wrpl.AnalyzePacketFields(packets, wrpl.DefaultFieldStatsOffsets),
+		selected: -1,
+	}
+	fieldStatsCache[key] = v
+	return v
+}
+
+func (v *fieldStatsView) selectOffset(off int) {
+	v.selected = off
+	v.correlations = v.stats.TopCorrelations(off, 10)
+	v.histX = v.histX[:0]
+	v.histY = v.histY[:0]
+	for b, c := range v.stats.Offsets[off].Histogram {
+		if c > 0 {
+			v.histX = append(v.histX, float32(b))
+			v.histY = append(v.histY, float32(c))
+		}
+	}
+}
+
+func formatCorrelation(c float64) string {
+	if math.IsNaN(c) {
+		return "-"
+	}
+	return strconv.FormatFloat(c, 'f', 2, 64)
+}
+
+func uiShowFieldStats(packets []*wrpl.WRPLRawPacket) {
+	v := getFieldStatsView(packets)
+	st := v.stats
+	imgui.TextUnformatted(fmt.Sprintf("%d offsets analyzed, %d field candidates", len(st.Offsets), len(st.Candidates)))
+	if len(st.Offsets) == 0 {
+		return
+	}
+	if v.selected < 0 || v.selected >= len(st.Offsets) {
+		v.selectOffset(0)
+	}
+
+	tableFlags := imgui.TableFlagsRowBg | imgui.TableFlagsBordersV | imgui.TableFlagsBordersOuterH | imgui.TableFlagsSizingFixedFit | imgui.TableFlagsScrollY | imgui.TableFlagsScrollX
+	if imgui.BeginTable("##fieldstatslayout", 2) {
+		imgui.TableNextRow()
+		imgui.TableNextColumn()
+		if imgui.BeginTableV("##offset stats", 9, tableFlags, imgui.ContentRegionAvail(), 0) {
+			imgui.TableSetupScrollFreeze(0, 1)
+			imgui.TableSetupColumn("offset")
+			imgui.TableSetupColumn("class")
+			imgui.TableSetupColumn("present")
+			imgui.TableSetupColumn("distinct")
+			imgui.TableSetupColumn("entropy")
+			imgui.TableSetupColumn("min")
+			imgui.TableSetupColumn("max")
+			imgui.TableSetupColumn("inc/dec")
+			imgui.TableSetupColumn("time corr")
+			imgui.TableHeadersRow()
+			clipper := imgui.NewListClipper()
+			clipper.Begin(int32(len(st.Offsets)))
+			for clipper.Step() {
+				for i := clipper.DisplayStart(); i < clipper.DisplayEnd(); i++ {
+					o := &st.Offsets[i]
+					imgui.TableNextRow()
+					imgui.TableNextColumn()
+					if imgui.SelectableBoolV(strconv.Itoa(o.Offset), v.selected == o.Offset, imgui.SelectableFlagsSpanAllColumns, imgui.NewVec2(0, 0)) {
+						v.selectOffset(o.Offset)
+					}
+					uiTableRowStrings(
+						o.Class,
+						strconv.Itoa(o.Present),
+						strconv.Itoa(o.Distinct),
+						strconv.FormatFloat(o.Entropy, 'f', 2, 64),
+						fmt.Sprintf("%02x", o.Min),
+						fmt.Sprintf("%02x", o.Max),
+						fmt.Sprintf("%d/%d", o.Increasing, o.Decreasing),
+						formatCorrelation(o.TimeCorrelation),
+					)
+				}
+			}
+			clipper.End()
+			imgui.EndTable()
+		}
+
+		imgui.TableNextColumn()
+		o := &st.Offsets[v.selected]
+		imgui.TextUnformatted(fmt.Sprintf("Offset %d: %s, entropy %.2f, time correlation %s", o.Offset, o.Class, o.Entropy, formatCorrelation(o.TimeCorrelation)))
+		avail := imgui.ContentRegionAvail()
+		if len(v.histX) > 0 && implot.BeginPlotV("##offset histogram", imgui.NewVec2(-1, avail.Y/3), 0) {
+			implot.PlotBarsFloatPtrFloatPtr("count", &v.histX[0], &v.histY[0], int32(len(v.histX)), 0.8)
+			implot.EndPlot()
+		}
+		imgui.TextUnformatted("Correlated offsets")
+		if imgui.BeginTableV("##offset correlations", 2, tableFlags, imgui.NewVec2(0, avail.Y/4), 0) {
+			imgui.TableSetupScrollFreeze(0, 1)
+			imgui.TableSetupColumn("offset")
+			imgui.TableSetupColumn("correlation")
+			imgui.TableHeadersRow()
+			for _, c := range v.correlations {
+				imgui.TableNextRow()
+				uiTableRowStrings(strconv.Itoa(c.Offset), formatCorrelation(c.Correlation))
+			}
+			imgui.EndTable()
+		}
+		imgui.TextUnformatted("Field candidates")
+		if imgui.BeginTableV("##field candidates", 3, tableFlags, imgui.ContentRegionAvail(), 0) {
+			imgui.TableSetupScrollFreeze(0, 1)
+			imgui.TableSetupColumn("offset")
+			imgui.TableSetupColumn("type")
+			imgui.TableSetupColumn("reason")
+			imgui.TableHeadersRow()
+			clipper := imgui.NewListClipper()
+			clipper.Begin(int32(len(st.Candidates)))
+			for clipper.Step() {
+				for i := clipper.DisplayStart(); i < clipper.DisplayEnd(); i++ {
+					c := st.Candidates[i]
+					imgui.TableNextRow()
+					uiTableRowStrings(strconv.Itoa(c.Offset), c.Kind, c.Reason)
+				}
+			}
+			clipper.End()
+			imgui.EndTable()
+		}
+		imgui.EndTable()
+	}
+}
@@ -0,0 +1,223 @@
+/*
+	wrpl-inspector: War Thunder replay inspection software
+	Copyright (C) 2025 flexcoral
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published
+	by the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/AllenDang/cimgui-go/imgui"
+	"github.com/maxsupermanhd/wrpl-inspector/pktschema"
+	"github.com/maxsupermanhd/wrpl-inspector/wrpl"
+)
+
+const hexViewRowBytes = 16
+
+var (
+	hexViewPalette = []imgui.Vec4{
+		imgui.NewVec4(0.90, 0.40, 0.40, 1),
+		imgui.NewVec4(0.40, 0.80, 0.40, 1),
+		imgui.NewVec4(0.40, 0.55, 0.95, 1),
+		imgui.NewVec4(0.90, 0.75, 0.30, 1),
+		imgui.NewVec4(0.75, 0.45, 0.90, 1),
+		imgui.NewVec4(0.30, 0.80, 0.80, 1),
+	}
+	hexViewUnconsumed = imgui.NewVec4(0.5, 0.5, 0.5, 0.25)
+	hexViewStates     = map[imgui.ID]*hexViewState{}
+)
+
+// hexViewState is kept per hex view (pinned packet windows and inspect tab
+// each have their own), keyed by imgui id of the view
+type hexViewState struct {
+	pk *wrpl.WRPLRawPacket
+	// hovered field is shared between hex grid and field list, both
+	// read value from the previous frame and write it for the next one
+	span int
+	next int
+	// text shows plain hexdump that can be selected and copied
+	text bool
+}
+
+// packetFieldSpans returns spans recorded by the parser, when packet parser
+// does not know the packet top-level fields of matching schema are used
+func packetFieldSpans(pk *wrpl.WRPLRawPacket) ([]wrpl.FieldSpan, string) {
+	if pk.Parsed != nil && len(pk.Parsed.Spans) > 0 {
+		return pk.Parsed.Spans, "parser"
+	}
+	def := pktSchema.Match(pk.PacketType, pk.PacketPayload)
+	if def == nil {
+		return nil, ""
+	}
+	fields, _ := def.Decode(pk.PacketPayload)
+	return schemaFieldSpans(nil, fields), "schema " + def.Name
+}
+
+func schemaFieldSpans(ret []wrpl.FieldSpan, fields []*pktschema.Field) []wrpl.FieldSpan {
+	for _, f := range fields {
+		if f.InBlob {
+			continue
+		}
+		if f.Name == "" {
+			ret = schemaFieldSpans(ret, f.Children)
+			continue
+		}
+		ret = append(ret, wrpl.FieldSpan{Name: f.Name, BitStart: f.BitStart, BitEnd: f.BitEnd})
+	}
+	return ret
+}
+
+func hexViewSpanColor(i int, hovered bool) uint32 {
+	c := hexViewPalette[i%len(hexViewPalette)]
+	c.W = 0.35
+	if hovered {
+		c.W = 0.85
+	}
+	return imgui.ColorU32Vec4(c)
+}
+
+func uiShowHexView(pk *wrpl.WRPLRawPacket) {
+	id := imgui.IDStr("##packet hexview")
+	st, ok := hexViewStates[id]
+	if !ok {
+		st = &hexViewState{}
+		hexViewStates[id] = st
+	}
+	hovered := -1
+	if st.pk == pk {
+		hovered = st.span
+	}
+	st.pk = pk
+	st.next = -1
+	defer func() {
+		st.span = st.next
+	}()
+
+	payload := pk.PacketPayload
+	imgui.Checkbox("text", &st.text)
+	if st.text {
+		d := hex.Dump(payload)
+		imgui.InputTextMultiline("## packet hexdump", &d, imgui.ContentRegionAvail(), imgui.InputTextFlagsReadOnly, nil)
+		return
+	}
+	spans, source := packetFieldSpans(pk)
+	byteSpan := make([]int, len(payload))
+	for i := range byteSpan {
+		byteSpan[i] = -1
+	}
+	for i, s := range spans {
+		for b := s.BitStart / 8; b < (s.BitEnd+7)/8 && b < len(payload); b++ {
+			byteSpan[b] = i
+		}
+	}
+
+	avail := imgui.ContentRegionAvail()
+	hexSize := avail
+	if len(spans) > 0 {
+		hexSize.Y = avail.Y * 2 / 3
+	}
+	if imgui.BeginChildStrV("##packet hexview", hexSize, imgui.ChildFlagsBorders, 0) {
+		dl := imgui.WindowDrawList()
+		charSize := imgui.CalcTextSize("00")
+		clipper := imgui.NewListClipper()
+		clipper.Begin(int32((len(payload) + hexViewRowBytes - 1) / hexViewRowBytes))
+		for clipper.Step() {
+			for row := int(clipper.DisplayStart()); row < int(clipper.DisplayEnd()); row++ {
+				imgui.TextUnformatted(fmt.Sprintf("%08x", row*hexViewRowBytes))
+				end := min(len(payload), (row+1)*hexViewRowBytes)
+				for i := row * hexViewRowBytes; i < end; i++ {
+					spacing := float32(4)
+					if i%8 == 0 {
+						spacing = 12
+					}
+					imgui.SameLineV(0, spacing)
+					p := imgui.CursorScreenPos()
+					pMax := imgui.NewVec2(p.X+charSize.X, p.Y+charSize.Y)
+					switch {
+					case byteSpan[i] >= 0:
+						dl.AddRectFilled(p, pMax, hexViewSpanColor(byteSpan[i], byteSpan[i] == hovered))
+					case len(spans) > 0:
+						dl.AddRectFilled(p, pMax, imgui.ColorU32Vec4(hexViewUnconsumed))
+					}
+					imgui.TextUnformatted(hex.EncodeToString(payload[i : i+1]))
+					if imgui.IsItemHovered() {
+						st.next = byteSpan[i]
+						if imgui.BeginTooltip() {
+							imgui.TextUnformatted(fmt.Sprintf("offset %d (0x%x)", i, i))
+							if byteSpan[i] >= 0 {
+								s := spans[byteSpan[i]]
+								imgui.TextUnformatted(fmt.Sprintf("%s: bits %d..%d", s.Name, s.BitStart, s.BitEnd))
+							} else if len(spans) > 0 {
+								imgui.TextUnformatted("not consumed")
+							}
+							imgui.EndTooltip()
+						}
+					}
+				}
+				imgui.SameLineV(0, 12+float32(hexViewRowBytes-(end-row*hexViewRowBytes))*(charSize.X+4))
+				imgui.TextUnformatted(hexViewPrintable(payload[row*hexViewRowBytes : end]))
+			}
+		}
+		clipper.End()
+	}
+	imgui.EndChild()
+
+	if len(spans) == 0 {
+		return
+	}
+	imgui.TextUnformatted("Fields from " + source)
+	tableFlags := imgui.TableFlagsRowBg | imgui.TableFlagsBordersV | imgui.TableFlagsBordersOuterH | imgui.TableFlagsSizingFixedFit | imgui.TableFlagsScrollY | imgui.TableFlagsScrollX
+	if imgui.BeginTableV("##packet field spans", 4, tableFlags, imgui.ContentRegionAvail(), 0) {
+		imgui.TableSetupScrollFreeze(0, 1)
+		imgui.TableSetupColumn("name")
+		imgui.TableSetupColumn("offset")
+		imgui.TableSetupColumn("bits")
+		imgui.TableSetupColumn("bytes")
+		imgui.TableHeadersRow()
+		for i, s := range spans {
+			imgui.TableNextRow()
+			imgui.TableNextColumn()
+			imgui.TableSetBgColor(imgui.TableBgTargetCellBg, hexViewSpanColor(i, i == hovered))
+			imgui.SelectableBoolV(s.Name+fmt.Sprintf("##span%d", i), i == hovered, imgui.SelectableFlagsSpanAllColumns, imgui.NewVec2(0, 0))
+			if imgui.IsItemHovered() {
+				st.next = i
+			}
+			offset := fmt.Sprintf("%d", s.BitStart/8)
+			if s.BitStart%8 != 0 {
+				offset = fmt.Sprintf("%d.%d", s.BitStart/8, s.BitStart%8)
+			}
+			val := ""
+			if s.BitStart/8 < len(payload) {
+				val = hex.EncodeToString(payload[s.BitStart/8 : min(len(payload), (s.BitEnd+7)/8)])
+			}
+			uiTableRowStrings(offset, fmt.Sprintf("%d", s.BitEnd-s.BitStart), val)
+		}
+		imgui.EndTable()
+	}
+}
+
+func hexViewPrintable(b []byte) string {
+	ret := make([]byte, len(b))
+	for i, c := range b {
+		if c < 0x20 || c > 0x7e {
+			c = '.'
+		}
+		ret[i] = c
+	}
+	return string(ret)
+}
@@ -0,0 +1,279 @@
+/*
+	wrpl-inspector: War Thunder replay inspection software
+	Copyright (C) 2025 flexcoral
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published
+	by the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AllenDang/cimgui-go/imgui"
+	"github.com/maxsupermanhd/wrpl-inspector/wrpl"
+	"github.com/rs/zerolog/log"
+)
+
+// how many hits of one file are listed before the rest is collapsed
+const librarySearchHitsShown = 64
+
+type librarySearchSession struct {
+	sessionID string
+	header    wrpl.WRPLHeader
+	files     []wrpl.LibraryMatch
+	hits      int
+}
+
+type librarySearchState struct {
+	lock sync.Mutex
+
+	term    string
+	mode    int32
+	workers int32
+
+	generation int
+	cancel     context.CancelFunc
+	running    bool
+	started    time.Time
+	took       time.Duration
+	err        error
+
+	total     int
+	scanned   int
+	failed    int
+	hitFiles  int
+	hitsTotal int
+	sessions  []*librarySearchSession
+}
+
+var librarySearch = &librarySearchState{
+	mode:    4,
+	workers: int32(runtime.GOMAXPROCS(0)),
+}
+
+func (s *librarySearchState) start(paths []string) {
+	if s.cancel != nil {
+		s.cancel()
+		s.cancel = nil
+	}
+	s.generation++
+	s.running = false
+	s.err = nil
+	s.total = len(paths)
+	s.scanned = 0
+	s.failed = 0
+	s.hitFiles = 0
+	s.hitsTotal = 0
+	s.sessions = nil
+
+	if s.mode == 6 {
+		s.err = errors.New("notes search only works inside open replays")
+		return
+	}
+	match, err := newPacketMatcher(s.mode, s.term, nil)
+	if err != nil {
+		s.err = fmt.Errorf("search %q: %w", s.term, err)
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.running = true
+	s.started = time.Now()
+	gen := s.generation
+	workers := int(s.workers)
+	go func() {
+		err := wrpl.SearchLibrary(ctx, paths, workers, match, func(m wrpl.LibraryMatch) {
+			s.lock.Lock()
+			defer s.lock.Unlock()
+			if s.generation == gen {
+				s.add(m)
+			}
+		})
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		if s.generation != gen {
+			return
+		}
+		if err != nil && !errors.Is(err, context.Canceled) {
+			s.err = err
+		}
+		s.running = false
+		s.took = time.Since(s.started)
+		cancel()
+	}()
+}
+
+// add must be called with lock held
+func (s *librarySearchState) add(m wrpl.LibraryMatch) {
+	s.scanned++
+	if m.Err != nil {
+		s.failed++
+		log.Debug().Err(m.Err).Str("path", m.Path).Msg("library search")
+		return
+	}
+	if len(m.Packets) == 0 {
+		return
+	}
+	s.hitFiles++
+	s.hitsTotal += len(m.Packets)
+	sid := m.Header.SessionHEX()
+	i, found := slices.BinarySearchFunc(s.sessions, sid, func(v *librarySearchSession, sid string) int {
+		// newest sessions first, same as discovery tree
+		return strings.Compare(sid, v.sessionID)
+	})
+	if !found {
+		s.sessions = slices.Insert(s.sessions, i, &librarySearchSession{sessionID: sid, header: m.Header})
+	}
+	ss := s.sessions[i]
+	ss.hits += len(m.Packets)
+	j, _ := slices.BinarySearchFunc(ss.files, m.Path, func(v wrpl.LibraryMatch, p string) int {
+		return strings.Compare(v.Path, p)
+	})
+	ss.files = slices.Insert(ss.files, j, m)
+}
+
+func (s *librarySearchState) stop() {
+	if s.cancel != nil {
+		s.cancel()
+		s.cancel = nil
+	}
+	if s.running {
+		s.running = false
+		s.took = time.Since(s.started)
+	}
+}
+
+// openLibraryHit opens replay file in a tab and pins packet stream at the hit
+func openLibraryHit(path string, packet int) error {
+	rpl, err := openReplaySource(replaySource{Kind: replaySourceFile, Path: path})
+	if err != nil {
+		return err
+	}
+	if packet < 0 || packet >= len(rpl.Replay.Packets) {
+		return fmt.Errorf("packet %d is out of range of %s", packet, path)
+	}
+	openPacketInStream(rpl, rpl.Replay.Packets[packet])
+	return nil
+}
+
+func uiShowLibrarySearch() {
+	if !imgui.CollapsingHeaderTreeNodeFlags("Search library") {
+		return
+	}
+	s := librarySearch
+	s.lock.Lock()
+	hitPath, hitPacket := uiShowLibrarySearchLocked(s)
+	s.lock.Unlock()
+	// parsing the replay takes a while, search workers should not wait for it
+	if hitPath != "" {
+		err := openLibraryHit(hitPath, hitPacket)
+		s.lock.Lock()
+		s.err = err
+		s.lock.Unlock()
+	}
+}
+
+// uiShowLibrarySearchLocked returns hit that was clicked, empty path if none
+func uiShowLibrarySearchLocked(s *librarySearchState) (hitPath string, hitPacket int) {
+	imgui.AlignTextToFramePadding()
+	imgui.TextUnformatted("Search in all found replays:")
+	imgui.SameLine()
+	imgui.SetNextItemWidth(150)
+	imgui.ComboStrarr("##librarySearchMode", &s.mode, packetSearchModeNames, int32(len(packetSearchModeNames)))
+	imgui.SameLine()
+	imgui.SetNextItemWidth(max(200, imgui.ContentRegionAvail().X-350))
+	doSearch := imgui.InputTextWithHint("##librarySearchTerm", "byte pattern or query", &s.term, imgui.InputTextFlagsEnterReturnsTrue, nil)
+	imgui.SameLine()
+	imgui.SetNextItemWidth(90)
+	if imgui.InputInt("workers", &s.workers) {
+		s.workers = max(1, s.workers)
+	}
+	imgui.SameLine()
+	if s.running {
+		if imgui.Button("cancel##librarySearch") {
+			s.stop()
+		}
+	} else if imgui.Button("search##librarySearch") {
+		doSearch = true
+	}
+	imgui.SameLine()
+	uiHelpMarker("Parses every replay file listed below and matches packets of each one.\nSession folders are searched file by file, hits open the file they were found in.")
+	if doSearch {
+		paths := make([]string, 0, len(wrplDiscoveryFound))
+		for _, v := range wrplDiscoveryFound {
+			paths = append(paths, v.wrplPath)
+		}
+		s.start(paths)
+	}
+
+	if s.err != nil {
+		imgui.TextUnformatted("Error: " + s.err.Error())
+	}
+	if s.total == 0 {
+		return "", 0
+	}
+	if s.running {
+		imgui.ProgressBarV(float32(s.scanned)/float32(s.total), imgui.NewVec2(-1, 0), fmt.Sprintf("%d/%d", s.scanned, s.total))
+	}
+	took := s.took
+	if s.running {
+		took = time.Since(s.started)
+	}
+	imgui.TextUnformatted(fmt.Sprintf("%d hits in %d of %d scanned replays (%d sessions), %d failed to parse, took %s",
+		s.hitsTotal, s.hitFiles, s.scanned, len(s.sessions), s.failed, took.Round(time.Millisecond)))
+	if len(s.sessions) == 0 {
+		return "", 0
+	}
+
+	if imgui.BeginChildStrV("##library search results", imgui.NewVec2(0, 300), imgui.ChildFlagsResizeY|imgui.ChildFlagsBorders, 0) {
+		for si, ss := range s.sessions {
+			imgui.PushIDInt(int32(si))
+			isOpen := imgui.TreeNodeExStr(fmt.Sprintf("session %s %s: %d hits in %d files", ss.sessionID, ss.header.StartTimeFormatted(), ss.hits, len(ss.files)))
+			if isOpen {
+				for fi, f := range ss.files {
+					imgui.PushIDInt(int32(fi))
+					if imgui.TreeNodeExStr(fmt.Sprintf("%s: %d of %d packets", f.Path, len(f.Packets), f.Total)) {
+						for hi, pi := range f.Packets[:min(len(f.Packets), librarySearchHitsShown)] {
+							if hi%8 != 0 {
+								imgui.SameLine()
+							}
+							imgui.PushIDInt(int32(hi))
+							if imgui.SmallButton(fmt.Sprintf("#%d %s", pi, time.Duration(f.Times[hi])*time.Millisecond)) {
+								hitPath, hitPacket = f.Path, pi
+							}
+							imgui.PopID()
+						}
+						if len(f.Packets) > librarySearchHitsShown {
+							imgui.TextUnformatted(fmt.Sprintf("and %d more", len(f.Packets)-librarySearchHitsShown))
+						}
+						imgui.TreePop()
+					}
+					imgui.PopID()
+				}
+				imgui.TreePop()
+			}
+			imgui.PopID()
+		}
+	}
+	imgui.EndChild()
+	return hitPath, hitPacket
+}
@@ -0,0 +1,126 @@
+/*
+	wrpl-inspector: War Thunder replay inspection software
+	Copyright (C) 2025 flexcoral
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published
+	by the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AllenDang/cimgui-go/imgui"
+	"github.com/maxsupermanhd/wrpl-inspector/wrpl"
+)
+
+var lifeEndColors = map[wrpl.LifeEnd]imgui.Vec4{
+	wrpl.LifeEndDestroyed: imgui.NewVec4(0.95, 0.4, 0.4, 1),
+	wrpl.LifeEndRespawn:   imgui.NewVec4(0.95, 0.8, 0.4, 1),
+	wrpl.LifeEndBattleEnd: imgui.NewVec4(0.45, 0.85, 0.45, 1),
+}
+
+func formatLifeTime(t uint32) string {
+	return (time.Duration(t) * time.Millisecond).Truncate(time.Second).String()
+}
+
+// packetAtTime returns first packet at or after given replay time
+func packetAtTime(rpl *parsedReplay, t uint32) *wrpl.WRPLRawPacket {
+	i := sort.Search(len(rpl.Replay.Packets), func(i int) bool {
+		return rpl.Replay.Packets[i].CurrentTime >= t
+	})
+	if i >= len(rpl.Replay.Packets) {
+		return nil
+	}
+	return rpl.Replay.Packets[i]
+}
+
+func uiShowLineups(rpl *parsedReplay) {
+	if rpl.Replay.Parsed == nil || rpl.Replay.Parsed.Roster == nil {
+		imgui.TextUnformatted("roster is nil")
+		return
+	}
+	if rpl.lineups == nil {
+		rpl.lineups = wrpl.NewLineups(rpl.Replay)
+	}
+	lives := 0
+	for _, l := range rpl.lineups {
+		lives += len(l.Lives)
+	}
+	imgui.TextUnformatted(fmt.Sprintf("%d participants, %d lives", len(rpl.lineups), lives))
+	imgui.SameLine()
+	uiHelpMarker("Lives start at construction of vehicle entity linked to the player and end with its destruction,\nvehicles without entities are known only from kills and span from first to last kill.\nVehicles listed in results that were never seen are shown as unobserved.\nEntity vehicles need resolved component names, reload names on the ecs tab to recompute.")
+	tableFlags := imgui.TableFlagsRowBg | imgui.TableFlagsBordersV | imgui.TableFlagsBordersOuterH | imgui.TableFlagsSizingFixedFit | imgui.TableFlagsScrollY | imgui.TableFlagsScrollX
+	if imgui.BeginTableV("lineupsTable", 8, tableFlags, imgui.Vec2{}, 0) {
+		imgui.TableSetupScrollFreeze(0, 1)
+		imgui.TableSetupColumn("player")
+		imgui.TableSetupColumn("vehicle")
+		imgui.TableSetupColumn("start")
+		imgui.TableSetupColumn("end")
+		imgui.TableSetupColumn("duration")
+		imgui.TableSetupColumn("kills")
+		imgui.TableSetupColumn("ended")
+		imgui.TableSetupColumn("eid")
+		imgui.TableHeadersRow()
+		for i, l := range rpl.lineups {
+			for j, life := range l.Lives {
+				imgui.TableNextRow()
+				imgui.TableNextColumn()
+				if j == 0 {
+					imgui.TextUnformatted(l.Participant.String())
+				}
+				imgui.TableNextColumn()
+				if imgui.SelectableBoolV(life.Vehicle+"##"+strconv.Itoa(i)+"_"+strconv.Itoa(j), false, imgui.SelectableFlagsSpanAllColumns, imgui.NewVec2(0, 0)) {
+					if pk := packetAtTime(rpl, life.Start); pk != nil {
+						openPacketInStream(rpl, pk)
+					}
+				}
+				imgui.SetItemTooltip("open packet at start of the life")
+				eid := ""
+				if life.Eid != 0 {
+					eid = strconv.FormatUint(life.Eid, 10)
+				}
+				uiTableRowStrings(
+					formatLifeTime(life.Start),
+					formatLifeTime(life.End),
+					life.Duration().Truncate(time.Second).String(),
+					strconv.Itoa(life.Kills),
+				)
+				imgui.TableNextColumn()
+				if c, ok := lifeEndColors[life.Ended]; ok {
+					imgui.PushStyleColorVec4(imgui.ColText, c)
+					imgui.TextUnformatted(life.Ended.String())
+					imgui.PopStyleColor()
+				} else {
+					imgui.TextUnformatted(life.Ended.String())
+				}
+				uiTableRowStrings(eid)
+			}
+			if len(l.Unobserved) > 0 {
+				imgui.TableNextRow()
+				imgui.TableNextColumn()
+				if len(l.Lives) == 0 {
+					imgui.TextUnformatted(l.Participant.String())
+				}
+				imgui.TableNextColumn()
+				imgui.TextDisabled("unobserved: " + strings.ReplaceAll(strings.Join(l.Unobserved, ", "), "%", "%%"))
+			}
+		}
+		imgui.EndTable()
+	}
+}
@@ -0,0 +1,363 @@
+/*
+	wrpl-inspector: War Thunder replay inspection software
+	Copyright (C) 2025 flexcoral
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published
+	by the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"cmp"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/AllenDang/cimgui-go/imgui"
+	"github.com/maxsupermanhd/wrpl-inspector/wrpl"
+)
+
+// maximum depth of nested structs flattened into columns
+const reflectionMaxDepth = 3
+
+type reflectionCell struct {
+	Text  string
+	Num   float64
+	IsNum bool
+}
+
+type reflectionColumn struct {
+	Name      string
+	Index     []int
+	filter    string
+	filterFn  func(reflectionCell) bool
+	filterErr error
+}
+
+// reflectionTable is a table of parsed packet fields, columns are
+// flattened struct fields, cells are formatted once on creation
+type reflectionTable struct {
+	packets    []*wrpl.WRPLRawPacket
+	columns    []*reflectionColumn
+	cells      [][]reflectionCell
+	order      []int
+	sortColumn int
+	sortDesc   bool
+	selected   int
+}
+
+func reflectionColumns(t reflect.Type, prefix string, index []int, depth int) []*reflectionColumn {
+	ret := []*reflectionColumn{}
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if !f.IsExported() || f.Tag.Get("reflectViewHidden") == "true" {
+			continue
+		}
+		fi := append(slices.Clone(index), i)
+		ft := f.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if ft.Kind() == reflect.Struct && depth < reflectionMaxDepth {
+			p := prefix + f.Name + "."
+			if f.Anonymous {
+				p = prefix
+			}
+			ret = append(ret, reflectionColumns(ft, p, fi, depth+1)...)
+			continue
+		}
+		ret = append(ret, &reflectionColumn{Name: prefix + f.Name, Index: fi})
+	}
+	return ret
+}
+
+func formatReflectValue(v reflect.Value) reflectionCell {
+	switch v.Kind() {
+	case reflect.Invalid:
+		return reflectionCell{Text: "nil"}
+	case reflect.String:
+		return reflectionCell{Text: v.String()}
+	case reflect.Bool:
+		return reflectionCell{Text: strconv.FormatBool(v.Bool())}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return reflectionCell{Text: strconv.FormatInt(v.Int(), 10), Num: float64(v.Int()), IsNum: true}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return reflectionCell{Text: strconv.FormatUint(v.Uint(), 10), Num: float64(v.Uint()), IsNum: true}
+	case reflect.Float32:
+		return reflectionCell{Text: strconv.FormatFloat(v.Float(), 'g', -1, 32), Num: v.Float(), IsNum: true}
+	case reflect.Float64:
+		return reflectionCell{Text: strconv.FormatFloat(v.Float(), 'g', -1, 64), Num: v.Float(), IsNum: true}
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return reflectionCell{Text: "nil"}
+		}
+		return formatReflectValue(v.Elem())
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return reflectionCell{Text: "nil"}
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(b), v)
+			return reflectionCell{Text: hex.EncodeToString(b)}
+		}
+		return reflectionCell{Text: fmt.Sprintf("[%d] %v", v.Len(), v.Interface()), Num: float64(v.Len()), IsNum: true}
+	default:
+		return reflectionCell{Text: fmt.Sprintf("%+v", v.Interface())}
+	}
+}
+
+func newReflectionTable(packets []*wrpl.WRPLRawPacket) *reflectionTable {
+	ret := &reflectionTable{packets: packets, sortColumn: -1, selected: -1}
+	rfType := reflect.Indirect(reflect.ValueOf(packets[0].Parsed.Data)).Type()
+	if rfType.Kind() == reflect.Struct {
+		ret.columns = reflectionColumns(rfType, "", nil, 0)
+	}
+	ret.cells = make([][]reflectionCell, len(packets))
+	for i, pk := range packets {
+		row := make([]reflectionCell, len(ret.columns))
+		v := reflect.Indirect(reflect.ValueOf(pk.Parsed.Data))
+		for ci, c := range ret.columns {
+			if v.Type() != rfType {
+				row[ci] = reflectionCell{Text: "type " + v.Type().String()}
+				continue
+			}
+			f, err := v.FieldByIndexErr(c.Index)
+			if err != nil {
+				row[ci] = reflectionCell{Text: "nil"}
+				continue
+			}
+			row[ci] = formatReflectValue(f)
+		}
+		ret.cells[i] = row
+	}
+	ret.update()
+	return ret
+}
+
+// parseReflectionFilter understands ">N", ">=N", "<N", "<=N", "=X", "!=X",
+// "~REGEX", "!~REGEX", anything else is case-insensitive substring
+func parseReflectionFilter(s string) (func(reflectionCell) bool, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	for _, op := range []string{">=", "<=", "!=", "!~", ">", "<", "==", "=", "~"} {
+		if !strings.HasPrefix(s, op) {
+			continue
+		}
+		arg := strings.TrimSpace(s[len(op):])
+		switch op {
+		case "~", "!~":
+			re, err := regexp.Compile(arg)
+			if err != nil {
+				return nil, err
+			}
+			neg := op == "!~"
+			return func(c reflectionCell) bool { return re.MatchString(c.Text) != neg }, nil
+		case "=", "==", "!=":
+			neg := op == "!="
+			n, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				return func(c reflectionCell) bool { return (c.Text == arg) != neg }, nil
+			}
+			return func(c reflectionCell) bool { return (c.IsNum && c.Num == n) != neg }, nil
+		}
+		n, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", arg)
+		}
+		return func(c reflectionCell) bool {
+			if !c.IsNum {
+				return false
+			}
+			switch op {
+			case ">=":
+				return c.Num >= n
+			case "<=":
+				return c.Num <= n
+			case ">":
+				return c.Num > n
+			default:
+				return c.Num < n
+			}
+		}, nil
+	}
+	lower := strings.ToLower(s)
+	return func(c reflectionCell) bool { return strings.Contains(strings.ToLower(c.Text), lower) }, nil
+}
+
+func compareReflectionCells(a, b reflectionCell) int {
+	if a.IsNum && b.IsNum {
+		if math.IsNaN(a.Num) || math.IsNaN(b.Num) {
+			return cmp.Compare(a.Text, b.Text)
+		}
+		return cmp.Compare(a.Num, b.Num)
+	}
+	return cmp.Compare(a.Text, b.Text)
+}
+
+// update applies filters and sorting
+func (t *reflectionTable) update() {
+	t.order = t.order[:0]
+rows:
+	for i, row := range t.cells {
+		for ci, c := range t.columns {
+			if c.filterFn != nil && !c.filterFn(row[ci]) {
+				continue rows
+			}
+		}
+		t.order = append(t.order, i)
+	}
+	if t.sortColumn < 0 {
+		return
+	}
+	slices.SortStableFunc(t.order, func(a, b int) int {
+		var r int
+		switch t.sortColumn {
+		case 0:
+			r = cmp.Compare(a, b)
+		case 1:
+			r = cmp.Compare(t.packets[a].CurrentTime, t.packets[b].CurrentTime)
+		default:
+			r = compareReflectionCells(t.cells[a][t.sortColumn-2], t.cells[b][t.sortColumn-2])
+		}
+		if t.sortDesc {
+			return -r
+		}
+		return r
+	})
+}
+
+func viewReflection(rpl *parsedReplay, packets []*wrpl.WRPLRawPacket) {
+	if len(packets) == 0 {
+		imgui.TextUnformatted("no packets?")
+		return
+	}
+	if packets[0] == nil {
+		imgui.TextUnformatted("first packet nil?")
+		return
+	}
+	if packets[0].Parsed == nil {
+		imgui.TextUnformatted("first packet parsed nil?")
+		return
+	}
+	if packets[0].Parsed.Data == nil {
+		imgui.TextUnformatted("first packet parsed data nil?")
+		return
+	}
+	if rpl.reflectionTables == nil {
+		rpl.reflectionTables = map[*wrpl.WRPLRawPacket]*reflectionTable{}
+	}
+	t, ok := rpl.reflectionTables[packets[0]]
+	if !ok {
+		t = newReflectionTable(packets)
+		rpl.reflectionTables[packets[0]] = t
+	}
+
+	imgui.TextUnformatted(fmt.Sprintf("%d of %d rows", len(t.order), len(t.packets)))
+	imgui.SameLine()
+	uiHelpMarker("Column filters: >N >=N <N <=N =X !=X ~regex !~regex, anything else is a substring.\nClick header to sort, double click row to open raw packet.")
+
+	tableFlags := imgui.TableFlagsRowBg |
+		imgui.TableFlagsBordersV |
+		imgui.TableFlagsBordersOuterH |
+		imgui.TableFlagsSizingFixedFit |
+		imgui.TableFlagsSortable |
+		imgui.TableFlagsSortTristate |
+		imgui.TableFlagsReorderable |
+		imgui.TableFlagsResizable |
+		imgui.TableFlagsScrollX |
+		imgui.TableFlagsScrollY
+	if imgui.BeginTableV("##context", 2+int32(len(t.columns)), tableFlags, imgui.Vec2{X: 0, Y: 0}, 0) {
+		imgui.TableSetupScrollFreeze(1, 2)
+		imgui.TableSetupColumn("num")
+		imgui.TableSetupColumn("time")
+		for _, c := range t.columns {
+			imgui.TableSetupColumn(c.Name)
+		}
+		imgui.TableHeadersRow()
+
+		if specs := imgui.TableGetSortSpecs(); specs != nil && specs.SpecsDirty() {
+			t.sortColumn = -1
+			if specs.SpecsCount() > 0 {
+				s := specs.Specs()
+				t.sortColumn = int(s.ColumnIndex())
+				t.sortDesc = s.SortDirection() != imgui.SortDirectionAscending
+			}
+			t.update()
+			specs.SetSpecsDirty(false)
+		}
+
+		imgui.TableNextRow()
+		imgui.TableNextColumn()
+		imgui.TableNextColumn()
+		for i, c := range t.columns {
+			imgui.TableNextColumn()
+			imgui.PushIDInt(int32(i))
+			imgui.SetNextItemWidth(-1)
+			if imgui.InputTextWithHint("##filter", "filter", &c.filter, 0, nil) {
+				c.filterFn, c.filterErr = parseReflectionFilter(c.filter)
+				t.update()
+			}
+			if c.filterErr != nil {
+				imgui.SetItemTooltip(strings.ReplaceAll(c.filterErr.Error(), "%", "%%"))
+			}
+			imgui.PopID()
+		}
+
+		clipper := imgui.NewListClipper()
+		clipper.Begin(int32(len(t.order)))
+		for clipper.Step() {
+			for oi := clipper.DisplayStart(); oi < clipper.DisplayEnd(); oi++ {
+				i := t.order[oi]
+				pk := t.packets[i]
+				imgui.TableNextRow()
+				imgui.TableNextColumn()
+				if imgui.SelectableBoolV(strconv.Itoa(i), t.selected == i, imgui.SelectableFlagsSpanAllColumns|imgui.SelectableFlagsAllowDoubleClick, imgui.Vec2{}) {
+					t.selected = i
+					if imgui.IsMouseDoubleClicked(imgui.MouseButtonLeft) {
+						openPacketInStream(rpl, pk)
+					}
+				}
+				imgui.TableNextColumn()
+				imgui.TextUnformatted(pk.Time().String())
+				for _, c := range t.cells[i] {
+					imgui.TableNextColumn()
+					imgui.TextUnformatted(c.Text)
+				}
+			}
+		}
+		clipper.End()
+		imgui.EndTable()
+	}
+}
+
+// openPacketInStream pins packet stream window positioned at the packet
+func openPacketInStream(rpl *parsedReplay, pk *wrpl.WRPLRawPacket) {
+	idx, ok := rpl.packetIndex[pk]
+	if !ok {
+		return
+	}
+	rpl.PinnedFindings = append(rpl.PinnedFindings, pinnedFinding{
+		Packets:   rpl.Replay.Packets,
+		InitialID: int32(idx),
+		CurrentID: int32(idx),
+	})
+}
@@ -0,0 +1,133 @@
+/*
+	wrpl-inspector: War Thunder replay inspection software
+	Copyright (C) 2025 flexcoral
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published
+	by the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/AllenDang/cimgui-go/imgui"
+	"github.com/maxsupermanhd/wrpl-inspector/pktschema"
+	"github.com/maxsupermanhd/wrpl-inspector/wrpl"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	pktSchemaPath      = flag.String("schema", "packets.schema", "packet layout templates file, reloaded on change")
+	pktSchema          *pktschema.Schema
+	pktSchemaErr       error
+	pktSchemaModTime   time.Time
+	pktSchemaCheckedAt time.Time
+	pktSchemaDecoded   = map[*wrpl.WRPLRawPacket]*schemaDecodeResult{}
+	pktSchemaDecodedAt time.Time
+)
+
+// schemaDecodeResult is decoded packet kept until schema file changes
+type schemaDecodeResult struct {
+	def    *pktschema.PacketDef
+	fields []*pktschema.Field
+	err    error
+}
+
+func reloadPacketSchema() {
+	if time.Since(pktSchemaCheckedAt) < time.Second {
+		return
+	}
+	pktSchemaCheckedAt = time.Now()
+	st, err := os.Stat(*pktSchemaPath)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			pktSchemaErr = err
+		}
+		return
+	}
+	if st.ModTime().Equal(pktSchemaModTime) {
+		return
+	}
+	pktSchemaModTime = st.ModTime()
+	s, err := pktschema.ParseFile(*pktSchemaPath)
+	pktSchemaErr = err
+	if err != nil {
+		log.Err(err).Str("path", *pktSchemaPath).Msg("loading packet schema")
+		return
+	}
+	log.Info().Str("path", *pktSchemaPath).Int("packets", len(s.Packets)).Msg("loaded packet schema")
+	pktSchema = s
+}
+
+func uiShowSchemaDecode(pk *wrpl.WRPLRawPacket) {
+	if pktSchemaErr != nil {
+		imgui.PushTextWrapPos()
+		imgui.TextUnformatted("Schema error: " + pktSchemaErr.Error())
+		imgui.PopTextWrapPos()
+	}
+	if !pktSchemaDecodedAt.Equal(pktSchemaModTime) {
+		clear(pktSchemaDecoded)
+		pktSchemaDecodedAt = pktSchemaModTime
+	}
+	res, ok := pktSchemaDecoded[pk]
+	if !ok {
+		res = &schemaDecodeResult{def: pktSchema.Match(pk.PacketType, pk.PacketPayload)}
+		if res.def != nil {
+			res.fields, res.err = res.def.Decode(pk.PacketPayload)
+		}
+		pktSchemaDecoded[pk] = res
+	}
+	if res.def == nil {
+		imgui.TextDisabled("no schema matched")
+		return
+	}
+	imgui.TextUnformatted("Schema: " + res.def.Name)
+	if res.err != nil {
+		imgui.PushTextWrapPos()
+		imgui.TextUnformatted("Decode error: " + res.err.Error())
+		imgui.PopTextWrapPos()
+	}
+	tableFlags := imgui.TableFlagsRowBg | imgui.TableFlagsBordersV | imgui.TableFlagsBordersOuterH | imgui.TableFlagsSizingFixedFit | imgui.TableFlagsScrollY | imgui.TableFlagsScrollX
+	if imgui.BeginTableV("##schema fields", 4, tableFlags, imgui.ContentRegionAvail(), 0) {
+		imgui.TableSetupScrollFreeze(0, 1)
+		imgui.TableSetupColumn("name")
+		imgui.TableSetupColumn("type")
+		imgui.TableSetupColumn("offset")
+		imgui.TableSetupColumn("value")
+		imgui.TableHeadersRow()
+		uiShowSchemaFields(res.fields, "")
+		imgui.EndTable()
+	}
+}
+
+func uiShowSchemaFields(fields []*pktschema.Field, indent string) {
+	for _, f := range fields {
+		imgui.TableNextRow()
+		offset := fmt.Sprintf("%d", f.BitStart/8)
+		if f.BitStart%8 != 0 || f.BitEnd%8 != 0 {
+			offset = fmt.Sprintf("%d.%d+%db", f.BitStart/8, f.BitStart%8, f.BitEnd-f.BitStart)
+		}
+		if f.InBlob {
+			offset = "blob " + offset
+		}
+		uiTableRowStrings(indent+f.Name, f.Kind, offset, f.String())
+		if len(f.Children) > 0 {
+			uiShowSchemaFields(f.Children, indent+"  ")
+		}
+	}
+}
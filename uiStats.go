@@ -0,0 +1,310 @@
+/*
+	wrpl-inspector: War Thunder replay inspection software
+	Copyright (C) 2025 flexcoral
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published
+	by the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AllenDang/cimgui-go/imgui"
+	"github.com/AllenDang/cimgui-go/implot"
+	"github.com/maxsupermanhd/wrpl-inspector/wrpl"
+	"github.com/rs/zerolog/log"
+)
+
+const libraryStatsExportDir = "stats"
+
+var libraryStatsCharts = []string{"win rate by map", "battles by map", "average length by map", "win rate by vehicle", "k/d over time"}
+
+type libraryStatsState struct {
+	lock sync.Mutex
+
+	workers int32
+	chart   int32
+	player  int32
+
+	generation int
+	cancel     context.CancelFunc
+	running    bool
+	started    time.Time
+	took       time.Duration
+	err        error
+
+	total   int
+	scanned int
+	stats   *wrpl.Stats
+	tables  []*wrpl.StatsTable
+	players []*wrpl.StatsGroup
+	dirty   bool
+}
+
+var libraryStats = &libraryStatsState{
+	workers: int32(runtime.GOMAXPROCS(0)),
+}
+
+// discoveredBattles groups discovered server replay parts by session,
+// client replays are battles on their own
+func discoveredBattles() [][]string {
+	ret := [][]string{}
+	sessions := map[string]int{}
+	for _, v := range wrplDiscoveryFound {
+		if !v.wrplHeader.IsServer() {
+			ret = append(ret, []string{v.wrplPath})
+			continue
+		}
+		if i, ok := sessions[v.sessionID]; ok {
+			ret[i] = append(ret[i], v.wrplPath)
+		} else {
+			sessions[v.sessionID] = len(ret)
+			ret = append(ret, []string{v.wrplPath})
+		}
+	}
+	return ret
+}
+
+func (s *libraryStatsState) start(battles [][]string) {
+	s.stop()
+	s.generation++
+	s.err = nil
+	s.total = len(battles)
+	s.scanned = 0
+	s.stats = wrpl.NewStats()
+	s.dirty = true
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.running = true
+	s.started = time.Now()
+	gen := s.generation
+	workers := int(s.workers)
+	go func() {
+		err := wrpl.AggregateLibrary(ctx, battles, workers, func(r wrpl.BattleReport) {
+			s.lock.Lock()
+			defer s.lock.Unlock()
+			if s.generation != gen {
+				return
+			}
+			s.scanned++
+			s.dirty = true
+			if r.Err != nil {
+				s.stats.Failed++
+				log.Debug().Err(r.Err).Strs("paths", r.Paths).Msg("library stats")
+				return
+			}
+			s.stats.Add(r.Summary)
+		})
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		if s.generation != gen {
+			return
+		}
+		if err != nil && !errors.Is(err, context.Canceled) {
+			s.err = err
+		}
+		s.running = false
+		s.took = time.Since(s.started)
+		cancel()
+	}()
+}
+
+func (s *libraryStatsState) stop() {
+	if s.cancel != nil {
+		s.cancel()
+		s.cancel = nil
+	}
+	if s.running {
+		s.running = false
+		s.took = time.Since(s.started)
+	}
+}
+
+func (s *libraryStatsState) exportCSV() error {
+	err := os.MkdirAll(libraryStatsExportDir, 0755)
+	if err != nil {
+		return err
+	}
+	for _, t := range s.tables {
+		f, err := os.Create(filepath.Join(libraryStatsExportDir, strings.ReplaceAll(t.Name, " ", "_")+".csv"))
+		if err != nil {
+			return err
+		}
+		err = t.WriteCSV(f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	log.Info().Str("dir", libraryStatsExportDir).Int("reports", len(s.tables)).Msg("exported library stats")
+	return nil
+}
+
+func uiShowLibraryStats() {
+	if !imgui.CollapsingHeaderTreeNodeFlags("Library statistics") {
+		return
+	}
+	s := libraryStats
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	imgui.SetNextItemWidth(90)
+	if imgui.InputInt("workers##libraryStats", &s.workers) {
+		s.workers = max(1, s.workers)
+	}
+	imgui.SameLine()
+	if s.running {
+		if imgui.Button("cancel##libraryStats") {
+			s.stop()
+		}
+	} else if imgui.Button("aggregate##libraryStats") {
+		s.start(discoveredBattles())
+	}
+	if s.stats != nil {
+		imgui.SameLine()
+		if imgui.Button("export csv##libraryStats") {
+			s.err = s.exportCSV()
+		}
+	}
+	imgui.SameLine()
+	uiHelpMarker("Parses every replay listed below, server replay parts are joined by session.\nWin rate counts battles whose results name the outcome and for vehicles and players only battles they recorded,\nk/d comes from kills and lineups unless results list kills and deaths of the player.\nCSV files are written to the \"" + libraryStatsExportDir + "\" directory, same reports are printed by tools/wrpl-stats.")
+
+	if s.err != nil {
+		imgui.TextUnformatted("Error: " + s.err.Error())
+	}
+	if s.stats == nil {
+		return
+	}
+	if s.running {
+		imgui.ProgressBarV(float32(s.scanned)/float32(max(s.total, 1)), imgui.NewVec2(-1, 0), fmt.Sprintf("%d/%d", s.scanned, s.total))
+	}
+	if s.dirty {
+		s.tables = s.stats.Tables()
+		s.players = s.stats.Players()
+		s.dirty = false
+	}
+	took := s.took
+	if s.running {
+		took = time.Since(s.started)
+	}
+	st := s.stats
+	imgui.TextUnformatted(fmt.Sprintf("%d battles, %d failed to parse, %d wins, %d losses, average length %s, took %s",
+		st.Battles, st.Failed, st.Wins, st.Losses, st.AvgDuration().Round(time.Second), took.Round(time.Millisecond)))
+
+	if imgui.BeginChildStrV("##library stats", imgui.NewVec2(0, 400), imgui.ChildFlagsResizeY|imgui.ChildFlagsBorders, 0) {
+		if imgui.BeginTabBar("libraryStatsTabs") {
+			if imgui.BeginTabItem("charts") {
+				uiShowLibraryStatsCharts(s)
+				imgui.EndTabItem()
+			}
+			for _, t := range s.tables {
+				if imgui.BeginTabItem(t.Name) {
+					uiShowStatsTable(t)
+					imgui.EndTabItem()
+				}
+			}
+			imgui.EndTabBar()
+		}
+	}
+	imgui.EndChild()
+}
+
+func uiShowStatsTable(t *wrpl.StatsTable) {
+	tableFlags := imgui.TableFlagsRowBg | imgui.TableFlagsBordersV | imgui.TableFlagsBordersOuterH | imgui.TableFlagsSizingFixedFit | imgui.TableFlagsScrollY | imgui.TableFlagsScrollX
+	if imgui.BeginTableV("##stats "+t.Name, int32(len(t.Columns)), tableFlags, imgui.Vec2{}, 0) {
+		imgui.TableSetupScrollFreeze(0, 1)
+		for _, c := range t.Columns {
+			imgui.TableSetupColumn(c)
+		}
+		imgui.TableHeadersRow()
+		clipper := imgui.NewListClipper()
+		clipper.Begin(int32(len(t.Rows)))
+		for clipper.Step() {
+			for i := clipper.DisplayStart(); i < clipper.DisplayEnd(); i++ {
+				imgui.TableNextRow()
+				uiTableRowStrings(t.Rows[i]...)
+			}
+		}
+		clipper.End()
+		imgui.EndTable()
+	}
+}
+
+// uiPlotLabeledBars draws one bar per group with group keys as ticks
+func uiPlotLabeledBars(id, label string, groups []*wrpl.StatsGroup, value func(*wrpl.StatsGroup) float64) {
+	if len(groups) == 0 {
+		imgui.TextUnformatted("nothing to plot")
+		return
+	}
+	xs := make([]float32, len(groups))
+	ys := make([]float32, len(groups))
+	ticks := make([]float64, len(groups))
+	labels := make([]string, len(groups))
+	for i, g := range groups {
+		xs[i] = float32(i)
+		ys[i] = float32(value(g))
+		ticks[i] = float64(i)
+		labels[i] = g.Key
+	}
+	if implot.BeginPlotV(id, imgui.NewVec2(-1, -1), implot.FlagsNoLegend) {
+		implot.SetupAxisV(implot.AxisX1, "", implot.AxisFlagsAutoFit)
+		implot.SetupAxisV(implot.AxisY1, label, implot.AxisFlagsAutoFit)
+		implot.SetupAxisTicksdoublePtrV(implot.AxisX1, &ticks[0], int32(len(ticks)), labels, false)
+		implot.PlotBarsFloatPtrFloatPtr(label, &xs[0], &ys[0], int32(len(xs)), 0.7)
+		implot.EndPlot()
+	}
+}
+
+func uiShowLibraryStatsCharts(s *libraryStatsState) {
+	imgui.SetNextItemWidth(200)
+	imgui.ComboStrarr("##libraryStatsChart", &s.chart, libraryStatsCharts, int32(len(libraryStatsCharts)))
+	switch s.chart {
+	case 0:
+		uiPlotLabeledBars("##win rate by map", "win rate %", s.stats.Maps(), (*wrpl.StatsGroup).WinRate)
+	case 1:
+		uiPlotLabeledBars("##battles by map", "battles", s.stats.Maps(), func(g *wrpl.StatsGroup) float64 { return float64(g.Battles) })
+	case 2:
+		uiPlotLabeledBars("##length by map", "minutes", s.stats.Maps(), func(g *wrpl.StatsGroup) float64 { return g.AvgDuration().Minutes() })
+	case 3:
+		uiPlotLabeledBars("##win rate by vehicle", "win rate %", s.stats.Vehicles(), (*wrpl.StatsGroup).WinRate)
+	case 4:
+		if len(s.players) == 0 {
+			imgui.TextUnformatted("no players")
+			return
+		}
+		s.player = min(s.player, int32(len(s.players)-1))
+		imgui.SameLine()
+		imgui.SetNextItemWidth(200)
+		if imgui.BeginCombo("##libraryStatsPlayer", s.players[s.player].Key) {
+			for i, p := range s.players {
+				if imgui.SelectableBool(p.Key + " (" + strconv.Itoa(p.Battles) + " battles)##" + strconv.Itoa(i)) {
+					s.player = int32(i)
+				}
+			}
+			imgui.EndCombo()
+		}
+		uiPlotLabeledBars("##kd over time", "k/d", s.stats.PlayerTimeline(s.players[s.player].Key), (*wrpl.StatsGroup).KD)
+	}
+}
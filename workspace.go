@@ -0,0 +1,377 @@
+/*
+	wrpl-inspector: War Thunder replay inspection software
+	Copyright (C) 2025 flexcoral
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published
+	by the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/AllenDang/cimgui-go/imgui"
+	"github.com/maxsupermanhd/wrpl-inspector/wrpl"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	replaySourceFile   = "file"
+	replaySourceFolder = "folder"
+
+	defaultByteInterpFilter = "^00ff0f81(........)"
+)
+
+var (
+	workspacePath = flag.String("workspace", "workspace.json", "workspace file, loaded on start and saved on exit (empty to disable)")
+	workspaceErr  error
+	// named byte interpreter presets, shared between all replays
+	byteInterpPresets = []byteInterpPreset{}
+	// replays of loaded workspace that failed to open, written back on save
+	// unchanged so that they are not lost when file is moved or unreadable
+	workspaceUnopened = []workspaceUnopenedReplay{}
+)
+
+// replaySource is enough to open replay again
+type replaySource struct {
+	Kind string `json:"kind"`
+	Path string `json:"path"`
+}
+
+type workspace struct {
+	Replays []workspaceReplay `json:"replays"`
+	// pinned packet windows, Replay is index in Replays
+	PinnedPackets []workspacePacketRef `json:"pinnedPackets,omitempty"`
+	Presets       []byteInterpPreset   `json:"presets,omitempty"`
+}
+
+type workspaceReplay struct {
+	Source          replaySource       `json:"source"`
+	Search          workspaceSearch    `json:"search"`
+	PinnedFindings  []workspaceFinding `json:"pinnedFindings,omitempty"`
+	ByteInterpreter byteInterpPreset   `json:"byteInterpreter"`
+}
+
+type workspaceSearch struct {
+	Subset             int32  `json:"subset"`
+	Term               string `json:"term"`
+	Mode               int32  `json:"mode"`
+	ViewMode           int32  `json:"viewMode"`
+	ViewPacketID       int32  `json:"viewPacketID"`
+	EnableFilterByType bool   `json:"enableFilterByType,omitempty"`
+	FilterByType       int32  `json:"filterByType,omitempty"`
+}
+
+// workspaceFinding stores packets of pinned finding as indices in the subset
+type workspaceFinding struct {
+	Subset    int32 `json:"subset"`
+	Packets   []int `json:"packets"`
+	InitialID int32 `json:"initialID"`
+	CurrentID int32 `json:"currentID"`
+	ViewMode  int32 `json:"viewMode"`
+}
+
+type workspacePacketRef struct {
+	Replay int   `json:"replay"`
+	Subset int32 `json:"subset"`
+	Packet int   `json:"packet"`
+}
+
+// workspaceUnopenedReplay keeps pinned packets of the replay, Replay of the
+// refs is updated to the new index on save
+type workspaceUnopenedReplay struct {
+	replay        workspaceReplay
+	pinnedPackets []workspacePacketRef
+}
+
+type byteInterpPreset struct {
+	Name   string                   `json:"name,omitempty"`
+	Filter string                   `json:"filter"`
+	Series []byteInterpPresetSeries `json:"series,omitempty"`
+}
+
+type byteInterpPresetSeries struct {
+	Group     string `json:"group"`
+	Type      string `json:"type"`
+	BitOffset int32  `json:"bitOffset,omitempty"`
+	BitWidth  int32  `json:"bitWidth,omitempty"`
+	FracBits  int32  `json:"fracBits,omitempty"`
+	IsX       bool   `json:"isX,omitempty"`
+}
+
+func (dat *uiByteInterpreterData) preset(name string) byteInterpPreset {
+	ret := byteInterpPreset{Name: name, Filter: dat.beFilter}
+	for _, s := range dat.beSeries {
+		ret.Series = append(ret.Series, byteInterpPresetSeries{
+			Group:     s.Group,
+			Type:      beInterpretTypeNames[s.Type],
+			BitOffset: s.BitOffset,
+			BitWidth:  s.BitWidth,
+			FracBits:  s.FracBits,
+			IsX:       s.IsX,
+		})
+	}
+	return ret
+}
+
+func (dat *uiByteInterpreterData) applyPreset(p byteInterpPreset) {
+	dat.beFilter = p.Filter
+	dat.beSeries = []*beSeries{}
+	for _, s := range p.Series {
+		t := slices.Index(beInterpretTypeNames, s.Type)
+		if t < 0 {
+			log.Warn().Str("type", s.Type).Str("group", s.Group).Msg("unknown byte interpreter type in preset")
+			t = int(beInterpretTypeFloat4)
+		}
+		dat.beSeries = append(dat.beSeries, &beSeries{
+			Group:     s.Group,
+			Type:      int32(t),
+			BitOffset: s.BitOffset,
+			BitWidth:  s.BitWidth,
+			FracBits:  s.FracBits,
+			IsX:       s.IsX,
+		})
+	}
+	dat.beProcessed = false
+}
+
+// replaySubset returns packet list that search subset index refers to
+func replaySubset(rpl *parsedReplay, subset int32) []*wrpl.WRPLRawPacket {
+	switch subset {
+	case 1:
+		return rpl.ParsingFailedPackets
+	case 2:
+		return rpl.SlotMessages
+	case 3:
+		return rpl.ECSMessages
+	default:
+		return rpl.Replay.Packets
+	}
+}
+
+func packetIndices(subset []*wrpl.WRPLRawPacket, packets []*wrpl.WRPLRawPacket) []int {
+	idx := make(map[*wrpl.WRPLRawPacket]int, len(subset))
+	for i, pk := range subset {
+		idx[pk] = i
+	}
+	ret := make([]int, 0, len(packets))
+	for _, pk := range packets {
+		if i, ok := idx[pk]; ok {
+			ret = append(ret, i)
+		}
+	}
+	return ret
+}
+
+func saveWorkspace(path string) error {
+	openReplaysLock.Lock()
+	ws := workspace{
+		Replays: []workspaceReplay{},
+		Presets: byteInterpPresets,
+	}
+	for _, rpl := range openReplays {
+		if rpl.Source.Kind == "" {
+			continue
+		}
+		wr := workspaceReplay{
+			Source:          rpl.Source,
+			ByteInterpreter: rpl.beData.preset(""),
+		}
+		if dat := rpl.uiPacketInspect; dat != nil {
+			wr.Search = workspaceSearch{
+				Subset:             dat.Subset,
+				Term:               dat.SearchTerm,
+				Mode:               dat.SearchMode,
+				ViewMode:           dat.ViewMode,
+				ViewPacketID:       dat.ViewPacketID,
+				EnableFilterByType: dat.EnableFilterByType,
+				FilterByType:       dat.FilterByType,
+			}
+		}
+		for _, f := range rpl.PinnedFindings {
+			wr.PinnedFindings = append(wr.PinnedFindings, workspaceFinding{
+				Subset:    f.Subset,
+				Packets:   packetIndices(replaySubset(rpl, f.Subset), f.Packets),
+				InitialID: f.InitialID,
+				CurrentID: f.CurrentID,
+				ViewMode:  f.ViewingPacketListingMode,
+			})
+		}
+		ri := len(ws.Replays)
+		ws.Replays = append(ws.Replays, wr)
+		for _, pk := range pinnedPacketsByContent {
+			for subset := range int32(len(uiPacketInspectSubsetNames)) {
+				if i := slices.Index(replaySubset(rpl, subset), pk); i >= 0 {
+					ws.PinnedPackets = append(ws.PinnedPackets, workspacePacketRef{Replay: ri, Subset: subset, Packet: i})
+					break
+				}
+			}
+		}
+	}
+	openReplaysLock.Unlock()
+	for _, u := range workspaceUnopened {
+		// opened again by hand since workspace was loaded
+		if slices.ContainsFunc(ws.Replays, func(wr workspaceReplay) bool { return wr.Source == u.replay.Source }) {
+			continue
+		}
+		ri := len(ws.Replays)
+		ws.Replays = append(ws.Replays, u.replay)
+		for _, ref := range u.pinnedPackets {
+			ref.Replay = ri
+			ws.PinnedPackets = append(ws.PinnedPackets, ref)
+		}
+	}
+	buf, err := json.MarshalIndent(ws, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf, 0644)
+}
+
+func loadWorkspace(path string) error {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	ws := workspace{}
+	err = json.Unmarshal(buf, &ws)
+	if err != nil {
+		return fmt.Errorf("decoding workspace: %w", err)
+	}
+	byteInterpPresets = ws.Presets
+	opened := make([]*parsedReplay, len(ws.Replays))
+	// tabs are prepended, open in reverse to keep the order
+	for i := len(ws.Replays) - 1; i >= 0; i-- {
+		wr := ws.Replays[i]
+		rpl, err := openReplaySource(wr.Source)
+		if err != nil {
+			log.Err(err).Str("kind", wr.Source.Kind).Str("path", wr.Source.Path).Msg("opening workspace replay")
+			continue
+		}
+		opened[i] = rpl
+		rpl.uiPacketInspect = &uiPacketInspectData{
+			Subset:             wr.Search.Subset,
+			SearchTerm:         wr.Search.Term,
+			SearchMode:         wr.Search.Mode,
+			ViewMode:           wr.Search.ViewMode,
+			ViewPacketID:       wr.Search.ViewPacketID,
+			EnableFilterByType: wr.Search.EnableFilterByType,
+			FilterByType:       wr.Search.FilterByType,
+		}
+		if wr.ByteInterpreter.Filter != "" {
+			rpl.beData.applyPreset(wr.ByteInterpreter)
+		}
+		rpl.PinnedFindings = nil
+		for _, f := range wr.PinnedFindings {
+			subset := replaySubset(rpl, f.Subset)
+			pf := pinnedFinding{
+				Subset:                   f.Subset,
+				InitialID:                f.InitialID,
+				CurrentID:                f.CurrentID,
+				ViewingPacketListingMode: f.ViewMode,
+			}
+			for _, pi := range f.Packets {
+				if pi >= 0 && pi < len(subset) {
+					pf.Packets = append(pf.Packets, subset[pi])
+				}
+			}
+			rpl.PinnedFindings = append(rpl.PinnedFindings, pf)
+		}
+	}
+	workspaceUnopened = []workspaceUnopenedReplay{}
+	unopened := map[int]int{}
+	for i, rpl := range opened {
+		if rpl == nil {
+			unopened[i] = len(workspaceUnopened)
+			workspaceUnopened = append(workspaceUnopened, workspaceUnopenedReplay{replay: ws.Replays[i]})
+		}
+	}
+	for _, ref := range ws.PinnedPackets {
+		if u, ok := unopened[ref.Replay]; ok {
+			workspaceUnopened[u].pinnedPackets = append(workspaceUnopened[u].pinnedPackets, ref)
+			continue
+		}
+		if ref.Replay < 0 || ref.Replay >= len(opened) {
+			continue
+		}
+		subset := replaySubset(opened[ref.Replay], ref.Subset)
+		if ref.Packet >= 0 && ref.Packet < len(subset) && !slices.Contains(pinnedPacketsByContent, subset[ref.Packet]) {
+			pinnedPacketsByContent = append(pinnedPacketsByContent, subset[ref.Packet])
+		}
+	}
+	return nil
+}
+
+func uiShowWorkspaceControls() {
+	if *workspacePath == "" {
+		return
+	}
+	imgui.AlignTextToFramePadding()
+	imgui.TextUnformatted("Workspace " + *workspacePath)
+	imgui.SameLine()
+	if imgui.Button("save##workspace") {
+		workspaceErr = saveWorkspace(*workspacePath)
+	}
+	imgui.SameLine()
+	if imgui.Button("load##workspace") {
+		workspaceErr = loadWorkspace(*workspacePath)
+	}
+	imgui.SameLine()
+	uiHelpMarker("Workspace keeps open replays, searches, pinned packets and byte interpreter presets.\nIt is loaded on start and saved on exit, file can be shared as long as replay paths are the same.")
+	if workspaceErr != nil {
+		imgui.TextUnformatted("Error: " + workspaceErr.Error())
+	}
+}
+
+var byteInterpPresetName string
+
+func uiShowByteInterpreterPresets(dat *uiByteInterpreterData) {
+	imgui.AlignTextToFramePadding()
+	imgui.TextUnformatted("Presets:")
+	toDelete := -1
+	for i, p := range byteInterpPresets {
+		imgui.SameLine()
+		imgui.PushIDInt(int32(i))
+		if imgui.SmallButton(p.Name) {
+			dat.applyPreset(p)
+		}
+		if imgui.BeginPopupContextItem() {
+			if imgui.MenuItemBool("delete") {
+				toDelete = i
+			}
+			imgui.EndPopup()
+		}
+		imgui.PopID()
+	}
+	if toDelete >= 0 {
+		byteInterpPresets = slices.Delete(byteInterpPresets, toDelete, toDelete+1)
+	}
+	imgui.SameLine()
+	imgui.SetNextItemWidth(150)
+	imgui.InputTextWithHint("##preset name", "preset name", &byteInterpPresetName, 0, nil)
+	imgui.SameLine()
+	if imgui.Button("save preset") && byteInterpPresetName != "" {
+		p := dat.preset(byteInterpPresetName)
+		i := slices.IndexFunc(byteInterpPresets, func(v byteInterpPreset) bool { return v.Name == p.Name })
+		if i >= 0 {
+			byteInterpPresets[i] = p
+		} else {
+			byteInterpPresets = append(byteInterpPresets, p)
+		}
+	}
+}
@@ -0,0 +1,371 @@
+/*
+	wrpl: War Thunder replay parsing library (golang)
+	Copyright (C) 2025 flexcoral
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published
+	by the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package wrpl
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Anonymizer replaces player identities with pseudonyms. Mapping is kept
+// between replays, so the same player gets the same pseudonym in every
+// replay anonymized by one Anonymizer, and can be saved to (private) json.
+//
+// Covered are player init slot messages (user id, name, clan tag, title),
+// chat packets (sender, content) and settings and results BLK (known names,
+// clan tags and user ids, values of user id keys). Identities in packets
+// parser does not know about (ECS, unknown MPI) are left as is.
+type Anonymizer struct {
+	Names    map[string]string `json:"names"`
+	UserIDs  map[uint32]uint32 `json:"userIds"`
+	ClanTags map[string]string `json:"clanTags"`
+	// KeepChatContent keeps chat messages with known names and clan tags
+	// replaced, otherwise content is removed
+	KeepChatContent bool `json:"-"`
+}
+
+func NewAnonymizer() *Anonymizer {
+	return &Anonymizer{
+		Names:    map[string]string{},
+		UserIDs:  map[uint32]uint32{},
+		ClanTags: map[string]string{},
+	}
+}
+
+// LoadAnonymizer reads mapping saved with Save, missing file gives empty mapping
+func LoadAnonymizer(path string) (*Anonymizer, error) {
+	ret := NewAnonymizer()
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ret, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(b, ret)
+	if err != nil {
+		return nil, fmt.Errorf("parsing anonymizer mapping: %w", err)
+	}
+	return ret, nil
+}
+
+func (a *Anonymizer) Save(path string) error {
+	b, err := json.MarshalIndent(a, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0600)
+}
+
+// Name returns pseudonym of the player name, empty name stays empty
+func (a *Anonymizer) Name(real string) string {
+	if real == "" {
+		return ""
+	}
+	if ret, ok := a.Names[real]; ok {
+		return ret
+	}
+	ret := "player" + strconv.Itoa(len(a.Names)+1)
+	a.Names[real] = ret
+	return ret
+}
+
+// UserID returns pseudonym of the user id, zero stays zero
+func (a *Anonymizer) UserID(real uint32) uint32 {
+	if real == 0 {
+		return 0
+	}
+	if ret, ok := a.UserIDs[real]; ok {
+		return ret
+	}
+	ret := uint32(len(a.UserIDs) + 1)
+	a.UserIDs[real] = ret
+	return ret
+}
+
+// ClanTag returns pseudonym of the clan tag, empty tag stays empty
+func (a *Anonymizer) ClanTag(real string) string {
+	if real == "" {
+		return ""
+	}
+	if ret, ok := a.ClanTags[real]; ok {
+		return ret
+	}
+	ret := "clan" + strconv.Itoa(len(a.ClanTags)+1)
+	a.ClanTags[real] = ret
+	return ret
+}
+
+// replaceKnown replaces known names and clan tags in free text
+func (a *Anonymizer) replaceKnown(text string) string {
+	type pair struct{ real, fake string }
+	pairs := []pair{}
+	for real, fake := range a.Names {
+		pairs = append(pairs, pair{real, fake})
+	}
+	for real, fake := range a.ClanTags {
+		pairs = append(pairs, pair{real, fake})
+	}
+	// longer first so that names containing other names win
+	slices.SortFunc(pairs, func(x, y pair) int {
+		return cmp.Or(cmp.Compare(len(y.real), len(x.real)), strings.Compare(x.real, y.real))
+	})
+	args := []string{}
+	for _, p := range pairs {
+		args = append(args, p.real, p.fake)
+	}
+	return strings.NewReplacer(args...).Replace(text)
+}
+
+// Anonymize rewrites identities in the replay and parses it again.
+// Players are registered in slot order first so that pseudonyms do not
+// depend on the order of packets.
+func (a *Anonymizer) Anonymize(rpl *WRPL) error {
+	if rpl.Parsed == nil {
+		ParsePacketStream(rpl)
+	}
+	for _, p := range rpl.Parsed.Players {
+		if p != nil {
+			a.Name(p.Name)
+			a.UserID(p.UserID)
+			a.ClanTag(p.ClanTag)
+		}
+	}
+	for i, pk := range rpl.Packets {
+		var payload []byte
+		var err error
+		switch PacketType(pk.PacketType) {
+		case PacketTypeChat:
+			payload, err = a.anonymizeChat(pk.PacketPayload)
+		case PacketTypeMPI:
+			if bytes.HasPrefix(pk.PacketPayload, []byte{0x02, 0x58, 0x2d, 0xf0}) || bytes.HasPrefix(pk.PacketPayload, []byte{0x02, 0x58, 0xaa, 0xff}) {
+				payload, err = rewriteSlotMessages(pk.PacketPayload, a.anonymizePlayerInit)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("packet %d: %w", i, err)
+		}
+		if payload != nil {
+			pk.PacketPayload = payload
+		}
+	}
+	rw := blkRewriter{Str: a.anonymizeBlkString, Num: a.anonymizeBlkNumber}
+	if len(rpl.SettingsBLK) > 0 {
+		b, err := rewriteBlk(rpl.SettingsBLK, rw)
+		if err != nil {
+			return fmt.Errorf("settings blk: %w", err)
+		}
+		rpl.SettingsBLK = b
+		rpl.Settings, rpl.SettingsJSON = parseBlkJSON(b)
+	}
+	if len(rpl.ResultsBLK) > 0 {
+		b, err := rewriteBlk(rpl.ResultsBLK, rw)
+		if err != nil {
+			return fmt.Errorf("results blk: %w", err)
+		}
+		rpl.ResultsBLK = b
+		rpl.Results, rpl.ResultsJSON = parseBlkJSON(b)
+	}
+	ParsePacketStream(rpl)
+	return nil
+}
+
+func parseBlkJSON(b []byte) (map[string]any, string) {
+	ret, err := ParseBlk(b)
+	if err != nil {
+		return nil, ""
+	}
+	readable, _ := json.MarshalIndent(ret, "", "\t")
+	return ret, string(readable)
+}
+
+func isUserIDKey(key string) bool {
+	key = strings.ToLower(key)
+	return strings.Contains(key, "userid") || key == "uid"
+}
+
+func (a *Anonymizer) anonymizeBlkString(key, s string) (string, bool) {
+	if fake, ok := a.Names[s]; ok {
+		return fake, true
+	}
+	if fake, ok := a.ClanTags[s]; ok {
+		return fake, true
+	}
+	if id, err := strconv.ParseUint(s, 10, 32); err == nil && id != 0 {
+		if _, ok := a.UserIDs[uint32(id)]; ok || isUserIDKey(key) {
+			return strconv.FormatUint(uint64(a.UserID(uint32(id))), 10), true
+		}
+	}
+	return "", false
+}
+
+func (a *Anonymizer) anonymizeBlkNumber(key string, v int64) (int64, bool) {
+	if !isUserIDKey(key) || v <= 0 || v > 0xffffffff {
+		return 0, false
+	}
+	return int64(a.UserID(uint32(v))), true
+}
+
+func appendLenString(b []byte, s string) []byte {
+	s = s[:min(len(s), 0xff)]
+	return append(append(b, byte(len(s))), s...)
+}
+
+func (a *Anonymizer) anonymizeChat(payload []byte) ([]byte, error) {
+	r := bytes.NewReader(payload)
+	sender, err := PacketReadLenString(r)
+	if err != nil {
+		return nil, err
+	}
+	content, err := PacketReadLenString(r)
+	if err != nil {
+		return nil, err
+	}
+	fake := a.replaceKnown(sender)
+	if fake == sender {
+		fake = a.Name(sender)
+	}
+	if a.KeepChatContent {
+		content = a.replaceKnown(content)
+	} else {
+		content = ""
+	}
+	ret := appendLenString(nil, fake)
+	ret = appendLenString(ret, content)
+	return append(ret, payload[len(payload)-r.Len():]...), nil
+}
+
+// anonymizePlayerInit returns nil if message is not a player init
+func (a *Anonymizer) anonymizePlayerInit(slot byte, msg []byte) []byte {
+	if !isSlotMessagePlayerInit(msg) {
+		return nil
+	}
+	nameAt := slotMessageHeaderSize + 8
+	tagAt := nameAt + SlotPlayerNameSize + SlotPlayerSkipSize
+	if len(msg) < tagAt {
+		return nil
+	}
+	r := bytes.NewReader(msg[tagAt:])
+	clanTag, err := PacketReadLenString(r)
+	if err != nil {
+		return nil
+	}
+	_, err = PacketReadLenString(r)
+	if err != nil {
+		return nil
+	}
+	ret := bytes.Clone(msg[:tagAt])
+	if binary.LittleEndian.Uint32(ret[slotMessageHeaderSize+4:]) != 0 {
+		// parser does not take these as players either
+		return nil
+	}
+	userID := binary.LittleEndian.Uint32(ret[slotMessageHeaderSize:])
+	binary.LittleEndian.PutUint32(ret[slotMessageHeaderSize:], a.UserID(userID))
+	name := ret[nameAt : nameAt+SlotPlayerNameSize]
+	fake := a.Name(slotPlayerName(name))
+	clear(name)
+	copy(name[:SlotPlayerNameSize-1], fake)
+	ret = appendLenString(ret, a.ClanTag(clanTag))
+	ret = appendLenString(ret, "")
+	return append(ret, msg[len(msg)-r.Len():]...)
+}
+
+// rewriteSlotMessages re-encodes slot messages packet (signature included)
+// with messages changed by fn, fn returns nil to keep message as is
+func rewriteSlotMessages(payload []byte, fn func(slot byte, msg []byte) []byte) ([]byte, error) {
+	bodyAt := 5
+	if len(payload) < bodyAt {
+		return nil, errors.New("slot messages packet is too short")
+	}
+	compressed := payload[4] > 0
+	body := payload[bodyAt:]
+	if compressed {
+		if len(payload) < 9 {
+			return nil, errors.New("slot messages packet is too short")
+		}
+		bodyAt = 9
+		if payload[6]&0xF0 > 0 {
+			bodyAt++
+		}
+		if len(payload) < bodyAt {
+			return nil, errors.New("slot messages packet is too short")
+		}
+		dec, err := zstd.NewReader(nil, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxMemory(maxSlotMessagesSize))
+		if err != nil {
+			return nil, err
+		}
+		defer dec.Close()
+		body, err = dec.DecodeAll(payload[bodyAt:], nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(body) < 2 {
+		return nil, errors.New("slot messages are truncated")
+	}
+	count := int(binary.LittleEndian.Uint16(body))
+	newBody := bytes.Clone(body[:2])
+	p := 2
+	changed := false
+	for range count {
+		if p+3 > len(body) {
+			return nil, errors.New("slot messages are truncated")
+		}
+		l := int(binary.LittleEndian.Uint16(body[p:]))
+		if l == 0 || p+2+l > len(body) {
+			return nil, errors.New("slot messages are truncated")
+		}
+		slot := body[p+2]
+		msg := body[p+3 : p+2+l]
+		if m := fn(slot, msg); m != nil {
+			if len(m)+1 > 0xffff {
+				return nil, fmt.Errorf("slot message of size %d is too long", len(m))
+			}
+			msg = m
+			changed = true
+		}
+		newBody = binary.LittleEndian.AppendUint16(newBody, uint16(len(msg)+1))
+		newBody = append(append(newBody, slot), msg...)
+		p += 2 + l
+	}
+	if !changed {
+		return nil, nil
+	}
+	newBody = append(newBody, body[p:]...)
+	ret := bytes.Clone(payload[:bodyAt])
+	if !compressed {
+		return append(ret, newBody...), nil
+	}
+	enc, err := zstd.NewWriter(nil)
+	if err != nil {
+		return nil, err
+	}
+	defer enc.Close()
+	return enc.EncodeAll(newBody, ret), nil
+}
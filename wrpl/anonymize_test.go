@@ -0,0 +1,157 @@
+/*
+	wrpl: War Thunder replay parsing library (golang)
+	Copyright (C) 2025 flexcoral
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published
+	by the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package wrpl
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// anonymizeResultsBlk is {"userId": 123456, "name": "RealName", "RealName": {"kills": 3}}
+var anonymizeResultsBlk = []byte{
+	0x01,
+	0x04, 0x1b, 'u', 's', 'e', 'r', 'I', 'd', 0, 'n', 'a', 'm', 'e', 0, 'R', 'e', 'a', 'l', 'N', 'a', 'm', 'e', 0, 'k', 'i', 'l', 'l', 's', 0,
+	0x02,       // blocks
+	0x03, 0x09, // params count, data size
+	'R', 'e', 'a', 'l', 'N', 'a', 'm', 'e', 0,
+	0x00, 0x00, 0x00, 0x02, 0x40, 0xe2, 0x01, 0x00, // userId: int 123456
+	0x01, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, // name: string at 0
+	0x03, 0x00, 0x00, 0x02, 0x03, 0x00, 0x00, 0x00, // kills: int 3
+	0x00, 0x02, 0x01, 0x01, // root: 2 fields, 1 child starting at 1
+	0x03, 0x01, 0x00, // RealName: 1 field
+}
+
+func anonymizeSample(t *testing.T) *WRPL {
+	enc, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer enc.Close()
+	plain := append([]byte{0x02, 0x58, 0x2d, 0xf0, 0x00}, sampleSlotMessages(1, samplePlayerInit(123456, "RealName", "[TAG]", "Legend"))...)
+	compressed := []byte{0x02, 0x58, 0x2d, 0xf0, 0x01, 0x00, 0x10, 0x00, 0x00, 0x04}
+	compressed = enc.EncodeAll(sampleSlotMessages(2, samplePlayerInit(654321, "OtherGuy", "", "")), compressed)
+
+	rpl := &WRPL{SettingsBLK: fuzzFatBlk, ResultsBLK: anonymizeResultsBlk}
+	copy(rpl.Header.Magic[:], []byte{0xe5, 0xac, 0x00, 0x10})
+	rpl.Packets = []*WRPLRawPacket{
+		{CurrentTime: 0, PacketType: byte(PacketTypeMPI), PacketPayload: plain},
+		{CurrentTime: 10, PacketType: byte(PacketTypeMPI), PacketPayload: compressed},
+		{CurrentTime: 20, PacketType: byte(PacketTypeChat), PacketPayload: sampleChat("RealName", "gg OtherGuy", ChatChannelTeam, false)},
+	}
+	b, err := WriteWRPL(rpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rpl, err = ReadWRPL(bytes.NewReader(b), true, true, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rpl
+}
+
+func TestAnonymize(t *testing.T) {
+	rpl := anonymizeSample(t)
+	if p := rpl.Parsed.Players[2]; p == nil || p.Name != "OtherGuy" {
+		t.Fatalf("sample player was not parsed: %+v", p)
+	}
+	a := NewAnonymizer()
+	if err := a.Anonymize(rpl); err != nil {
+		t.Fatal(err)
+	}
+	b, err := WriteWRPL(rpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rpl, err = ReadWRPL(bytes.NewReader(b), true, true, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := rpl.Parsed.Players[1]; p == nil || *p != (Player{Name: "player1", UserID: 1, ClanTag: "clan1"}) {
+		t.Fatalf("slot 1: %+v", p)
+	}
+	if p := rpl.Parsed.Players[2]; p == nil || *p != (Player{Name: "player2", UserID: 2}) {
+		t.Fatalf("slot 2: %+v", p)
+	}
+	if len(rpl.Parsed.Chat) != 1 || rpl.Parsed.Chat[0].Sender != "player1" || rpl.Parsed.Chat[0].Content != "" || rpl.Parsed.Chat[0].ChannelType != 1 {
+		t.Fatalf("chat: %+v", rpl.Parsed.Chat[0])
+	}
+	if rpl.Results["userId"] != int64(1) || rpl.Results["name"] != "player1" || rpl.Results["player1"] == nil {
+		t.Fatalf("results: %s", rpl.ResultsJSON)
+	}
+	if rpl.SettingsJSON == "" {
+		t.Fatal("settings blk was broken")
+	}
+	stream := &bytes.Buffer{}
+	if err := WritePackets(stream, rpl.Packets); err != nil {
+		t.Fatal(err)
+	}
+	all := string(stream.Bytes()) + string(rpl.ResultsBLK)
+	for _, real := range []string{"RealName", "OtherGuy", "[TAG]", "Legend"} {
+		if strings.Contains(all, real) {
+			t.Errorf("%q is left in the replay", real)
+		}
+	}
+
+	// saved mapping gives the same pseudonyms and keeps chat on request
+	mappingPath := filepath.Join(t.TempDir(), "mapping.json")
+	if err := a.Save(mappingPath); err != nil {
+		t.Fatal(err)
+	}
+	a2, err := LoadAnonymizer(mappingPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a2.KeepChatContent = true
+	rpl = anonymizeSample(t)
+	if err := a2.Anonymize(rpl); err != nil {
+		t.Fatal(err)
+	}
+	if rpl.Parsed.Players[2].Name != "player2" || rpl.Parsed.Chat[0].Content != "gg player2" {
+		t.Fatalf("second anonymization: %+v %+v", rpl.Parsed.Players[2], rpl.Parsed.Chat[0])
+	}
+}
+
+func TestAnonymizeZstdBlk(t *testing.T) {
+	tail := []byte{0xde, 0xad}
+	for _, pseudonym := range []string{"p", "pseudonym-way-longer-than-the-real-name-" + strings.Repeat("0123456789", 8)} {
+		rpl := anonymizeSample(t)
+		compressed := zstdBlob(t, anonymizeResultsBlk)
+		l := len(compressed)
+		rpl.ResultsBLK = append(append([]byte{0x02, byte(l >> 16), byte(l >> 8), byte(l)}, compressed...), tail...)
+		a := NewAnonymizer()
+		a.Names["RealName"] = pseudonym
+		if err := a.Anonymize(rpl); err != nil {
+			t.Fatal(err)
+		}
+		if rpl.Results["name"] != pseudonym || rpl.Results[pseudonym] == nil {
+			t.Fatalf("results: %s", rpl.ResultsJSON)
+		}
+		if !bytes.HasSuffix(rpl.ResultsBLK, tail) {
+			t.Fatalf("bytes after compressed payload are lost: %x", rpl.ResultsBLK)
+		}
+		l = int(rpl.ResultsBLK[1])<<16 | int(rpl.ResultsBLK[2])<<8 | int(rpl.ResultsBLK[3])
+		if 4+l+len(tail) != len(rpl.ResultsBLK) {
+			t.Fatalf("header length %d does not match %d bytes of results", l, len(rpl.ResultsBLK))
+		}
+	}
+}
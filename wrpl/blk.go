@@ -23,11 +23,33 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"maps"
 	"math"
+	"slices"
 
 	"github.com/klauspost/compress/zstd"
 )
 
+// maxBlkSize bounds decompressed BLK, real settings and results are kilobytes
+const maxBlkSize = 16 << 20
+
+// decodeBlkZstd decompresses zstd packed BLK no larger than maxBlkSize
+func decodeBlkZstd(b []byte) ([]byte, error) {
+	dec, err := zstd.NewReader(nil, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxMemory(maxBlkSize))
+	if err != nil {
+		return nil, fmt.Errorf("new zstd reader: %w", err)
+	}
+	defer dec.Close()
+	out, err := dec.DecodeAll(b, nil)
+	if isZstdLimit(err) {
+		return nil, fmt.Errorf("%w of %d bytes", ErrDecompressionLimit, maxBlkSize)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("decode: %w", err)
+	}
+	return out, nil
+}
+
 func ParseBlk(input []byte) (ret map[string]any, err error) {
 	if len(input) == 0 {
 		return nil, errors.New("empty BLK buffer")
@@ -43,14 +65,9 @@ func ParseBlk(input []byte) (ret map[string]any, err error) {
 		if len(input) < int(4+l) {
 			return nil, fmt.Errorf("FAT_ZSTD: compressed payload truncated: need %d, have %d", 4+l, len(input))
 		}
-		dec, err := zstd.NewReader(nil)
+		out, err := decodeBlkZstd(input[4 : 4+l])
 		if err != nil {
-			return nil, fmt.Errorf("FAT_ZSTD: new zstd reader: %w", err)
-		}
-		defer dec.Close()
-		out, err := dec.DecodeAll(input[4:4+l], nil)
-		if err != nil {
-			return nil, fmt.Errorf("FAT_ZSTD: decode: %w", err)
+			return nil, fmt.Errorf("FAT_ZSTD: %w", err)
 		}
 		if len(out) == 0 || out[0] != 0x01 {
 			return nil, errors.New("FAT_ZSTD: decoded payload missing FAT header")
@@ -59,16 +76,10 @@ func ParseBlk(input []byte) (ret map[string]any, err error) {
 	case 0x03:
 		return nil, errors.New("SLIM BLK is not yet supported (and won't lol)")
 	case 0x04: // SLIM_ZSTD
-		dec, err := zstd.NewReader(nil)
-		if err != nil {
-			return nil, fmt.Errorf("SLIM_ZSTD: new zstd reader: %w", err)
-		}
-		defer dec.Close()
-		out, err := dec.DecodeAll(input[1:], nil)
+		_, err := decodeBlkZstd(input[1:])
 		if err != nil {
-			return nil, fmt.Errorf("SLIM_ZSTD: decode: %w", err)
+			return nil, fmt.Errorf("SLIM_ZSTD: %w", err)
 		}
-		_ = out
 		return nil, errors.New("SLIM_ZSTD BLK is not supported without an external name map")
 	case 0x05: // SLIM_ZSTD_DICT
 		return nil, errors.New("SLIM_ZSTD_DICT BLK not supported (requires dictionary and external name map)")
@@ -110,10 +121,10 @@ func parseFatBlk(buf []byte) (map[string]any, error) {
 	if err != nil {
 		return nil, fmt.Errorf("names_size: %w", err)
 	}
-	namesSize := int(namesSize64)
-	if p+namesSize > len(buf) {
+	if namesSize64 > uint64(len(buf)-p) {
 		return nil, errors.New("names buffer truncated")
 	}
+	namesSize := int(namesSize64)
 	namesRaw := buf[p : p+namesSize]
 	p += namesSize
 	names := parseNullSeparatedStrings(namesRaw)
@@ -123,6 +134,10 @@ func parseFatBlk(buf []byte) (map[string]any, error) {
 	if err != nil {
 		return nil, fmt.Errorf("total blocks: %w", err)
 	}
+	// every block takes at least 3 bytes of block info
+	if totalBlocks64 == 0 || totalBlocks64 > uint64(len(buf)/3) {
+		return nil, fmt.Errorf("bad total blocks count %d", totalBlocks64)
+	}
 	totalBlocks := int(totalBlocks64)
 
 	// Params
@@ -130,21 +145,21 @@ func parseFatBlk(buf []byte) (map[string]any, error) {
 	if err != nil {
 		return nil, fmt.Errorf("params_count: %w", err)
 	}
-	paramsCount := int(paramsCount64)
 	paramsDataSize64, err := readULEB()
 	if err != nil {
 		return nil, fmt.Errorf("params_data_size: %w", err)
 	}
-	paramsDataSize := int(paramsDataSize64)
-	if p+paramsDataSize > len(buf) {
+	if paramsDataSize64 > uint64(len(buf)-p) {
 		return nil, errors.New("params data truncated")
 	}
+	paramsDataSize := int(paramsDataSize64)
 	paramsData := buf[p : p+paramsDataSize]
 	p += paramsDataSize
 
-	if p+paramsCount*8 > len(buf) {
+	if paramsCount64 > uint64(len(buf)-p)/8 {
 		return nil, errors.New("params info truncated")
 	}
+	paramsCount := int(paramsCount64)
 	paramsInfo := buf[p : p+paramsCount*8]
 	p += paramsCount * 8
 
@@ -179,12 +194,22 @@ func parseFatBlk(buf []byte) (map[string]any, error) {
 		if err != nil {
 			return nil, fmt.Errorf("block[%d] child_count: %w", i, err)
 		}
+		if fieldCount64 > uint64(paramsCount) {
+			return nil, fmt.Errorf("block[%d]: field count %d is over params count %d", i, fieldCount64, paramsCount)
+		}
+		if childCount64 > uint64(totalBlocks) {
+			return nil, fmt.Errorf("block[%d]: child count %d is over blocks count %d", i, childCount64, totalBlocks)
+		}
 		firstChild := 0
 		if childCount64 > 0 {
 			fc, err := readULEBFrom(blockInfo, &bp)
 			if err != nil {
 				return nil, fmt.Errorf("block[%d] first_child: %w", i, err)
 			}
+			// children always follow the parent, this also rules out cycles
+			if fc <= uint64(i) || fc+childCount64 > uint64(totalBlocks) {
+				return nil, fmt.Errorf("block[%d]: children %d+%d out of range", i, fc, childCount64)
+			}
 			firstChild = int(fc)
 		}
 		descs = append(descs, blockDesc{
@@ -384,6 +409,17 @@ func parseFatBlk(buf []byte) (map[string]any, error) {
 		})
 	}
 
+	// shared children would make the output grow exponentially
+	isChild := make([]bool, len(flat))
+	for i, fb := range flat {
+		for c := fb.firstChild; c < fb.firstChild+fb.childCount; c++ {
+			if isChild[c] {
+				return nil, fmt.Errorf("block[%d]: child %d already has a parent", i, c)
+			}
+			isChild[c] = true
+		}
+	}
+
 	// Build nested map
 	var build func(idx int) map[string]any
 	build = func(idx int) map[string]any {
@@ -449,3 +485,222 @@ func uleb128(b []byte) (val uint64, n int, err error) {
 	}
 	return 0, 0, errors.New("uleb128: buffer too small")
 }
+
+func appendULEB128(b []byte, v uint64) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+// blkRewriter changes values of FAT BLK in place. Str is called for every
+// name table entry (with empty key) and string value, Num for every int
+// and long value, both return new value and true if it has to be changed.
+type blkRewriter struct {
+	Str func(key, s string) (string, bool)
+	Num func(key string, v int64) (int64, bool)
+}
+
+// rewriteBlk returns copy of FAT or FAT_ZSTD BLK with values changed by rw,
+// layout of blocks and params is kept as is
+func rewriteBlk(input []byte, rw blkRewriter) ([]byte, error) {
+	if len(input) == 0 {
+		return nil, errors.New("empty BLK buffer")
+	}
+	switch input[0] {
+	case 0x01:
+		fat, err := rewriteFatBlk(input[1:], rw)
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte{0x01}, fat...), nil
+	case 0x02:
+		if len(input) < 4 {
+			return nil, errors.New("FAT_ZSTD: truncated header")
+		}
+		oldL := (uint32(input[1]) << 16) | (uint32(input[2]) << 8) | uint32(input[3])
+		if len(input) < int(4+oldL) {
+			return nil, fmt.Errorf("FAT_ZSTD: compressed payload truncated: need %d, have %d", 4+oldL, len(input))
+		}
+		out, err := decodeBlkZstd(input[4 : 4+oldL])
+		if err != nil {
+			return nil, fmt.Errorf("FAT_ZSTD: %w", err)
+		}
+		if len(out) == 0 || out[0] != 0x01 {
+			return nil, errors.New("FAT_ZSTD: decoded payload missing FAT header")
+		}
+		fat, err := rewriteFatBlk(out[1:], rw)
+		if err != nil {
+			return nil, err
+		}
+		enc, err := zstd.NewWriter(nil)
+		if err != nil {
+			return nil, fmt.Errorf("FAT_ZSTD: new zstd writer: %w", err)
+		}
+		compressed := enc.EncodeAll(append([]byte{0x01}, fat...), nil)
+		enc.Close()
+		if len(compressed) >= 1<<24 {
+			return nil, fmt.Errorf("FAT_ZSTD: compressed payload of %d bytes does not fit in the header", len(compressed))
+		}
+		l := uint32(len(compressed))
+		ret := []byte{0x02, byte(l >> 16), byte(l >> 8), byte(l)}
+		ret = append(ret, compressed...)
+		return append(ret, input[4+oldL:]...), nil
+	default:
+		return nil, fmt.Errorf("rewriting BLK of type 0x%02x is not supported", input[0])
+	}
+}
+
+func rewriteFatBlk(buf []byte, rw blkRewriter) ([]byte, error) {
+	p := 0
+	readULEB := func() (uint64, error) {
+		v, n, err := uleb128(buf[p:])
+		if err != nil {
+			return 0, err
+		}
+		p += n
+		return v, nil
+	}
+	namesCount, err := readULEB()
+	if err != nil {
+		return nil, fmt.Errorf("names_count: %w", err)
+	}
+	namesSize, err := readULEB()
+	if err != nil {
+		return nil, fmt.Errorf("names_size: %w", err)
+	}
+	if namesSize > uint64(len(buf)-p) {
+		return nil, errors.New("names buffer truncated")
+	}
+	namesRaw := buf[p : p+int(namesSize)]
+	p += int(namesSize)
+	names := parseNullSeparatedStrings(namesRaw)
+	newNames := []byte{}
+	for _, n := range names {
+		if s, ok := rw.Str("", n); ok {
+			n = s
+		}
+		newNames = append(append(newNames, n...), 0)
+	}
+	// bytes after the last terminator are ignored by the parser
+	newNames = append(newNames, namesRaw[bytes.LastIndexByte(namesRaw, 0)+1:]...)
+
+	totalBlocks, err := readULEB()
+	if err != nil {
+		return nil, fmt.Errorf("total blocks: %w", err)
+	}
+	paramsCount, err := readULEB()
+	if err != nil {
+		return nil, fmt.Errorf("params_count: %w", err)
+	}
+	paramsDataSize, err := readULEB()
+	if err != nil {
+		return nil, fmt.Errorf("params_data_size: %w", err)
+	}
+	if paramsDataSize > uint64(len(buf)-p) {
+		return nil, errors.New("params data truncated")
+	}
+	paramsData := bytes.Clone(buf[p : p+int(paramsDataSize)])
+	p += int(paramsDataSize)
+	if paramsCount > uint64(len(buf)-p)/8 {
+		return nil, errors.New("params info truncated")
+	}
+	paramsInfo := bytes.Clone(buf[p : p+int(paramsCount)*8])
+	p += int(paramsCount) * 8
+	blockInfo := buf[p:]
+
+	// strings in params data are referenced by offset and can share bytes,
+	// replaced ones are wiped so that old values do not stay in the file
+	// and new values are appended to the end of params data
+	replaced := map[int]string{}
+	ends := map[int]int{}
+	kept := map[int]bool{}
+	for i := range int(paramsCount) {
+		chunk := paramsInfo[i*8 : i*8+8]
+		nameID := int(uint32(chunk[0]) | uint32(chunk[1])<<8 | uint32(chunk[2])<<16)
+		if nameID >= len(names) {
+			return nil, fmt.Errorf("param[%d]: name id %d out of range %d", i, nameID, len(names))
+		}
+		key := names[nameID]
+		data := chunk[4:8]
+		switch chunk[3] {
+		case 0x01:
+			raw := binary.LittleEndian.Uint32(data)
+			if raw>>31 == 1 {
+				continue
+			}
+			off := int(raw)
+			if off >= len(paramsData) {
+				return nil, fmt.Errorf("param[%d]: string offset %d OOB", i, off)
+			}
+			end := bytes.IndexByte(paramsData[off:], 0)
+			if end < 0 {
+				return nil, fmt.Errorf("param[%d]: unterminated string", i)
+			}
+			ends[off] = off + end
+			if _, ok := replaced[off]; ok {
+				continue
+			}
+			if s, ok := rw.Str(key, string(paramsData[off:off+end])); ok {
+				replaced[off] = s
+				delete(kept, off)
+			} else {
+				kept[off] = true
+			}
+		case 0x02:
+			if v, ok := rw.Num(key, int64(int32(binary.LittleEndian.Uint32(data)))); ok {
+				binary.LittleEndian.PutUint32(data, uint32(int32(v)))
+			}
+		case 0x0C:
+			off := int(binary.LittleEndian.Uint32(data))
+			if off < 0 || off+8 > len(paramsData) {
+				return nil, fmt.Errorf("param[%d]: long offset OOB", i)
+			}
+			if v, ok := rw.Num(key, int64(binary.LittleEndian.Uint64(paramsData[off:]))); ok {
+				binary.LittleEndian.PutUint64(paramsData[off:], uint64(v))
+			}
+		}
+	}
+	moved := map[int]int{}
+	appendString := func(s string) int {
+		off := len(paramsData)
+		paramsData = append(append(paramsData, s...), 0)
+		return off
+	}
+	replacedOffsets := slices.Sorted(maps.Keys(replaced))
+	for _, k := range slices.Sorted(maps.Keys(kept)) {
+		for _, off := range replacedOffsets {
+			if k < ends[off] && ends[k] > off {
+				moved[k] = appendString(string(paramsData[k:ends[k]]))
+				break
+			}
+		}
+	}
+	for _, off := range replacedOffsets {
+		clear(paramsData[off:ends[off]])
+	}
+	for _, off := range replacedOffsets {
+		moved[off] = appendString(replaced[off])
+	}
+	for i := range int(paramsCount) {
+		chunk := paramsInfo[i*8 : i*8+8]
+		raw := binary.LittleEndian.Uint32(chunk[4:8])
+		if chunk[3] != 0x01 || raw>>31 == 1 {
+			continue
+		}
+		if off, ok := moved[int(raw)]; ok {
+			binary.LittleEndian.PutUint32(chunk[4:8], uint32(off))
+		}
+	}
+
+	ret := appendULEB128(nil, namesCount)
+	ret = appendULEB128(ret, uint64(len(newNames)))
+	ret = append(ret, newNames...)
+	ret = appendULEB128(ret, totalBlocks)
+	ret = appendULEB128(ret, paramsCount)
+	ret = appendULEB128(ret, uint64(len(paramsData)))
+	ret = append(ret, paramsData...)
+	ret = append(ret, paramsInfo...)
+	return append(ret, blockInfo...), nil
+}
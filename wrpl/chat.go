@@ -0,0 +1,168 @@
+/*
+	wrpl: War Thunder replay parsing library (golang)
+	Copyright (C) 2025 flexcoral
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published
+	by the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package wrpl
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ChatChannel is decoded ChannelType of chat packet, values other than
+// the known ones are printed as channelN
+type ChatChannel byte
+
+const (
+	ChatChannelAll    ChatChannel = 0
+	ChatChannelTeam   ChatChannel = 1
+	ChatChannelSquad  ChatChannel = 2
+	ChatChannelSystem ChatChannel = 3
+)
+
+func (c ChatChannel) String() string {
+	switch c {
+	case ChatChannelAll:
+		return "all"
+	case ChatChannelTeam:
+		return "team"
+	case ChatChannelSquad:
+		return "squad"
+	case ChatChannelSystem:
+		return "system"
+	default:
+		return fmt.Sprintf("channel%d", byte(c))
+	}
+}
+
+func (c ChatChannel) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+// Enemy is set for messages from the other team
+func (c *ParsedPacketChat) Enemy() bool {
+	return c.IsEnemy != 0
+}
+
+func (c *ParsedPacketChat) Time() time.Duration {
+	return time.Duration(c.CurrentTime) * time.Millisecond
+}
+
+// ChatLog is chat of one replay prepared for export
+type ChatLog struct {
+	Replay   string              `json:"replay"`
+	Start    time.Time           `json:"start"`
+	Messages []*ParsedPacketChat `json:"messages"`
+}
+
+// NewChatLog takes chat of parsed replay, name is used to tell replays apart
+func NewChatLog(name string, rpl *WRPL) ChatLog {
+	ret := ChatLog{
+		Replay: name,
+		Start:  time.Unix(int64(rpl.Header.StartTime), 0),
+	}
+	if rpl.Parsed != nil {
+		ret.Messages = rpl.Parsed.Chat
+	}
+	return ret
+}
+
+// ChatExportFormats are formats accepted by ExportChat
+var ChatExportFormats = []string{"text", "json", "irc"}
+
+// ExportChat writes chat logs of many replays as plain text (replay time,
+// channel, sender and message), json or IRC-style log with wall clock time
+func ExportChat(w io.Writer, format string, logs []ChatLog) error {
+	switch format {
+	case "text":
+		return exportChatText(w, logs)
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "\t")
+		return enc.Encode(logs)
+	case "irc":
+		return exportChatIRC(w, logs)
+	default:
+		return fmt.Errorf("unknown chat export format %q (supported: %s)", format, strings.Join(ChatExportFormats, ", "))
+	}
+}
+
+// ChatSender is sender with clan tag and enemy mark if known
+func ChatSender(c *ParsedPacketChat) string {
+	ret := c.Sender
+	if c.Player != nil && c.Player.ClanTag != "" && !strings.HasPrefix(ret, c.Player.ClanTag) {
+		ret = c.Player.ClanTag + " " + ret
+	}
+	if c.Enemy() {
+		ret += " (enemy)"
+	}
+	return ret
+}
+
+func exportChatText(w io.Writer, logs []ChatLog) error {
+	for i, l := range logs {
+		if i > 0 {
+			if _, err := fmt.Fprintln(w); err != nil {
+				return err
+			}
+		}
+		_, err := fmt.Fprintf(w, "%s (%s)\n", l.Replay, l.Start.Format(time.DateTime))
+		if err != nil {
+			return err
+		}
+		for _, c := range l.Messages {
+			_, err = fmt.Fprintf(w, "%s\t%s\t%s: %s\n", c.Time().Truncate(time.Millisecond), c.Channel, ChatSender(c), c.Content)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func exportChatIRC(w io.Writer, logs []ChatLog) error {
+	for _, l := range logs {
+		_, err := fmt.Fprintf(w, "--- Log opened %s (%s)\n", l.Start.Format(time.ANSIC), l.Replay)
+		if err != nil {
+			return err
+		}
+		end := l.Start
+		for _, c := range l.Messages {
+			at := l.Start.Add(c.Time())
+			end = at
+			switch c.Channel {
+			case ChatChannelSystem:
+				_, err = fmt.Fprintf(w, "[%s] -!- %s\n", at.Format(time.TimeOnly), c.Content)
+			case ChatChannelAll:
+				_, err = fmt.Fprintf(w, "[%s] <%s> %s\n", at.Format(time.TimeOnly), ChatSender(c), c.Content)
+			default:
+				_, err = fmt.Fprintf(w, "[%s] <%s> [%s] %s\n", at.Format(time.TimeOnly), ChatSender(c), c.Channel, c.Content)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		_, err = fmt.Fprintf(w, "--- Log closed %s\n", end.Format(time.ANSIC))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
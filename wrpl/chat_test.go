@@ -0,0 +1,58 @@
+/*
+	wrpl: War Thunder replay parsing library (golang)
+	Copyright (C) 2025 flexcoral
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published
+	by the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package wrpl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChatExport(t *testing.T) {
+	rpl := anonymizeSample(t)
+	if len(rpl.Parsed.Chat) != 1 {
+		t.Fatalf("expected one chat message, got %d", len(rpl.Parsed.Chat))
+	}
+	c := rpl.Parsed.Chat[0]
+	if c.Channel != ChatChannelTeam || c.Player != rpl.Parsed.Players[1] {
+		t.Fatalf("chat is not decoded: %+v", c)
+	}
+	if d := rpl.Packets[2].Parsed.Data.(ParsedPacketChat); d.Player != c.Player {
+		t.Fatalf("sender is not resolved on the packet: %+v", d)
+	}
+	rpl.Header.StartTime = uint32(time.Date(2025, 10, 1, 12, 0, 0, 0, time.Local).Unix())
+	logs := []ChatLog{NewChatLog("a.wrpl", rpl), NewChatLog("b.wrpl", &WRPL{})}
+	for format, want := range map[string]string{
+		"text": "20ms\tteam\t[TAG] RealName: gg OtherGuy\n",
+		"json": `"Channel": "team"`,
+		"irc":  "[12:00:00] <[TAG] RealName> [team] gg OtherGuy\n--- Log closed",
+	} {
+		buf := &bytes.Buffer{}
+		if err := ExportChat(buf, format, logs); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("%s export does not contain %q:\n%s", format, want, buf.String())
+		}
+	}
+	if err := ExportChat(&bytes.Buffer{}, "csv", logs); err == nil {
+		t.Error("unknown format is accepted")
+	}
+}
@@ -0,0 +1,254 @@
+/*
+	wrpl: War Thunder replay parsing library (golang)
+	Copyright (C) 2025 flexcoral
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published
+	by the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package wrpl
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Corpus is a directory with sample replays: single .wrpl files and
+// session folders with server replay parts. Golden snapshots are kept
+// in the golden subdirectory of the corpus so it can be shared as a whole.
+// The default corpus only holds synthetic sample.wrpl built by corpusSample.
+//
+//	go test ./wrpl -run Corpus -corpus ~/replays-corpus
+//	go test ./wrpl -run Corpus -corpus ~/replays-corpus -update
+var (
+	corpusDir    = flag.String("corpus", "testdata/corpus", "directory with sample replays and session folders")
+	corpusUpdate = flag.Bool("update", false, "rewrite golden files of the corpus")
+)
+
+type corpusTypeStats struct {
+	Count  int            `json:"count"`
+	Parsed map[string]int `json:"parsed,omitempty"`
+	Errors map[string]int `json:"errors,omitempty"`
+}
+
+type corpusSnapshot struct {
+	Error            string                      `json:"error,omitempty"`
+	Header           map[string]any              `json:"header"`
+	Settings         json.RawMessage             `json:"settings,omitempty"`
+	Results          json.RawMessage             `json:"results,omitempty"`
+	ContinuityIssues []string                    `json:"continuityIssues,omitempty"`
+	Packets          int                         `json:"packets"`
+	Types            map[string]*corpusTypeStats `json:"types"`
+	Players          map[string]*Player          `json:"players,omitempty"`
+	Chat             []*ParsedPacketChat         `json:"chat,omitempty"`
+}
+
+// snapshotHeader keeps text fields readable and dumps binary ones as hex
+func snapshotHeader(h WRPLHeader) map[string]any {
+	ret := map[string]any{}
+	v := reflect.ValueOf(h)
+	for i := range v.NumField() {
+		f := v.Type().Field(i)
+		fv := v.Field(i)
+		if fv.Kind() != reflect.Array {
+			ret[f.Name] = fv.Interface()
+			continue
+		}
+		b := make([]byte, fv.Len())
+		reflect.Copy(reflect.ValueOf(b), fv)
+		text := bytes.TrimRight(b, "\x00")
+		if len(text) == 0 {
+			ret[f.Name] = ""
+		} else if bytesToChar(text) == string(text) {
+			ret[f.Name] = string(text)
+		} else {
+			ret[f.Name] = hex.EncodeToString(b)
+		}
+	}
+	return ret
+}
+
+func snapshotReplay(rpl *WRPL, err error) *corpusSnapshot {
+	ret := &corpusSnapshot{
+		Types: map[string]*corpusTypeStats{},
+	}
+	if err != nil {
+		ret.Error = err.Error()
+	}
+	if rpl == nil {
+		return ret
+	}
+	ret.Header = snapshotHeader(rpl.Header)
+	if rpl.SettingsJSON != "" {
+		ret.Settings = json.RawMessage(rpl.SettingsJSON)
+	}
+	if rpl.ResultsJSON != "" {
+		ret.Results = json.RawMessage(rpl.ResultsJSON)
+	}
+	ret.ContinuityIssues = rpl.ContinuityIssues
+	ret.Packets = len(rpl.Packets)
+	for _, pk := range rpl.Packets {
+		t := strconv.Itoa(int(pk.PacketType))
+		st, ok := ret.Types[t]
+		if !ok {
+			st = &corpusTypeStats{Parsed: map[string]int{}, Errors: map[string]int{}}
+			ret.Types[t] = st
+		}
+		st.Count++
+		if pk.ParseError != nil && !errors.Is(pk.ParseError, ErrUnknownPacket) {
+			st.Errors[pk.ParseError.Error()]++
+		} else if pk.Parsed != nil {
+			st.Parsed[pk.Parsed.Name]++
+		}
+	}
+	if rpl.Parsed != nil {
+		ret.Chat = rpl.Parsed.Chat
+		ret.Players = map[string]*Player{}
+		for i, p := range rpl.Parsed.Players {
+			if p != nil {
+				ret.Players[strconv.Itoa(i)] = p
+			}
+		}
+	}
+	return ret
+}
+
+func readCorpusEntry(p string, isDir bool) (*WRPL, error) {
+	if isDir {
+		return ReadPartedWRPLFolder(p)
+	}
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	return ReadWRPL(bytes.NewReader(b), true, true, true)
+}
+
+// firstDiff describes first differing line of two texts
+func firstDiff(want, got []byte) string {
+	wl := strings.Split(string(want), "\n")
+	gl := strings.Split(string(got), "\n")
+	for i := range max(len(wl), len(gl)) {
+		w, g := "<eof>", "<eof>"
+		if i < len(wl) {
+			w = wl[i]
+		}
+		if i < len(gl) {
+			g = gl[i]
+		}
+		if w != g {
+			return fmt.Sprintf("line %d:\n\twant: %s\n\tgot:  %s", i+1, strings.TrimSpace(w), strings.TrimSpace(g))
+		}
+	}
+	return "no difference"
+}
+
+// corpusSample is synthetic replay kept in the default corpus so the
+// harness runs without real replays, -update regenerates it
+func corpusSample() *sampleBuilder {
+	b := newSampleBuilder().
+		player(0, 1, 1001, "Alpha", "[AAA]", "Ace").
+		player(0, 2, 1002, "Bravo", "", "").
+		packet(5, PacketTypeMPI, []byte{0x02, 0x58, 0x73, 0xf0, 0x00}).
+		chat(1000, "Alpha", "hello", ChatChannelAll, false).
+		kill(2000, 1, "germ_tiger").
+		chat(3000, "Bravo", "nice shot", ChatChannelTeam, true).
+		end(60000)
+	copy(b.rpl.Header.Raw_Level[:], "levels/avg_stalingrad.bin")
+	b.rpl.ResultsBLK = fuzzFatBlk
+	return b
+}
+
+func TestCorpusSample(t *testing.T) {
+	samplePath := filepath.Join("testdata", "corpus", "sample.wrpl")
+	want := corpusSample().bytes(t)
+	if *corpusUpdate {
+		if err := os.MkdirAll(filepath.Dir(samplePath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(samplePath, want, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	b, err := os.ReadFile(samplePath)
+	if err != nil {
+		t.Fatalf("reading sample replay (run with -update to create): %v", err)
+	}
+	// compared after rewriting so compressor changes don't matter
+	rpl, err := ReadWRPL(bytes.NewReader(b), true, true, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := WriteWRPL(rpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("%s is not built by corpusSample, run with -update", samplePath)
+	}
+}
+
+func TestCorpus(t *testing.T) {
+	entries, err := os.ReadDir(*corpusDir)
+	if errors.Is(err, os.ErrNotExist) {
+		t.Skipf("no replay corpus at %s (set with -corpus)", *corpusDir)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	goldenDir := filepath.Join(*corpusDir, "golden")
+	if *corpusUpdate {
+		if err := os.MkdirAll(goldenDir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() && name == "golden" || !e.IsDir() && !strings.HasSuffix(name, ".wrpl") {
+			continue
+		}
+		t.Run(name, func(t *testing.T) {
+			rpl, err := readCorpusEntry(filepath.Join(*corpusDir, name), e.IsDir())
+			got, err := json.MarshalIndent(snapshotReplay(rpl, err), "", "\t")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, '\n')
+			goldenPath := filepath.Join(goldenDir, strings.TrimSuffix(name, ".wrpl")+".json")
+			if *corpusUpdate {
+				if err := os.WriteFile(goldenPath, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(goldenPath)
+			if err != nil {
+				t.Fatalf("reading golden file (run with -update to create): %v", err)
+			}
+			if !bytes.Equal(want, got) {
+				t.Errorf("snapshot differs from %s, %s", goldenPath, firstDiff(want, got))
+			}
+		})
+	}
+}
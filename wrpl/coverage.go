@@ -0,0 +1,159 @@
+/*
+	wrpl: War Thunder replay parsing library (golang)
+	Copyright (C) 2025 flexcoral
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published
+	by the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package wrpl
+
+import (
+	"cmp"
+	"encoding/hex"
+	"errors"
+	"maps"
+	"slices"
+)
+
+const (
+	DefaultCoverageSignatureLength = 4
+	DefaultCoverageExamples        = 8
+)
+
+// MPISignatureHints are guesses about signatures parsePacketMPI does not decode yet
+var MPISignatureHints = map[string]string{
+	"025873f0": "some rando noise",
+	"025874f0": "model info (has steering)",
+	"035843f0": "model info (has turret angles)",
+}
+
+// LengthCount is how many packets of a cluster have given payload length
+type LengthCount struct {
+	Length int
+	Count  int
+}
+
+// SignatureCluster is a group of MPI packets sharing leading payload bytes
+type SignatureCluster struct {
+	Signature string
+	Hint      string
+	Count     int
+	Parsed    int
+	Failed    int
+	Names     []string
+	MinLength int
+	MaxLength int
+	AvgLength float64
+	// Lengths sorted by count, most common first
+	Lengths []LengthCount
+	// Examples are indices in analyzed packet list
+	Examples []int
+}
+
+// Unparsed is count of packets that parser does not know
+func (c *SignatureCluster) Unparsed() int {
+	return c.Count - c.Parsed - c.Failed
+}
+
+// Coverage is share of MPI packets that parser understands
+type Coverage struct {
+	Total    int
+	Parsed   int
+	Failed   int
+	Clusters []*SignatureCluster
+}
+
+// Percent of packets parsed without error
+func (c *Coverage) Percent() float64 {
+	if c.Total == 0 {
+		return 0
+	}
+	return float64(c.Parsed) / float64(c.Total) * 100
+}
+
+func (c *Coverage) Unparsed() int {
+	return c.Total - c.Parsed - c.Failed
+}
+
+// coverageSignature is first sigLength bytes of the payload, movement
+// packets have entity id right after ff0f so they are clustered on 2 bytes
+func coverageSignature(payload []byte, sigLength int) string {
+	if len(payload) >= 2 && payload[0] == 0xff && payload[1] == 0x0f {
+		sigLength = min(sigLength, 2)
+	}
+	return hex.EncodeToString(payload[:min(len(payload), sigLength)])
+}
+
+// AnalyzeMPICoverage groups MPI packets by first sigLength bytes of the
+// payload, clusters are sorted by count of packets that are not parsed
+func AnalyzeMPICoverage(packets []*WRPLRawPacket, sigLength, examples int) *Coverage {
+	ret := &Coverage{}
+	clusters := map[string]*SignatureCluster{}
+	lengths := map[string]map[int]int{}
+	names := map[string]map[string]bool{}
+	for i, pk := range packets {
+		if pk == nil || PacketType(pk.PacketType) != PacketTypeMPI {
+			continue
+		}
+		sig := coverageSignature(pk.PacketPayload, sigLength)
+		c, ok := clusters[sig]
+		if !ok {
+			c = &SignatureCluster{
+				Signature: sig,
+				Hint:      MPISignatureHints[sig],
+				MinLength: len(pk.PacketPayload),
+			}
+			clusters[sig] = c
+			lengths[sig] = map[int]int{}
+			names[sig] = map[string]bool{}
+		}
+		ret.Total++
+		c.Count++
+		switch {
+		case pk.ParseError != nil && !errors.Is(pk.ParseError, ErrUnknownPacket):
+			ret.Failed++
+			c.Failed++
+		case pk.Parsed != nil:
+			ret.Parsed++
+			c.Parsed++
+			names[sig][pk.Parsed.Name] = true
+		}
+		l := len(pk.PacketPayload)
+		lengths[sig][l]++
+		c.MinLength = min(c.MinLength, l)
+		c.MaxLength = max(c.MaxLength, l)
+		c.AvgLength += float64(l)
+		if len(c.Examples) < examples {
+			c.Examples = append(c.Examples, i)
+		}
+	}
+	for sig, c := range clusters {
+		c.AvgLength /= float64(c.Count)
+		for l, n := range lengths[sig] {
+			c.Lengths = append(c.Lengths, LengthCount{Length: l, Count: n})
+		}
+		slices.SortFunc(c.Lengths, func(a, b LengthCount) int {
+			return cmp.Or(cmp.Compare(b.Count, a.Count), cmp.Compare(a.Length, b.Length))
+		})
+		c.Names = slices.Sorted(maps.Keys(names[sig]))
+		ret.Clusters = append(ret.Clusters, c)
+	}
+	slices.SortFunc(ret.Clusters, func(a, b *SignatureCluster) int {
+		return cmp.Or(
+			cmp.Compare(b.Unparsed()+b.Failed, a.Unparsed()+a.Failed),
+			cmp.Compare(b.Count, a.Count),
+			cmp.Compare(a.Signature, b.Signature))
+	})
+	return ret
+}
@@ -0,0 +1,74 @@
+/*
+	wrpl: War Thunder replay parsing library (golang)
+	Copyright (C) 2025 flexcoral
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published
+	by the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package wrpl
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestAnalyzeMPICoverage(t *testing.T) {
+	mpi := func(payload []byte, parsed string, parseErr error) *WRPLRawPacket {
+		pk := &WRPLRawPacket{PacketType: byte(PacketTypeMPI), PacketPayload: payload, ParseError: parseErr}
+		if parsed != "" {
+			pk.Parsed = &ParsedPacket{Name: parsed}
+		}
+		return pk
+	}
+	packets := []*WRPLRawPacket{
+		// movement of different entities is one cluster
+		mpi([]byte{0xff, 0x0f, 0x01, 0x00, 0xaa}, "movement", nil),
+		mpi([]byte{0xff, 0x0f, 0x02, 0x00, 0xaa}, "movement", nil),
+		mpi([]byte{0xff, 0x0f, 0x83, 0x01, 0xaa, 0xbb}, "", errors.New("short movement")),
+		{PacketType: byte(PacketTypeChat), PacketPayload: []byte{0xff, 0x0f}},
+		mpi([]byte{0x02, 0x58, 0x73, 0xf0, 0x01}, "", ErrUnknownPacket),
+		mpi([]byte{0x02, 0x58, 0x73, 0xf0, 0x02, 0x03}, "", nil),
+		mpi([]byte{0x02, 0x58, 0x78, 0xf0}, "award", nil),
+		nil,
+	}
+	cov := AnalyzeMPICoverage(packets, DefaultCoverageSignatureLength, 1)
+	if cov.Total != 6 || cov.Parsed != 3 || cov.Failed != 1 || cov.Unparsed() != 2 {
+		t.Fatalf("total %d parsed %d failed %d unparsed %d", cov.Total, cov.Parsed, cov.Failed, cov.Unparsed())
+	}
+	want := []struct {
+		sig                      string
+		count, parsed, failed    int
+		names                    []string
+		minLen, maxLen, examples int
+	}{
+		{"025873f0", 2, 0, 0, []string{}, 5, 6, 4},
+		{"ff0f", 3, 2, 1, []string{"movement"}, 5, 6, 0},
+		{"025878f0", 1, 1, 0, []string{"award"}, 4, 4, 6},
+	}
+	if len(cov.Clusters) != len(want) {
+		t.Fatalf("got %d clusters, want %d", len(cov.Clusters), len(want))
+	}
+	for i, w := range want {
+		c := cov.Clusters[i]
+		if c.Signature != w.sig || c.Count != w.count || c.Parsed != w.parsed || c.Failed != w.failed ||
+			!slices.Equal(c.Names, w.names) || c.MinLength != w.minLen || c.MaxLength != w.maxLen ||
+			len(c.Examples) != 1 || c.Examples[0] != w.examples {
+			t.Errorf("cluster %d: %+v, want %+v", i, c, w)
+		}
+	}
+	if cov.Clusters[0].Hint != MPISignatureHints["025873f0"] {
+		t.Errorf("hint of known signature is %q", cov.Clusters[0].Hint)
+	}
+}
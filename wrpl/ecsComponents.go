@@ -0,0 +1,252 @@
+/*
+	wrpl: War Thunder replay parsing library (golang)
+	Copyright (C) 2025 flexcoral
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published
+	by the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package wrpl
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/maxsupermanhd/wrpl-inspector/danet"
+)
+
+// ECSTypeDecoder reads one component value of a specific type from the bitstream
+type ECSTypeDecoder func(r *danet.BitReader) (any, error)
+
+type ECSPoint2 struct{ X, Y float32 }
+type ECSPoint3 struct{ X, Y, Z float32 }
+type ECSPoint4 struct{ X, Y, Z, W float32 }
+type ECSIPoint2 struct{ X, Y int32 }
+type ECSIPoint3 struct{ X, Y, Z int32 }
+type ECSIPoint4 struct{ X, Y, Z, W int32 }
+type ECSDPoint3 struct{ X, Y, Z float64 }
+type ECSTMatrix [4]ECSPoint3
+type ECSEntityID uint64
+
+// ECSArrayItem is an element of heterogeneous ecs::Array / ecs::Object
+type ECSArrayItem struct {
+	Key   string `json:",omitempty"`
+	Type  uint32
+	Value any
+}
+
+var (
+	ecsTypeDecoders = map[uint32]ECSTypeDecoder{}
+	ecsTypeNames    = map[uint32]string{}
+)
+
+// ECSHash is engine string hash used for component and type names (FNV-1a 32)
+func ECSHash(s string) uint32 {
+	h := uint32(2166136261)
+	for i := 0; i < len(s); i++ {
+		h ^= uint32(s[i])
+		h *= 16777619
+	}
+	return h
+}
+
+// RegisterECSType makes component values of the given type name decodable,
+// replaces previously registered decoder for the same name
+func RegisterECSType(typeName string, dec ECSTypeDecoder) {
+	h := ECSHash(typeName)
+	ecsTypeDecoders[h] = dec
+	ecsTypeNames[h] = typeName
+}
+
+// ECSTypeName returns name of registered type by it's hash
+func ECSTypeName(typeHash uint32) (string, bool) {
+	n, ok := ecsTypeNames[typeHash]
+	return n, ok
+}
+
+func ecsReadPOD[T any](r *danet.BitReader) (any, error) {
+	var v T
+	err := binary.Read(r, binary.LittleEndian, &v)
+	return v, err
+}
+
+func ecsReadList(item ECSTypeDecoder) ECSTypeDecoder {
+	return func(r *danet.BitReader) (any, error) {
+		count, err := r.ReadCompressed()
+		if err != nil {
+			return nil, fmt.Errorf("reading list length: %w", err)
+		}
+		if count > uint64(len(r.Data)*8) {
+			return nil, fmt.Errorf("list length %d is longer than the message", count)
+		}
+		ret := make([]any, 0, count)
+		for i := range count {
+			v, err := item(r)
+			if err != nil {
+				return ret, fmt.Errorf("reading list item %d: %w", i, err)
+			}
+			ret = append(ret, v)
+		}
+		return ret, nil
+	}
+}
+
+func ecsReadString(r *danet.BitReader) (any, error) {
+	l, err := r.ReadCompressed()
+	if err != nil {
+		return nil, err
+	}
+	if l > uint64(len(r.Data)) {
+		return nil, fmt.Errorf("string length %d is longer than the message", l)
+	}
+	b := make([]byte, l)
+	_, err = r.Read(b)
+	return string(b), err
+}
+
+func ecsReadEID(r *danet.BitReader) (any, error) {
+	eid, err := readEID(r)
+	return ECSEntityID(eid), err
+}
+
+func ecsReadTypedValue(r *danet.BitReader) (typeHash uint32, v any, err error) {
+	err = binary.Read(r, binary.LittleEndian, &typeHash)
+	if err != nil {
+		return 0, nil, fmt.Errorf("reading item type: %w", err)
+	}
+	dec, ok := ecsTypeDecoders[typeHash]
+	if !ok {
+		return typeHash, nil, fmt.Errorf("unknown item type %08x", typeHash)
+	}
+	v, err = dec(r)
+	return
+}
+
+func ecsReadArray(r *danet.BitReader) (any, error) {
+	count, err := r.ReadCompressed()
+	if err != nil {
+		return nil, fmt.Errorf("reading array length: %w", err)
+	}
+	if count > uint64(len(r.Data)) {
+		return nil, fmt.Errorf("array length %d is longer than the message", count)
+	}
+	ret := make([]ECSArrayItem, 0, count)
+	for range count {
+		t, v, err := ecsReadTypedValue(r)
+		if err != nil {
+			return ret, err
+		}
+		ret = append(ret, ECSArrayItem{Type: t, Value: v})
+	}
+	return ret, nil
+}
+
+func ecsReadObject(r *danet.BitReader) (any, error) {
+	count, err := r.ReadCompressed()
+	if err != nil {
+		return nil, fmt.Errorf("reading object length: %w", err)
+	}
+	if count > uint64(len(r.Data)) {
+		return nil, fmt.Errorf("object length %d is longer than the message", count)
+	}
+	ret := make([]ECSArrayItem, 0, count)
+	for range count {
+		k, err := ecsReadString(r)
+		if err != nil {
+			return ret, fmt.Errorf("reading object key: %w", err)
+		}
+		t, v, err := ecsReadTypedValue(r)
+		if err != nil {
+			return ret, err
+		}
+		ret = append(ret, ECSArrayItem{Key: k.(string), Type: t, Value: v})
+	}
+	return ret, nil
+}
+
+func init() {
+	RegisterECSType("ecs::Tag", func(r *danet.BitReader) (any, error) { return struct{}{}, nil })
+	RegisterECSType("bool", func(r *danet.BitReader) (any, error) { return r.ReadBit() })
+	RegisterECSType("int8_t", ecsReadPOD[int8])
+	RegisterECSType("uint8_t", ecsReadPOD[uint8])
+	RegisterECSType("int16_t", ecsReadPOD[int16])
+	RegisterECSType("uint16_t", ecsReadPOD[uint16])
+	RegisterECSType("int", ecsReadPOD[int32])
+	RegisterECSType("uint32_t", ecsReadPOD[uint32])
+	RegisterECSType("int64_t", ecsReadPOD[int64])
+	RegisterECSType("uint64_t", ecsReadPOD[uint64])
+	RegisterECSType("float", ecsReadPOD[float32])
+	RegisterECSType("double", ecsReadPOD[float64])
+	RegisterECSType("E3DCOLOR", ecsReadPOD[uint32])
+	RegisterECSType("Point2", ecsReadPOD[ECSPoint2])
+	RegisterECSType("Point3", ecsReadPOD[ECSPoint3])
+	RegisterECSType("Point4", ecsReadPOD[ECSPoint4])
+	RegisterECSType("Quat", ecsReadPOD[ECSPoint4])
+	RegisterECSType("IPoint2", ecsReadPOD[ECSIPoint2])
+	RegisterECSType("IPoint3", ecsReadPOD[ECSIPoint3])
+	RegisterECSType("IPoint4", ecsReadPOD[ECSIPoint4])
+	RegisterECSType("DPoint3", ecsReadPOD[ECSDPoint3])
+	RegisterECSType("TMatrix", ecsReadPOD[ECSTMatrix])
+	RegisterECSType("ecs::string", ecsReadString)
+	RegisterECSType("ecs::EntityId", ecsReadEID)
+	RegisterECSType("ecs::Array", ecsReadArray)
+	RegisterECSType("ecs::Object", ecsReadObject)
+	RegisterECSType("ecs::IntList", ecsReadList(ecsReadPOD[int32]))
+	RegisterECSType("ecs::UInt8List", ecsReadList(ecsReadPOD[uint8]))
+	RegisterECSType("ecs::UInt16List", ecsReadList(ecsReadPOD[uint16]))
+	RegisterECSType("ecs::UInt32List", ecsReadList(ecsReadPOD[uint32]))
+	RegisterECSType("ecs::FloatList", ecsReadList(ecsReadPOD[float32]))
+	RegisterECSType("ecs::Point2List", ecsReadList(ecsReadPOD[ECSPoint2]))
+	RegisterECSType("ecs::Point3List", ecsReadList(ecsReadPOD[ECSPoint3]))
+	RegisterECSType("ecs::Point4List", ecsReadList(ecsReadPOD[ECSPoint4]))
+	RegisterECSType("ecs::BoolList", ecsReadList(func(r *danet.BitReader) (any, error) { return r.ReadBit() }))
+	RegisterECSType("ecs::StringList", ecsReadList(ecsReadString))
+	RegisterECSType("ecs::EidList", ecsReadList(ecsReadEID))
+}
+
+// decodeECSComponentValues reads component values that follow the template
+// in construction message: compressed count, then for each component
+// compressed index into template component list followed by the value.
+// Stops at first component with type that has no registered decoder and returns
+// the rest of the block as remainder.
+func decodeECSComponentValues(ecs *ECS, templ *ECSTemplate, r *danet.BitReader) (map[uint32]any, []byte, error) {
+	ret := map[uint32]any{}
+	count, err := r.ReadCompressed()
+	if err != nil {
+		return ret, nil, fmt.Errorf("reading component count: %w", err)
+	}
+	for i := range count {
+		idx, err := r.ReadCompressed()
+		if err != nil {
+			return ret, nil, fmt.Errorf("reading component %d index: %w", i, err)
+		}
+		if idx >= uint64(len(templ.Components)) {
+			return ret, nil, fmt.Errorf("component %d index %d out of template %q range %d", i, idx, templ.Name, len(templ.Components))
+		}
+		comp, ok := ecs.ComponentDefs[templ.Components[idx]]
+		if !ok {
+			return ret, nil, fmt.Errorf("component %d has no definition", templ.Components[idx])
+		}
+		dec, ok := ecsTypeDecoders[comp.Type]
+		if !ok {
+			rem := r.ReadRemaining()
+			return ret, rem, fmt.Errorf("component %08x has unknown type %08x", comp.Name, comp.Type)
+		}
+		v, err := dec(r)
+		if err != nil {
+			return ret, nil, fmt.Errorf("reading component %08x value: %w", comp.Name, err)
+		}
+		ret[comp.Name] = v
+	}
+	return ret, r.ReadRemaining(), nil
+}
@@ -0,0 +1,143 @@
+/*
+	wrpl: War Thunder replay parsing library (golang)
+	Copyright (C) 2025 flexcoral
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published
+	by the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package wrpl
+
+import (
+	"encoding/binary"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/maxsupermanhd/wrpl-inspector/danet"
+)
+
+// ecsTyped is type hash followed by value, as in ecs::Array items
+func ecsTyped(typeName string, value ...byte) []byte {
+	return append(binary.LittleEndian.AppendUint32(nil, ECSHash(typeName)), value...)
+}
+
+func TestECSTypeDecoders(t *testing.T) {
+	f32 := func(v float32) []byte { return binary.LittleEndian.AppendUint32(nil, math.Float32bits(v)) }
+	for _, tc := range []struct {
+		typ     string
+		payload []byte
+		want    any
+		err     bool
+	}{
+		{"ecs::Tag", nil, struct{}{}, false},
+		{"bool", []byte{0x80}, true, false},
+		{"bool", []byte{0x7f}, false, false},
+		{"bool", nil, nil, true},
+		{"int8_t", []byte{0xfe}, int8(-2), false},
+		{"uint16_t", []byte{0x34, 0x12}, uint16(0x1234), false},
+		{"int", []byte{0xff, 0xff, 0xff, 0xff}, int32(-1), false},
+		{"int", []byte{0xff, 0xff}, nil, true},
+		{"uint64_t", []byte{1, 2, 3, 4, 5, 6, 7, 8}, uint64(0x0807060504030201), false},
+		{"float", f32(1.5), float32(1.5), false},
+		{"double", binary.LittleEndian.AppendUint64(nil, math.Float64bits(-2)), float64(-2), false},
+		{"Point3", append(append(f32(1), f32(2)...), f32(3)...), ECSPoint3{1, 2, 3}, false},
+		{"ecs::string", []byte{0x03, 'a', 'b', 'c'}, "abc", false},
+		{"ecs::string", []byte{0x05, 'a'}, nil, true},
+		{"ecs::EntityId", []byte{0x15, 0x00}, ECSEntityID(5), false},
+		{"ecs::IntList", []byte{0x02, 1, 0, 0, 0, 2, 0, 0, 0}, []any{int32(1), int32(2)}, false},
+		{"ecs::BoolList", []byte{0x03, 0xa0}, []any{true, false, true}, false},
+		{"ecs::StringList", []byte{0x02, 0x01, 'x', 0x00}, []any{"x", ""}, false},
+		{"ecs::UInt8List", []byte{0x81, 0x7f}, nil, true},
+		{"ecs::Array", append([]byte{0x01}, ecsTyped("int", 7, 0, 0, 0)...), []ECSArrayItem{{Type: ECSHash("int"), Value: int32(7)}}, false},
+		{"ecs::Array", append([]byte{0x01}, ecsTyped("no such type")...), nil, true},
+		{"ecs::Object", append([]byte{0x01, 0x01, 'k'}, ecsTyped("bool", 0x80)...), []ECSArrayItem{{Key: "k", Type: ECSHash("bool"), Value: true}}, false},
+	} {
+		dec, ok := ecsTypeDecoders[ECSHash(tc.typ)]
+		if !ok {
+			t.Fatalf("%s is not registered", tc.typ)
+		}
+		if n, _ := ECSTypeName(ECSHash(tc.typ)); n != tc.typ {
+			t.Errorf("%s is registered as %q", tc.typ, n)
+		}
+		got, err := dec(danet.NewBitReader(tc.payload))
+		if (err != nil) != tc.err {
+			t.Errorf("%s %x: unexpected error %v", tc.typ, tc.payload, err)
+			continue
+		}
+		if !tc.err && !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s %x: want %#v got %#v", tc.typ, tc.payload, tc.want, got)
+		}
+	}
+}
+
+func TestDecodeECSComponentValues(t *testing.T) {
+	ecs := &ECS{ComponentDefs: map[ECSComponentID]*ECSComponent{
+		0: {Name: ECSHash("health"), Type: ECSHash("int"), ResolvedName: "health"},
+		1: {Name: ECSHash("alive"), Type: ECSHash("bool")},
+		2: {Name: ECSHash("blob"), Type: 0xdeadbeef},
+	}}
+	templ := &ECSTemplate{Name: "tank", Components: []ECSComponentID{0, 1, 2, 9}}
+	for _, tc := range []struct {
+		name    string
+		payload []byte
+		want    map[uint32]any
+		rem     []byte
+		err     bool
+	}{
+		{"empty", []byte{0x00, 0xaa}, map[uint32]any{}, []byte{0xaa}, false},
+		{"int and bit", []byte{0x02, 0x00, 7, 0, 0, 0, 0x01, 0x80}, map[uint32]any{ECSHash("health"): int32(7), ECSHash("alive"): true}, []byte{}, false},
+		{"unaligned after bit", []byte{0x02, 0x01, 0x80, 0x03, 0x80, 0x00, 0x00, 0x00}, map[uint32]any{ECSHash("alive"): true, ECSHash("health"): int32(7)}, []byte{}, false},
+		{"unknown type", []byte{0x02, 0x00, 1, 0, 0, 0, 0x02, 0xaa, 0xbb}, map[uint32]any{ECSHash("health"): int32(1)}, []byte{0xaa, 0xbb}, true},
+		{"index out of template", []byte{0x01, 0x05}, map[uint32]any{}, nil, true},
+		{"no definition", []byte{0x01, 0x03}, map[uint32]any{}, nil, true},
+		{"truncated value", []byte{0x01, 0x00, 7}, map[uint32]any{}, nil, true},
+		{"truncated count", []byte{}, map[uint32]any{}, nil, true},
+	} {
+		got, rem, err := decodeECSComponentValues(ecs, templ, danet.NewBitReader(tc.payload))
+		if (err != nil) != tc.err {
+			t.Errorf("%s: unexpected error %v", tc.name, err)
+		}
+		if !reflect.DeepEqual(got, tc.want) || !reflect.DeepEqual(rem, tc.rem) {
+			t.Errorf("%s: want %v rem %x, got %v rem %x", tc.name, tc.want, tc.rem, got, rem)
+		}
+	}
+
+	named := ecs.NamedValues(map[uint32]any{ECSHash("health"): int32(7), ECSHash("alive"): true})
+	if len(named) != 2 || named["health"] != int32(7) || named[ecs.ComponentName(ECSHash("alive"))] != true {
+		t.Errorf("named values: %v", named)
+	}
+}
+
+func TestRegisterECSType(t *testing.T) {
+	h := ECSHash("test::Pair")
+	t.Cleanup(func() {
+		delete(ecsTypeDecoders, h)
+		delete(ecsTypeNames, h)
+	})
+	RegisterECSType("test::Pair", func(r *danet.BitReader) (any, error) {
+		b, err := r.ReadBytes(2)
+		if err != nil {
+			return nil, err
+		}
+		return [2]byte{b[0], b[1]}, nil
+	})
+	ecs := &ECS{ComponentDefs: map[ECSComponentID]*ECSComponent{0: {Name: 0x1234, Type: h}}}
+	got, _, err := decodeECSComponentValues(ecs, &ECSTemplate{Components: []ECSComponentID{0}}, danet.NewBitReader([]byte{0x01, 0x00, 0xaa, 0xbb}))
+	if err != nil || got[0x1234] != [2]byte{0xaa, 0xbb} {
+		t.Fatalf("registered decoder is not used: %v %v", got, err)
+	}
+	if n, ok := ECSTypeName(h); !ok || n != "test::Pair" {
+		t.Fatalf("type name: %q", n)
+	}
+}
@@ -0,0 +1,155 @@
+/*
+	wrpl: War Thunder replay parsing library (golang)
+	Copyright (C) 2025 flexcoral
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published
+	by the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package wrpl
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// ECSNameDict resolves component and type name hashes from a list of
+// candidate names, hashes that were looked up but not found are counted
+// so the list can be extended later
+type ECSNameDict struct {
+	lock       sync.Mutex
+	names      map[uint32]string
+	unresolved map[uint32]int
+}
+
+func NewECSNameDict() *ECSNameDict {
+	d := &ECSNameDict{
+		names:      map[uint32]string{},
+		unresolved: map[uint32]int{},
+	}
+	for h, n := range ecsTypeNames {
+		d.names[h] = n
+	}
+	return d
+}
+
+// LoadECSNameDict reads candidate names from a text file, one name per line,
+// empty lines and lines starting with # are ignored
+func LoadECSNameDict(path string) (*ECSNameDict, error) {
+	d := NewECSNameDict()
+	f, err := os.Open(path)
+	if err != nil {
+		return d, err
+	}
+	defer f.Close()
+	err = d.ReadNames(f)
+	if err != nil {
+		return d, fmt.Errorf("reading %q: %w", path, err)
+	}
+	return d, nil
+}
+
+func (d *ECSNameDict) ReadNames(r io.Reader) error {
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		l := strings.TrimSpace(sc.Text())
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+		d.Add(l)
+	}
+	return sc.Err()
+}
+
+func (d *ECSNameDict) Add(name string) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	h := ECSHash(name)
+	d.names[h] = name
+	delete(d.unresolved, h)
+}
+
+func (d *ECSNameDict) Len() int {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return len(d.names)
+}
+
+// Lookup resolves hash without recording misses
+func (d *ECSNameDict) Lookup(h uint32) (string, bool) {
+	if d == nil {
+		return "", false
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	n, ok := d.names[h]
+	return n, ok
+}
+
+// ResolveECS fills resolved names of all component definitions, every
+// unresolved hash is counted once per ECS no matter how many times it is
+// used or how many times ECS is resolved
+func (d *ECSNameDict) ResolveECS(ecs *ECS) {
+	if ecs == nil {
+		return
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if ecs.unresolvedBy != d {
+		ecs.unresolvedBy = d
+		ecs.unresolvedCounted = map[uint32]bool{}
+	}
+	resolve := func(h uint32) string {
+		n, ok := d.names[h]
+		if !ok && !ecs.unresolvedCounted[h] {
+			ecs.unresolvedCounted[h] = true
+			d.unresolved[h]++
+		}
+		return n
+	}
+	for _, comp := range ecs.ComponentDefs {
+		comp.ResolvedName = resolve(comp.Name)
+		comp.ResolvedType = resolve(comp.Type)
+	}
+}
+
+// Unresolved returns hashes that were not resolved, most encountered first
+func (d *ECSNameDict) Unresolved() []uint32 {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	ret := slices.Sorted(maps.Keys(d.unresolved))
+	slices.SortStableFunc(ret, func(a, b uint32) int {
+		return d.unresolved[b] - d.unresolved[a]
+	})
+	return ret
+}
+
+// WriteUnresolved writes unresolved hashes with their encounter count, one per line
+func (d *ECSNameDict) WriteUnresolved(w io.Writer) error {
+	for _, h := range d.Unresolved() {
+		d.lock.Lock()
+		c := d.unresolved[h]
+		d.lock.Unlock()
+		_, err := fmt.Fprintf(w, "%08x\t%d\n", h, c)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
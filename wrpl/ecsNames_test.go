@@ -0,0 +1,49 @@
+/*
+	wrpl: War Thunder replay parsing library (golang)
+	Copyright (C) 2025 flexcoral
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published
+	by the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package wrpl
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestResolveECSCountsOncePerECS(t *testing.T) {
+	d := NewECSNameDict()
+	d.Add("known")
+	newECS := func() *ECS {
+		return &ECS{ComponentDefs: map[ECSComponentID]*ECSComponent{
+			1: {Name: ECSHash("known"), Type: 0x1234},
+			2: {Name: 0x5678, Type: 0x1234},
+		}}
+	}
+	a := newECS()
+	d.ResolveECS(a)
+	d.ResolveECS(a)
+	if a.ComponentDefs[1].ResolvedName != "known" {
+		t.Fatalf("name not resolved: %+v", a.ComponentDefs[1])
+	}
+	d.ResolveECS(newECS())
+	buf := &bytes.Buffer{}
+	if err := d.WriteUnresolved(buf); err != nil {
+		t.Fatal(err)
+	}
+	if want := "00001234\t2\n00005678\t2\n"; buf.String() != want {
+		t.Fatalf("want unresolved\n%sgot\n%s", want, buf)
+	}
+}
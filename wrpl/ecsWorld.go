@@ -0,0 +1,186 @@
+/*
+	wrpl: War Thunder replay parsing library (golang)
+	Copyright (C) 2025 flexcoral
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published
+	by the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package wrpl
+
+import (
+	"maps"
+	"slices"
+	"sort"
+	"time"
+)
+
+type ECSEntityState struct {
+	EID       uint64
+	Template  ECSTemplateID
+	CreatedAt uint32
+	UpdatedAt uint32
+	Values    map[uint32]any
+}
+
+type ECSWorldState struct {
+	CurrentTime uint32
+	Entities    map[uint64]*ECSEntityState
+}
+
+// ECSWorldEvent is one of construction, replication or destruction of entity
+type ECSWorldEvent struct {
+	CurrentTime uint32
+	Construct   *ECSMessage
+	Replicate   *ECSReplication
+	Destroy     *ECSDestruction
+}
+
+type ecsWorldCheckpoint struct {
+	eventIndex int
+	state      *ECSWorldState
+}
+
+// ECSWorld allows rewinding entity state to any point of the replay
+type ECSWorld struct {
+	ECS         *ECS
+	Events      []ECSWorldEvent
+	checkpoints []ecsWorldCheckpoint
+}
+
+// DefaultECSWorldCheckpointInterval is distance between world checkpoints in replay time
+const DefaultECSWorldCheckpointInterval = 30 * time.Second
+
+// NewECSWorld collects ECS events of parsed packets in packet order. Event
+// times are kept non-decreasing: packet with time earlier than the one
+// before it (broken or joined streams) is treated as happening at the time
+// of the previous packet, so that checkpoints and At see events in order.
+func NewECSWorld(rpl *WRPL, checkpointInterval time.Duration) *ECSWorld {
+	w := &ECSWorld{}
+	if rpl.Parsed == nil {
+		return w
+	}
+	w.ECS = rpl.Parsed.ECS
+	at := uint32(0)
+	for _, pk := range rpl.Packets {
+		if pk.Parsed == nil {
+			continue
+		}
+		dat, ok := pk.Parsed.Data.(ParsedPacketECS)
+		if !ok {
+			continue
+		}
+		at = max(at, pk.CurrentTime)
+		for _, msg := range dat.Messages {
+			w.Events = append(w.Events, ECSWorldEvent{CurrentTime: at, Construct: msg})
+		}
+		for _, msg := range dat.Replications {
+			w.Events = append(w.Events, ECSWorldEvent{CurrentTime: at, Replicate: msg})
+		}
+		for _, msg := range dat.Destructions {
+			w.Events = append(w.Events, ECSWorldEvent{CurrentTime: at, Destroy: msg})
+		}
+	}
+
+	interval := uint32(checkpointInterval.Milliseconds())
+	if interval == 0 {
+		interval = uint32(DefaultECSWorldCheckpointInterval.Milliseconds())
+	}
+	state := &ECSWorldState{Entities: map[uint64]*ECSEntityState{}}
+	w.checkpoints = append(w.checkpoints, ecsWorldCheckpoint{eventIndex: 0, state: state.clone()})
+	nextCheckpoint := interval
+	for i, ev := range w.Events {
+		for ev.CurrentTime >= nextCheckpoint {
+			state.CurrentTime = nextCheckpoint
+			w.checkpoints = append(w.checkpoints, ecsWorldCheckpoint{eventIndex: i, state: state.clone()})
+			nextCheckpoint += interval
+		}
+		state.apply(ev)
+	}
+	return w
+}
+
+// Duration returns time of the last event
+func (w *ECSWorld) Duration() uint32 {
+	if len(w.Events) == 0 {
+		return 0
+	}
+	return w.Events[len(w.Events)-1].CurrentTime
+}
+
+// At returns state of the world after all events up to and including t
+func (w *ECSWorld) At(t uint32) *ECSWorldState {
+	if len(w.checkpoints) == 0 {
+		return &ECSWorldState{CurrentTime: t, Entities: map[uint64]*ECSEntityState{}}
+	}
+	ci := sort.Search(len(w.checkpoints), func(i int) bool {
+		return w.checkpoints[i].state.CurrentTime > t
+	}) - 1
+	cp := w.checkpoints[max(ci, 0)]
+	state := cp.state.clone()
+	for _, ev := range w.Events[cp.eventIndex:] {
+		if ev.CurrentTime > t {
+			break
+		}
+		state.apply(ev)
+	}
+	state.CurrentTime = t
+	return state
+}
+
+// Alive lists entities sorted by eid
+func (s *ECSWorldState) Alive() []*ECSEntityState {
+	ret := make([]*ECSEntityState, 0, len(s.Entities))
+	for _, k := range slices.Sorted(maps.Keys(s.Entities)) {
+		ret = append(ret, s.Entities[k])
+	}
+	return ret
+}
+
+func (s *ECSWorldState) apply(ev ECSWorldEvent) {
+	switch {
+	case ev.Construct != nil:
+		e := &ECSEntityState{
+			EID:       ev.Construct.EID,
+			Template:  ev.Construct.Template,
+			CreatedAt: ev.CurrentTime,
+			UpdatedAt: ev.CurrentTime,
+			Values:    map[uint32]any{},
+		}
+		maps.Copy(e.Values, ev.Construct.Values)
+		s.Entities[e.EID] = e
+	case ev.Replicate != nil:
+		e, ok := s.Entities[ev.Replicate.EID]
+		if !ok {
+			return
+		}
+		e.UpdatedAt = ev.CurrentTime
+		maps.Copy(e.Values, ev.Replicate.Values)
+	case ev.Destroy != nil:
+		delete(s.Entities, ev.Destroy.EID)
+	}
+}
+
+// clone copies entity states, component values themselves are never mutated so they are shared
+func (s *ECSWorldState) clone() *ECSWorldState {
+	ret := &ECSWorldState{
+		CurrentTime: s.CurrentTime,
+		Entities:    make(map[uint64]*ECSEntityState, len(s.Entities)),
+	}
+	for k, v := range s.Entities {
+		e := *v
+		e.Values = maps.Clone(v.Values)
+		ret.Entities[k] = &e
+	}
+	return ret
+}
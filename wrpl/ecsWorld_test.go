@@ -0,0 +1,102 @@
+/*
+	wrpl: War Thunder replay parsing library (golang)
+	Copyright (C) 2025 flexcoral
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published
+	by the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package wrpl
+
+import (
+	"math/rand/v2"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func ecsWorldPacket(time uint32, d ParsedPacketECS) *WRPLRawPacket {
+	return &WRPLRawPacket{CurrentTime: time, PacketType: byte(PacketTypeECS), Parsed: &ParsedPacket{Name: "ecs", Data: d}}
+}
+
+// ecsWorldSample has entities constructed, replicated and destroyed at random
+// over 100 seconds
+func ecsWorldSample() *WRPL {
+	rnd := rand.New(rand.NewPCG(1, 2))
+	rpl := &WRPL{Parsed: &ParsedInfo{ECS: &ECS{}}}
+	alive := []uint64{}
+	for tm := uint32(0); tm < 100000; tm += uint32(rnd.IntN(700)) {
+		d := ParsedPacketECS{}
+		switch n := rnd.IntN(3); {
+		case n == 0 || len(alive) == 0:
+			eid := uint64(len(rpl.Packets) + 1)
+			alive = append(alive, eid)
+			d.Messages = []*ECSMessage{{EID: eid, Template: ECSTemplateID(eid % 3), Values: map[uint32]any{1: int32(tm)}}}
+		case n == 1:
+			d.Replications = []*ECSReplication{{EID: alive[rnd.IntN(len(alive))], Values: map[uint32]any{2: int32(tm)}}}
+		default:
+			i := rnd.IntN(len(alive))
+			d.Destructions = []*ECSDestruction{{EID: alive[i]}}
+			alive = append(alive[:i], alive[i+1:]...)
+		}
+		rpl.Packets = append(rpl.Packets, ecsWorldPacket(tm, d))
+	}
+	return rpl
+}
+
+// ecsWorldLinear applies every event up to t from the start
+func ecsWorldLinear(w *ECSWorld, t uint32) *ECSWorldState {
+	state := &ECSWorldState{CurrentTime: t, Entities: map[uint64]*ECSEntityState{}}
+	for _, ev := range w.Events {
+		if ev.CurrentTime <= t {
+			state.apply(ev)
+		}
+	}
+	return state
+}
+
+func TestECSWorldAt(t *testing.T) {
+	w := NewECSWorld(ecsWorldSample(), 10*time.Second)
+	if len(w.checkpoints) != 10 {
+		t.Fatalf("expected 10 checkpoints, got %d", len(w.checkpoints))
+	}
+	// forward, across checkpoint boundaries, backward and past the end
+	for _, tm := range []uint32{0, 500, 9999, 10000, 10001, 35000, 99999, 200000, 60000, 20000, 19999, 1, 0} {
+		got, want := w.At(tm), ecsWorldLinear(w, tm)
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("at %d: %d entities, linear replay has %d", tm, len(got.Entities), len(want.Entities))
+		}
+	}
+	if len(w.At(w.Duration()).Entities) == 0 {
+		t.Fatal("sample has no entities alive at the end")
+	}
+}
+
+func TestECSWorldNonMonotonic(t *testing.T) {
+	rpl := &WRPL{Parsed: &ParsedInfo{ECS: &ECS{}}}
+	rpl.Packets = []*WRPLRawPacket{
+		ecsWorldPacket(1000, ParsedPacketECS{Messages: []*ECSMessage{{EID: 1}}}),
+		ecsWorldPacket(500, ParsedPacketECS{Messages: []*ECSMessage{{EID: 2}}}),
+		ecsWorldPacket(1500, ParsedPacketECS{Destructions: []*ECSDestruction{{EID: 1}}}),
+	}
+	w := NewECSWorld(rpl, time.Second)
+	if s := w.At(999); len(s.Entities) != 0 {
+		t.Errorf("entity going back in time is visible before the packet preceding it: %v", s.Alive())
+	}
+	if s := w.At(1000); len(s.Entities) != 2 || s.Entities[2].CreatedAt != 1000 {
+		t.Errorf("at 1000: %v", s.Alive())
+	}
+	if s := w.At(1500); len(s.Entities) != 1 || s.Entities[2] == nil {
+		t.Errorf("at 1500: %v", s.Alive())
+	}
+}
@@ -0,0 +1,141 @@
+/*
+	wrpl: War Thunder replay parsing library (golang)
+	Copyright (C) 2025 flexcoral
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published
+	by the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package wrpl
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Editing works on raw packets only, parsed state is left as is and
+// ParsePacketStream should be called again if it is needed. WriteWRPL
+// recalculates SettingsBLKSize and ResultsBlkOffset of the header.
+
+// PacketSignature matches packets of Type which payload starts with Prefix
+type PacketSignature struct {
+	Type   PacketType
+	Prefix []byte
+}
+
+// ParsePacketType accepts type number or name as in queries (mpi, ecs, chat...)
+func ParsePacketType(s string) (PacketType, error) {
+	if pt, ok := queryPacketTypeNames[strings.ToLower(s)]; ok {
+		return pt, nil
+	}
+	v, err := strconv.ParseUint(s, 0, 8)
+	if err != nil {
+		return 0, fmt.Errorf("unknown packet type %q", s)
+	}
+	return PacketType(v), nil
+}
+
+// ParsePacketSignature parses signature in form type:hexprefix, for
+// example mpi:025873f0, prefix can be omitted
+func ParsePacketSignature(s string) (PacketSignature, error) {
+	typ, prefix, _ := strings.Cut(s, ":")
+	pt, err := ParsePacketType(typ)
+	if err != nil {
+		return PacketSignature{}, err
+	}
+	b, err := hex.DecodeString(prefix)
+	if err != nil {
+		return PacketSignature{}, fmt.Errorf("signature %q prefix: %w", s, err)
+	}
+	return PacketSignature{Type: pt, Prefix: b}, nil
+}
+
+func (s PacketSignature) Match(pk *WRPLRawPacket) bool {
+	return PacketType(pk.PacketType) == s.Type && bytes.HasPrefix(pk.PacketPayload, s.Prefix)
+}
+
+func (s PacketSignature) String() string {
+	return fmt.Sprintf("%d:%s", s.Type, hex.EncodeToString(s.Prefix))
+}
+
+// TrimPackets keeps packets with time in [from, to) milliseconds, to of 0
+// means till the end. Packets outside of the window are kept if keep
+// (when not nil) reports true for them.
+func TrimPackets(packets []*WRPLRawPacket, from, to uint32, keep func(*WRPLRawPacket) bool) []*WRPLRawPacket {
+	ret := []*WRPLRawPacket{}
+	for _, pk := range packets {
+		inside := pk.CurrentTime >= from && (to == 0 || pk.CurrentTime < to)
+		if inside || keep != nil && keep(pk) {
+			ret = append(ret, pk)
+		}
+	}
+	return ret
+}
+
+// DropPackets removes packets drop reports true for
+func DropPackets(packets []*WRPLRawPacket, drop func(*WRPLRawPacket) bool) []*WRPLRawPacket {
+	ret := []*WRPLRawPacket{}
+	for _, pk := range packets {
+		if !drop(pk) {
+			ret = append(ret, pk)
+		}
+	}
+	return ret
+}
+
+// ShiftTime adds delta milliseconds to time of every packet, result is
+// clamped to the range of uint32
+func ShiftTime(packets []*WRPLRawPacket, delta int64) {
+	for _, pk := range packets {
+		pk.CurrentTime = uint32(min(max(int64(pk.CurrentTime)+delta, 0), math.MaxUint32))
+	}
+}
+
+// DropResults removes results BLK and its offset from the header
+func (rpl *WRPL) DropResults() {
+	rpl.Results = nil
+	rpl.ResultsJSON = ""
+	rpl.ResultsBLK = nil
+	rpl.Header.ResultsBlkOffset = 0
+}
+
+// ConcatParts joins server replay parts into one standalone replay:
+// header and settings of part 0, results of the last part that has them,
+// packets of all parts without next segment markers in between
+func ConcatParts(replayBytes [][]byte) (*WRPL, error) {
+	rpl, err := ReadPartedWRPL(replayBytes)
+	if err != nil {
+		return nil, err
+	}
+	if rpl == nil {
+		return nil, fmt.Errorf("no replay parts")
+	}
+	rpl.Packets = DropPackets(rpl.Packets, func(pk *WRPLRawPacket) bool {
+		return PacketType(pk.PacketType) == PacketTypeNextSegment
+	})
+	ParsePacketStream(rpl)
+	return rpl, nil
+}
+
+// ConcatPartsFolder is ConcatParts of every .wrpl file in the folder
+func ConcatPartsFolder(folderPath string) (*WRPL, error) {
+	parts, err := readPartsFolder(folderPath)
+	if err != nil {
+		return nil, err
+	}
+	return ConcatParts(parts)
+}
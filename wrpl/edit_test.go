@@ -0,0 +1,162 @@
+/*
+	wrpl: War Thunder replay parsing library (golang)
+	Copyright (C) 2025 flexcoral
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published
+	by the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package wrpl
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+)
+
+// editSamplePart builds server replay part with packets at given times
+func editSamplePart(t *testing.T, part byte, results bool, times ...uint32) []byte {
+	rpl := &WRPL{SettingsBLK: fuzzFatBlk}
+	copy(rpl.Header.Magic[:], []byte{0xe5, 0xac, 0x00, 0x10})
+	rpl.Header.SessionID = 0x1234
+	rpl.Header.Raw_Unknown2[1] = 0x5a
+	rpl.Header.ReplayPartNumber = part
+	for _, tm := range times {
+		rpl.Packets = append(rpl.Packets, &WRPLRawPacket{CurrentTime: tm, PacketType: byte(PacketTypeMPI), PacketPayload: []byte{0x02, 0x58, 0x73, 0xf0, part}})
+	}
+	rpl.Packets = append(rpl.Packets, &WRPLRawPacket{CurrentTime: times[len(times)-1], PacketType: byte(PacketTypeNextSegment), PacketPayload: []byte{0, 0, 0, 0}})
+	if results {
+		rpl.ResultsBLK = fuzzFatBlk
+	}
+	b, err := WriteWRPL(rpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestEditRoundTrip(t *testing.T) {
+	rpl, err := ConcatParts([][]byte{
+		editSamplePart(t, 0, false, 0, 1000, 2000),
+		editSamplePart(t, 1, true, 3000, 4000, 5000),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rpl.Packets) != 6 || len(rpl.ResultsBLK) == 0 || len(rpl.SettingsBLK) == 0 {
+		t.Fatalf("concatenated replay has %d packets, results %d bytes, settings %d bytes", len(rpl.Packets), len(rpl.ResultsBLK), len(rpl.SettingsBLK))
+	}
+
+	sig, err := ParsePacketSignature("mpi:025873f001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rpl.Packets = TrimPackets(rpl.Packets, 1000, 5000, nil)
+	rpl.Packets = DropPackets(rpl.Packets, sig.Match)
+	ShiftTime(rpl.Packets, -1500)
+	if len(rpl.Packets) != 2 || rpl.Packets[0].CurrentTime != 0 || rpl.Packets[1].CurrentTime != 500 {
+		t.Fatalf("unexpected packets after editing: %+v %+v", rpl.Packets[0], rpl.Packets[len(rpl.Packets)-1])
+	}
+
+	for _, dropResults := range []bool{false, true} {
+		if dropResults {
+			rpl.DropResults()
+		}
+		b, err := WriteWRPL(rpl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rpl2, err := ReadWRPL(bytes.NewReader(b), true, true, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rpl2.Packets) != 2 || rpl2.Packets[1].CurrentTime != 500 {
+			t.Fatalf("written replay has %d packets", len(rpl2.Packets))
+		}
+		if rpl2.SettingsJSON != rpl.SettingsJSON || rpl2.ResultsJSON != rpl.ResultsJSON {
+			t.Fatalf("blobs changed: settings %q results %q", rpl2.SettingsJSON, rpl2.ResultsJSON)
+		}
+	}
+}
+
+func TestPacketTypeName(t *testing.T) {
+	for name, pt := range queryPacketTypeNames {
+		if pt.Name() != name {
+			t.Errorf("%d is named %q, ParsePacketType accepts %q", pt, pt.Name(), name)
+		}
+	}
+	if PacketType(0xff).Name() != "" {
+		t.Error("unknown type has a name")
+	}
+}
+
+func TestReadPartedWRPLContinuity(t *testing.T) {
+	// part 1 closed with marker followed by more packets of the same tick
+	flushed, err := ReadWRPL(bytes.NewReader(editSamplePart(t, 1, false, 3000, 4000)), true, true, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 10 {
+		flushed.Packets = append(flushed.Packets, &WRPLRawPacket{CurrentTime: 4000, PacketType: byte(PacketTypeMPI), PacketPayload: []byte{0x02, 0x58, 0x73, 0xf0, 0x01}})
+	}
+	flushedBytes, err := WriteWRPL(flushed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// part 1 without marker
+	unclosed, err := ReadWRPL(bytes.NewReader(editSamplePart(t, 1, false, 3000, 4000)), true, true, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unclosed.Packets = unclosed.Packets[:len(unclosed.Packets)-1]
+	unclosedBytes, err := WriteWRPL(unclosed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		parts  [][]byte
+		issues []string
+	}{
+		{"clean join", [][]byte{
+			editSamplePart(t, 0, false, 0, 1000, 2000),
+			editSamplePart(t, 1, false, 2000, 3000),
+			editSamplePart(t, 3, true, 4000, 5000),
+		}, nil},
+		{"marker before trailing packets", [][]byte{
+			editSamplePart(t, 0, false, 0, 1000, 2000),
+			flushedBytes,
+			editSamplePart(t, 3, true, 4000, 5000),
+		}, nil},
+		{"time gap backwards", [][]byte{
+			editSamplePart(t, 0, false, 0, 1000, 2000),
+			editSamplePart(t, 1, true, 1500, 3000),
+		}, []string{"part 1 starts at 1500 but part 0 ends at 2000"}},
+		{"missing marker", [][]byte{
+			editSamplePart(t, 0, false, 0, 1000, 2000),
+			unclosedBytes,
+			editSamplePart(t, 3, true, 4000, 5000),
+		}, []string{"part 1 does not end with next segment marker before part 3"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rpl, err := ReadPartedWRPL(tc.parts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(rpl.ContinuityIssues, tc.issues) {
+				t.Fatalf("got issues %q, want %q", rpl.ContinuityIssues, tc.issues)
+			}
+		})
+	}
+}
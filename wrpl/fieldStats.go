@@ -0,0 +1,279 @@
+/*
+	wrpl: War Thunder replay parsing library (golang)
+	Copyright (C) 2025 flexcoral
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published
+	by the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package wrpl
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Byte offset classes
+const (
+	OffsetClassConstant = "constant"
+	OffsetClassEnum     = "enum"
+	OffsetClassCounter  = "counter"
+	OffsetClassVariable = "variable"
+	OffsetClassRandom   = "random"
+)
+
+// DefaultFieldStatsOffsets limits how many leading bytes are analyzed
+const DefaultFieldStatsOffsets = 512
+
+// OffsetStats describes values of one byte offset across packets
+type OffsetStats struct {
+	Offset    int
+	Present   int
+	Histogram [256]int
+	Distinct  int
+	Min       byte
+	Max       byte
+	// Shannon entropy of the values in bits (0..8)
+	Entropy float64
+	// number of changes between consecutive packets going up or down
+	Increasing int
+	Decreasing int
+	// Pearson correlation of the value with packet CurrentTime
+	TimeCorrelation float64
+	Class           string
+}
+
+// Monotonic is true when value changes only in one direction
+func (s *OffsetStats) Monotonic() bool {
+	return s.Distinct > 1 && (s.Increasing == 0 || s.Decreasing == 0)
+}
+
+// FieldCandidate is a guess about multi-byte field at Offset
+type FieldCandidate struct {
+	Offset int
+	Kind   string
+	Reason string
+}
+
+// FieldStats is per-offset analysis of a packet set (for example packets
+// of one signature or search results)
+type FieldStats struct {
+	Packets    []*WRPLRawPacket
+	Offsets    []OffsetStats
+	Candidates []FieldCandidate
+}
+
+// AnalyzePacketFields computes statistics of first maxOffsets bytes of the packets
+func AnalyzePacketFields(packets []*WRPLRawPacket, maxOffsets int) *FieldStats {
+	ret := &FieldStats{Packets: packets}
+	maxLen := 0
+	for _, pk := range packets {
+		maxLen = max(maxLen, len(pk.PacketPayload))
+	}
+	maxLen = min(maxLen, maxOffsets)
+	ret.Offsets = make([]OffsetStats, maxLen)
+	times := make([]float64, 0, len(packets))
+	vals := make([]float64, 0, len(packets))
+	for off := range maxLen {
+		s := &ret.Offsets[off]
+		s.Offset = off
+		s.Min = 0xff
+		times = times[:0]
+		vals = vals[:0]
+		prev := -1
+		for _, pk := range packets {
+			if off >= len(pk.PacketPayload) {
+				continue
+			}
+			v := pk.PacketPayload[off]
+			s.Present++
+			s.Histogram[v]++
+			s.Min = min(s.Min, v)
+			s.Max = max(s.Max, v)
+			if prev >= 0 {
+				if int(v) > prev {
+					s.Increasing++
+				} else if int(v) < prev {
+					s.Decreasing++
+				}
+			}
+			prev = int(v)
+			times = append(times, float64(pk.CurrentTime))
+			vals = append(vals, float64(v))
+		}
+		for _, c := range s.Histogram {
+			if c == 0 {
+				continue
+			}
+			s.Distinct++
+			p := float64(c) / float64(s.Present)
+			s.Entropy -= p * math.Log2(p)
+		}
+		s.TimeCorrelation = correlation(times, vals)
+		s.Class = classifyOffset(s)
+	}
+	ret.Candidates = findFieldCandidates(packets, maxLen)
+	return ret
+}
+
+func classifyOffset(s *OffsetStats) string {
+	switch {
+	case s.Distinct <= 1:
+		return OffsetClassConstant
+	case s.Monotonic() && s.Distinct > 2:
+		return OffsetClassCounter
+	case s.Distinct <= 8:
+		return OffsetClassEnum
+	case s.Entropy > 7 || (s.Present < 256 && s.Entropy > 0.95*math.Log2(float64(s.Present))):
+		return OffsetClassRandom
+	default:
+		return OffsetClassVariable
+	}
+}
+
+// Correlation returns Pearson correlation between byte values at offsets a
+// and b in packets that have both of them
+func (s *FieldStats) Correlation(a, b int) float64 {
+	va := []float64{}
+	vb := []float64{}
+	for _, pk := range s.Packets {
+		if a >= len(pk.PacketPayload) || b >= len(pk.PacketPayload) {
+			continue
+		}
+		va = append(va, float64(pk.PacketPayload[a]))
+		vb = append(vb, float64(pk.PacketPayload[b]))
+	}
+	return correlation(va, vb)
+}
+
+// TopCorrelations returns up to n offsets most correlated with offset
+func (s *FieldStats) TopCorrelations(offset, n int) []OffsetCorrelation {
+	ret := []OffsetCorrelation{}
+	for _, o := range s.Offsets {
+		if o.Offset == offset || o.Class == OffsetClassConstant {
+			continue
+		}
+		c := s.Correlation(offset, o.Offset)
+		if math.IsNaN(c) {
+			continue
+		}
+		ret = append(ret, OffsetCorrelation{Offset: o.Offset, Correlation: c})
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return math.Abs(ret[i].Correlation) > math.Abs(ret[j].Correlation)
+	})
+	if len(ret) > n {
+		ret = ret[:n]
+	}
+	return ret
+}
+
+type OffsetCorrelation struct {
+	Offset      int
+	Correlation float64
+}
+
+// correlation is NaN when one of the series is constant
+func correlation(a, b []float64) float64 {
+	n := float64(len(a))
+	if len(a) < 2 {
+		return math.NaN()
+	}
+	var sa, sb float64
+	for i := range a {
+		sa += a[i]
+		sb += b[i]
+	}
+	ma, mb := sa/n, sb/n
+	var cov, da, db float64
+	for i := range a {
+		cov += (a[i] - ma) * (b[i] - mb)
+		da += (a[i] - ma) * (a[i] - ma)
+		db += (b[i] - mb) * (b[i] - mb)
+	}
+	if da == 0 || db == 0 {
+		return math.NaN()
+	}
+	return cov / math.Sqrt(da*db)
+}
+
+// findFieldCandidates interprets every offset as little-endian u16/u32/f32
+// and looks for values that behave like counters, ids and floats
+func findFieldCandidates(packets []*WRPLRawPacket, maxLen int) []FieldCandidate {
+	ret := []FieldCandidate{}
+	for off := range maxLen {
+		for _, width := range []int{2, 4} {
+			vals := []float64{}
+			times := []float64{}
+			floats := []float64{}
+			for _, pk := range packets {
+				if off+width > len(pk.PacketPayload) {
+					continue
+				}
+				b := pk.PacketPayload[off : off+width]
+				var v uint32
+				if width == 2 {
+					v = uint32(binary.LittleEndian.Uint16(b))
+				} else {
+					v = binary.LittleEndian.Uint32(b)
+					floats = append(floats, float64(math.Float32frombits(v)))
+				}
+				vals = append(vals, float64(v))
+				times = append(times, float64(pk.CurrentTime))
+			}
+			if len(vals) < 4 {
+				continue
+			}
+			kind := fmt.Sprintf("u%d", width*8)
+			distinct := map[float64]struct{}{}
+			nondecreasing := true
+			for i, v := range vals {
+				distinct[v] = struct{}{}
+				if i > 0 && v < vals[i-1] {
+					nondecreasing = false
+				}
+			}
+			if len(distinct) == 1 {
+				continue
+			}
+			if nondecreasing && len(distinct) > len(vals)/2 {
+				ret = append(ret, FieldCandidate{Offset: off, Kind: kind, Reason: fmt.Sprintf("counter, time correlation %.2f", correlation(times, vals))})
+				continue
+			}
+			if len(distinct) <= max(2, len(vals)/8) {
+				ret = append(ret, FieldCandidate{Offset: off, Kind: kind, Reason: fmt.Sprintf("id, %d distinct values", len(distinct))})
+				continue
+			}
+			if width == 4 && plausibleFloats(floats) {
+				ret = append(ret, FieldCandidate{Offset: off, Kind: "f32", Reason: "values are finite and in sane range"})
+			}
+		}
+	}
+	return ret
+}
+
+func plausibleFloats(v []float64) bool {
+	if len(v) == 0 {
+		return false
+	}
+	good := 0
+	for _, f := range v {
+		a := math.Abs(f)
+		if !math.IsNaN(f) && (a == 0 || (a > 1e-4 && a < 1e7)) {
+			good++
+		}
+	}
+	return good*100 >= len(v)*95
+}
@@ -0,0 +1,67 @@
+/*
+	wrpl: War Thunder replay parsing library (golang)
+	Copyright (C) 2025 flexcoral
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published
+	by the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package wrpl
+
+import (
+	"math/rand/v2"
+	"strings"
+	"testing"
+)
+
+func TestAnalyzePacketFields(t *testing.T) {
+	rnd := rand.New(rand.NewPCG(3, 4))
+	packets := []*WRPLRawPacket{}
+	for i := range 1000 {
+		packets = append(packets, &WRPLRawPacket{CurrentTime: uint32(i * 10), PacketType: byte(PacketTypeMPI), PacketPayload: []byte{
+			0x42,                  // constant
+			byte(i / 4),           // counter
+			byte(rnd.Uint()),      // random
+			byte(i % 4),           // enum
+			byte(i), byte(i >> 8), // u16 counter
+		}})
+	}
+	stats := AnalyzePacketFields(packets, DefaultFieldStatsOffsets)
+	if len(stats.Offsets) != 6 {
+		t.Fatalf("expected 6 offsets, got %d", len(stats.Offsets))
+	}
+	for off, class := range []string{OffsetClassConstant, OffsetClassCounter, OffsetClassRandom, OffsetClassEnum} {
+		if s := stats.Offsets[off]; s.Class != class {
+			t.Errorf("offset %d classified as %s (%d distinct, entropy %.2f), want %s", off, s.Class, s.Distinct, s.Entropy, class)
+		}
+	}
+	if s := stats.Offsets[1]; !s.Monotonic() || s.TimeCorrelation < 0.99 {
+		t.Errorf("counter is not monotonic or correlated with time: %+v", s)
+	}
+	if s := stats.Offsets[0]; s.Present != 1000 || s.Min != 0x42 || s.Max != 0x42 || s.Entropy != 0 {
+		t.Errorf("constant offset stats: %+v", s)
+	}
+
+	found := false
+	for _, c := range stats.Candidates {
+		if c.Offset == 4 && c.Kind == "u16" {
+			found = strings.HasPrefix(c.Reason, "counter")
+		}
+		if c.Offset == 0 && c.Kind == "u32" {
+			t.Errorf("u32 over constant, counter and random bytes is a candidate: %+v", c)
+		}
+	}
+	if !found {
+		t.Errorf("u16 counter at offset 4 not found in %+v", stats.Candidates)
+	}
+}
@@ -0,0 +1,230 @@
+/*
+	wrpl: War Thunder replay parsing library (golang)
+	Copyright (C) 2025 flexcoral
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published
+	by the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package wrpl
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/maxsupermanhd/wrpl-inspector/danet"
+	"github.com/pierrec/lz4/v4"
+)
+
+// Fuzz targets only check that decoders return errors instead of
+// panicking or allocating unbounded memory, run one with
+//
+//	go test ./wrpl -run '^$' -fuzz FuzzParseBlk -fuzztime 1m
+
+// newFuzzReplay returns replay with initialized parse state and no packets
+func newFuzzReplay() *WRPL {
+	rpl := &WRPL{}
+	ParsePacketStream(rpl)
+	return rpl
+}
+
+// fuzzParse parses packet the way ParsePacketStream does, so panics that
+// would be hidden as ErrParserPanic fail the fuzz target
+func fuzzParse(t *testing.T, rpl *WRPL, pk *WRPLRawPacket) {
+	_, err := parsePacketRecover(rpl, pk)
+	if errors.Is(err, ErrParserPanic) {
+		t.Fatalf("type %d payload %x: %v", pk.PacketType, pk.PacketPayload, err)
+	}
+}
+
+// fuzzBlkBombs are zstd BLKs that decompress past maxBlkSize
+func fuzzBlkBombs(tb testing.TB) [][]byte {
+	fat := zstdBlob(tb, append([]byte{0x01}, make([]byte, maxBlkSize)...))
+	l := len(fat)
+	return [][]byte{
+		append([]byte{0x02, byte(l >> 16), byte(l >> 8), byte(l)}, fat...),
+		append([]byte{0x04}, zstdBlob(tb, make([]byte, maxBlkSize+1))...),
+	}
+}
+
+// fuzzMPIBombs are compressed MPI packets that decompress past their limits
+func fuzzMPIBombs(tb testing.TB) [][]byte {
+	return [][]byte{
+		append([]byte{0x00, 0x58, 0x22, 0xf0, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00}, zstdBlob(tb, make([]byte, maxCompressedBlobSize+1))...),
+		append([]byte{0x02, 0x58, 0x2d, 0xf0, 0x01, 0x00, 0x10, 0x00, 0x00, 0x04}, zstdBlob(tb, make([]byte, maxSlotMessagesSize+1))...),
+	}
+}
+
+func TestDecompressionBombs(t *testing.T) {
+	for _, b := range fuzzBlkBombs(t) {
+		if _, err := ParseBlk(b); !errors.Is(err, ErrDecompressionLimit) {
+			t.Errorf("BLK type %d: want %v, got %v", b[0], ErrDecompressionLimit, err)
+		}
+	}
+	for _, b := range fuzzMPIBombs(t) {
+		rpl := newFuzzReplay()
+		if _, err := parsePacketMPI(rpl, &WRPLRawPacket{PacketType: byte(PacketTypeMPI), PacketPayload: b}); !errors.Is(err, ErrDecompressionLimit) {
+			t.Errorf("MPI %x: want %v, got %v", b[:4], ErrDecompressionLimit, err)
+		}
+	}
+}
+
+// fuzzFatBlk is {"a": 7, "b": {"c": "text"}}
+var fuzzFatBlk = []byte{
+	0x01,                               // FAT
+	0x03, 0x06, 'a', 0, 'b', 0, 'c', 0, // names count, size, names
+	0x02,       // blocks
+	0x02, 0x05, // params count, data size
+	't', 'e', 'x', 't', 0,
+	0x00, 0x00, 0x00, 0x02, 0x07, 0x00, 0x00, 0x00, // a: int 7
+	0x02, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, // c: string at 0
+	0x00, 0x01, 0x01, 0x01, // root: 1 field, 1 child starting at 1
+	0x02, 0x01, 0x00, // b: 1 field
+}
+
+func FuzzParseBlk(f *testing.F) {
+	f.Add(fuzzFatBlk)
+	f.Add([]byte{0x01})
+	f.Add([]byte{0x02, 0x00, 0x00, 0x00})
+	enc, err := zstd.NewWriter(nil)
+	if err != nil {
+		f.Fatal(err)
+	}
+	compressed := enc.EncodeAll(fuzzFatBlk, nil)
+	enc.Close()
+	l := len(compressed)
+	f.Add(append([]byte{0x02, byte(l >> 16), byte(l >> 8), byte(l)}, compressed...))
+	for _, b := range fuzzBlkBombs(f) {
+		f.Add(b)
+	}
+	f.Fuzz(func(t *testing.T, b []byte) {
+		ParseBlk(b)
+	})
+}
+
+func FuzzParseFatBlk(f *testing.F) {
+	f.Add(fuzzFatBlk[1:])
+	f.Fuzz(func(t *testing.T, b []byte) {
+		parseFatBlk(b)
+	})
+}
+
+func FuzzReadVariableLengthSize(f *testing.F) {
+	for _, v := range []uint32{0, 0x3f, 0x40, 0x3fff, 0x4000, 0x1fffff, 0x200000, 0x0fffffff, 0x10000000, 0xffffffff} {
+		buf := &bytes.Buffer{}
+		if err := writeVariableLengthSize(buf, v); err != nil {
+			f.Fatal(err)
+		}
+		f.Add(buf.Bytes())
+	}
+	f.Fuzz(func(t *testing.T, b []byte) {
+		v, err := readVariableLengthSize(bytes.NewReader(b))
+		if err != nil {
+			return
+		}
+		buf := &bytes.Buffer{}
+		if err := writeVariableLengthSize(buf, v); err != nil {
+			t.Fatal(err)
+		}
+		v2, err := readVariableLengthSize(buf)
+		if err != nil || v2 != v {
+			t.Fatalf("size %d encoded as %x decodes to %d (%v)", v, buf.Bytes(), v2, err)
+		}
+	})
+}
+
+func FuzzReadEID(f *testing.F) {
+	f.Add([]byte{0x01, 0x00})
+	f.Add([]byte{0x02, 0x00, 0x05})
+	f.Add([]byte{0x00, 0x00, 0x00, 0x00})
+	f.Fuzz(func(t *testing.T, b []byte) {
+		readEID(danet.NewBitReader(b))
+	})
+}
+
+func FuzzReadPacketStream(f *testing.F) {
+	buf := &bytes.Buffer{}
+	err := WritePackets(buf, []*WRPLRawPacket{
+		{CurrentTime: 1, PacketType: byte(PacketTypeMPI), PacketPayload: []byte{0x02, 0x58, 0x78, 0xf0}},
+		{CurrentTime: 1, PacketType: byte(PacketTypeChat), PacketPayload: []byte{0x00}},
+	})
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(buf.Bytes())
+	f.Add([]byte{0x81, 0x10})
+	f.Add([]byte{0x00, 0xff, 0xff, 0xff, 0xff})
+	f.Fuzz(func(t *testing.T, b []byte) {
+		rpl := newFuzzReplay()
+		rpl.Packets, _ = ReadPacketStream(rpl, bytes.NewReader(b))
+		for _, pk := range rpl.Packets {
+			fuzzParse(t, rpl, pk)
+		}
+	})
+}
+
+func FuzzParsePacketECS(f *testing.F) {
+	f.Add([]byte{ECSControlEntityCreation, 0x00, 0x01, 0x00, 0x00})
+	f.Add([]byte{ECSControlEntityReplication, 0x00, 0x01, 0x00, 0x02, 0xaa, 0xbb})
+	f.Add([]byte{ECSControlEntityMsg, 0x00, 0x01, 0x00, 0x01, 0xaa})
+	f.Add([]byte{ECSControlEntityDestruction, 0x01, 0x01, 0x00, 0x01, 0x00})
+	plain := []byte{0x00, 0x01, 0x00, 0x40, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
+	compressed := make([]byte, lz4.CompressBlockBound(len(plain)))
+	n, err := lz4.CompressBlock(plain, compressed, nil)
+	if err != nil {
+		f.Fatal(err)
+	}
+	if n > 0 {
+		f.Add(append([]byte{ECSControlEntityCreationCompressed}, compressed[:n]...))
+	}
+	f.Add([]byte{ECSControlEntityMsgCompressed, 0xf0, 0x00})
+	f.Fuzz(func(t *testing.T, b []byte) {
+		rpl := newFuzzReplay()
+		fuzzParse(t, rpl, &WRPLRawPacket{PacketType: byte(PacketTypeECS), PacketPayload: b})
+	})
+}
+
+func FuzzParsePacketMPI(f *testing.F) {
+	// uncompressed slot messages: count 2, zero length message, slot 1 message
+	f.Add([]byte{0x02, 0x58, 0x2d, 0xf0, 0x00, 0x02, 0x00, 0x00, 0x00, 0x02, 0x00, 0x01, 0xaa})
+	enc, err := zstd.NewWriter(nil)
+	if err != nil {
+		f.Fatal(err)
+	}
+	slot := []byte{0x01, 0x00, 0x0a, 0x00, 0x03, 0x70, 0x00, 0x01, 0x08, 0x60, 0x01, 0x00, 0x00, 0x00}
+	f.Add(append([]byte{0x02, 0x58, 0x2d, 0xf0, 0x01, 0x00, 0x00, 0x00, 0x00}, enc.EncodeAll(slot, nil)...))
+	f.Add(append([]byte{0x00, 0x58, 0x22, 0xf0, 0x00, 0x00, 0x01}, enc.EncodeAll([]byte("blob"), nil)...))
+	enc.Close()
+	f.Add([]byte{0x02, 0x58, 0x58, 0xf0, 0x10, 0x00, 0xfe, 0x3f, 0x01, 0x00, 0x00, 0x00, 0x02, 't', '1'})
+	f.Add([]byte{0x02, 0x58, 0x78, 0xf0, 0x01, 0x00, 0x3e, 0x01, 0x00, 0x00, 0x00, 0x02, 'a', 'w'})
+	f.Add([]byte{0xff, 0x0f, 0x01, 0x81, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00})
+	for _, b := range fuzzMPIBombs(f) {
+		f.Add(b)
+	}
+	f.Fuzz(func(t *testing.T, b []byte) {
+		rpl := newFuzzReplay()
+		fuzzParse(t, rpl, &WRPLRawPacket{PacketType: byte(PacketTypeMPI), PacketPayload: b})
+	})
+}
+
+func FuzzParsePacket(f *testing.F) {
+	for _, pt := range []PacketType{PacketTypeStartMarker, PacketTypeChat, PacketTypeNextSegment, PacketTypeSnapshot, PacketTypeReplayHeaderInfo} {
+		f.Add(byte(pt), []byte{0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07})
+	}
+	f.Fuzz(func(t *testing.T, pt byte, b []byte) {
+		rpl := newFuzzReplay()
+		fuzzParse(t, rpl, &WRPLRawPacket{PacketType: pt, PacketPayload: b})
+	})
+}
@@ -86,3 +86,11 @@ func (h *WRPLHeader) Describe() string {
 	}
 	return h.SessionHEX() + rec
 }
+
+func (h *WRPLHeader) Level() string {
+	return string(bytes.Trim(h.Raw_Level[:], "\x00"))
+}
+
+func (h *WRPLHeader) BattleType() string {
+	return string(bytes.Trim(h.Raw_BattleType[:], "\x00"))
+}
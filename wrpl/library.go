@@ -0,0 +1,120 @@
+/*
+	wrpl: War Thunder replay parsing library (golang)
+	Copyright (C) 2025 flexcoral
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published
+	by the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package wrpl
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"runtime"
+	"sync"
+)
+
+// LibraryMatch is search result of one replay file, Packets are indices
+// in the packet stream of that file and Times are their timestamps
+type LibraryMatch struct {
+	Path    string
+	Header  WRPLHeader
+	Total   int
+	Packets []int
+	Times   []uint32
+	Err     error
+}
+
+// SearchLibrary parses every replay file in paths with given amount of
+// workers (GOMAXPROCS if not positive) and reports packets matching to
+// report. Report is called for every file, including ones that failed to
+// parse or had no hits, and is never called concurrently.
+// Returns ctx.Err() if search was cancelled.
+func SearchLibrary(ctx context.Context, paths []string, workers int, match func(*WRPLRawPacket) bool, report func(LibraryMatch)) error {
+	return runLibraryJobs(ctx, paths, workers, func(p string) LibraryMatch {
+		return searchLibraryFile(p, match)
+	}, report)
+}
+
+// runLibraryJobs processes jobs with given amount of workers (GOMAXPROCS
+// if not positive) and passes results to report, never concurrently
+func runLibraryJobs[J, R any](ctx context.Context, jobs []J, workers int, do func(J) R, report func(R)) error {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	queue := make(chan J)
+	results := make(chan R)
+	wg := sync.WaitGroup{}
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range queue {
+				r := do(j)
+				select {
+				case results <- r:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		defer close(queue)
+		for _, j := range jobs {
+			select {
+			case queue <- j:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	for r := range results {
+		if ctx.Err() != nil {
+			continue
+		}
+		report(r)
+	}
+	return ctx.Err()
+}
+
+func searchLibraryFile(p string, match func(*WRPLRawPacket) bool) LibraryMatch {
+	ret := LibraryMatch{Path: p}
+	b, err := os.ReadFile(p)
+	if err != nil {
+		ret.Err = err
+		return ret
+	}
+	rpl, err := ReadWRPL(bytes.NewReader(b), false, true, false)
+	if rpl != nil {
+		ret.Header = rpl.Header
+	}
+	if err != nil {
+		ret.Err = err
+		return ret
+	}
+	ret.Total = len(rpl.Packets)
+	for i, pk := range rpl.Packets {
+		if pk != nil && match(pk) {
+			ret.Packets = append(ret.Packets, i)
+			ret.Times = append(ret.Times, pk.CurrentTime)
+		}
+	}
+	return ret
+}
@@ -0,0 +1,227 @@
+/*
+	wrpl: War Thunder replay parsing library (golang)
+	Copyright (C) 2025 flexcoral
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published
+	by the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package wrpl
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+// LifeEnd is how vehicle life ended
+type LifeEnd byte
+
+const (
+	// LifeEndUnknown is set when life is only known from kills
+	LifeEndUnknown LifeEnd = iota
+	// LifeEndDestroyed is set when vehicle entity was destroyed
+	LifeEndDestroyed
+	// LifeEndRespawn is set when player showed up in another vehicle
+	LifeEndRespawn
+	// LifeEndBattleEnd is set when vehicle entity was alive till the end of replay
+	LifeEndBattleEnd
+)
+
+func (e LifeEnd) String() string {
+	switch e {
+	case LifeEndDestroyed:
+		return "destroyed"
+	case LifeEndRespawn:
+		return "respawned"
+	case LifeEndBattleEnd:
+		return "battle end"
+	default:
+		return "unknown"
+	}
+}
+
+func (e LifeEnd) MarshalText() ([]byte, error) {
+	return []byte(e.String()), nil
+}
+
+// VehicleLife is one spawn of the player. Lives known only from kills
+// start at the first kill and end at the last one.
+type VehicleLife struct {
+	Vehicle string
+	// Eid of vehicle entity, 0 if life is only known from kills
+	Eid        uint64
+	Start, End uint32
+	Kills      int
+	Ended      LifeEnd
+}
+
+func (l *VehicleLife) Duration() time.Duration {
+	return time.Duration(l.End-l.Start) * time.Millisecond
+}
+
+// Lineup is vehicle history of one participant
+type Lineup struct {
+	Participant *Participant
+	Lives       []*VehicleLife
+	// Unobserved are vehicles listed for participant (results) without any life
+	Unobserved []string
+}
+
+// lineupVehicleKeys are words in component names holding vehicle name
+var lineupVehicleKeys = []string{"vehicle", "model", "unit"}
+
+// lineupComponentNames maps component name hashes to lowercased resolved names
+func lineupComponentNames(ecs *ECS) map[uint32]string {
+	names := map[uint32]string{}
+	for _, c := range ecs.ComponentDefs {
+		names[c.Name] = strings.ToLower(c.ResolvedName)
+	}
+	return names
+}
+
+// entityVehicle returns vehicle name of ECS entity: string value of component
+// with resolved name mentioning vehicle, model or unit, or name of template
+// mentioning vehicle. Components need resolved names (ECSNameDict.ResolveECS),
+// names are built by lineupComponentNames.
+func entityVehicle(ecs *ECS, names map[uint32]string, templ ECSTemplateID, values map[uint32]any) string {
+	keys := []uint32{}
+	for k := range values {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		s, ok := values[k].(string)
+		if !ok || s == "" {
+			continue
+		}
+		for _, w := range lineupVehicleKeys {
+			if strings.Contains(names[k], w) {
+				return s
+			}
+		}
+	}
+	if t, ok := ecs.TemplateDefs[templ]; ok && strings.Contains(strings.ToLower(t.Name), "vehicle") {
+		return t.Name
+	}
+	return ""
+}
+
+type lineupKill struct {
+	p       *Participant
+	time    uint32
+	vehicle string
+}
+
+// spawnedBetween reports if other life started after start of life and before t
+func (l *Lineup) spawnedBetween(life *VehicleLife, t uint32) bool {
+	return slices.ContainsFunc(l.Lives, func(o *VehicleLife) bool {
+		return o != life && o.Start > life.Start && o.Start <= t
+	})
+}
+
+// NewLineups builds vehicle history of every roster participant from
+// vehicle entities linked to them, kills and vehicles listed in results
+func NewLineups(rpl *WRPL) []*Lineup {
+	if rpl.Parsed == nil || rpl.Parsed.Roster == nil {
+		return nil
+	}
+	r := rpl.Parsed.Roster
+	end := uint32(0)
+	if len(rpl.Packets) > 0 {
+		end = rpl.Packets[len(rpl.Packets)-1].CurrentTime
+	}
+	lineups := map[*Participant]*Lineup{}
+	ret := []*Lineup{}
+	for _, p := range r.Participants {
+		l := &Lineup{Participant: p}
+		lineups[p] = l
+		ret = append(ret, l)
+	}
+	alive := map[uint64]*VehicleLife{}
+	kills := []lineupKill{}
+	names := lineupComponentNames(rpl.Parsed.ECS)
+	for _, pk := range rpl.Packets {
+		if pk.Parsed == nil {
+			continue
+		}
+		switch d := pk.Parsed.Data.(type) {
+		case ParsedPacketECS:
+			for _, m := range d.Messages {
+				p := r.ByEid(m.EID)
+				if p == nil || alive[m.EID] != nil {
+					continue
+				}
+				v := entityVehicle(rpl.Parsed.ECS, names, m.Template, m.Values)
+				if v == "" {
+					continue
+				}
+				life := &VehicleLife{Vehicle: v, Eid: m.EID, Start: pk.CurrentTime, End: end, Ended: LifeEndBattleEnd}
+				alive[m.EID] = life
+				lineups[p].Lives = append(lineups[p].Lives, life)
+			}
+			for _, m := range d.Destructions {
+				if life := alive[m.EID]; life != nil {
+					life.End = pk.CurrentTime
+					life.Ended = LifeEndDestroyed
+					delete(alive, m.EID)
+				}
+			}
+		case ParsedPacketKill:
+			if p := r.BySlot(d.KillerID, pk.CurrentTime); p != nil {
+				kills = append(kills, lineupKill{p, pk.CurrentTime, d.KillerVehicle})
+			}
+		}
+	}
+	for _, k := range kills {
+		l := lineups[k.p]
+		var life *VehicleLife
+		for _, v := range l.Lives {
+			if v.Vehicle != k.vehicle || v.Start > k.time {
+				continue
+			}
+			// life known from kills goes on until player is seen in anything else
+			if v.Eid != 0 && v.End >= k.time || v.Eid == 0 && !l.spawnedBetween(v, k.time) {
+				life = v
+			}
+		}
+		if life == nil {
+			life = &VehicleLife{Vehicle: k.vehicle, Start: k.time, End: k.time}
+			l.Lives = append(l.Lives, life)
+		}
+		if life.Eid == 0 {
+			life.End = k.time
+		}
+		life.Kills++
+	}
+	for _, l := range ret {
+		slices.SortStableFunc(l.Lives, func(a, b *VehicleLife) int {
+			return int(int64(a.Start) - int64(b.Start))
+		})
+		for i, life := range l.Lives {
+			if i+1 < len(l.Lives) && life.Ended != LifeEndDestroyed {
+				next := l.Lives[i+1]
+				life.Ended = LifeEndRespawn
+				if life.Eid != 0 {
+					life.End = min(life.End, next.Start)
+				}
+			}
+		}
+		for _, v := range l.Participant.Vehicles {
+			if !slices.ContainsFunc(l.Lives, func(life *VehicleLife) bool { return life.Vehicle == v }) {
+				l.Unobserved = append(l.Unobserved, v)
+			}
+		}
+	}
+	return ret
+}
@@ -0,0 +1,83 @@
+/*
+	wrpl: War Thunder replay parsing library (golang)
+	Copyright (C) 2025 flexcoral
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published
+	by the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package wrpl
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestLineups(t *testing.T) {
+	b := newSampleBuilder().
+		player(0, 1, 123456, "RealName", "[TAG]", "").
+		player(10, 2, 654321, "OtherGuy", "", "")
+	for _, k := range []struct {
+		time    uint32
+		slot    byte
+		vehicle string
+	}{{30, 1, "germ_tiger"}, {35, 1, "germ_tiger"}, {60, 1, "germ_panther"}, {90, 1, "germ_tiger"}, {150, 2, "us_m4"}} {
+		b.kill(k.time, k.slot, k.vehicle)
+	}
+	rpl := b.end(400).read(t)
+
+	// vehicle entities of the second player
+	rpl.Parsed.ECS.ComponentDefs[1] = &ECSComponent{Name: 0xabc, ResolvedName: "vehicle_name"}
+	ecsPacket := func(time uint32, d ParsedPacketECS) *WRPLRawPacket {
+		return &WRPLRawPacket{CurrentTime: time, PacketType: byte(PacketTypeECS), Parsed: &ParsedPacket{Name: "ecs", Data: d}}
+	}
+	other := rpl.Parsed.Roster.ByName("OtherGuy")
+	rpl.Parsed.Roster.linkEid(7, other)
+	rpl.Parsed.Roster.linkEid(8, other)
+	other.Vehicles = append(other.Vehicles, "us_m18")
+	i := slices.IndexFunc(rpl.Packets, func(pk *WRPLRawPacket) bool { return pk.CurrentTime == 150 })
+	rpl.Packets = slices.Insert(rpl.Packets, i,
+		ecsPacket(100, ParsedPacketECS{Messages: []*ECSMessage{{EID: 7, Values: map[uint32]any{0xabc: "us_m4"}}}}))
+	rpl.Packets = slices.Insert(rpl.Packets, i+2,
+		ecsPacket(200, ParsedPacketECS{Destructions: []*ECSDestruction{{EID: 7}}}),
+		ecsPacket(250, ParsedPacketECS{Messages: []*ECSMessage{{EID: 8, Values: map[uint32]any{0xabc: "us_m10"}}}}))
+
+	lineups := NewLineups(rpl)
+	if len(lineups) != 2 {
+		t.Fatalf("expected 2 lineups, got %d", len(lineups))
+	}
+	want := [][]VehicleLife{{
+		{Vehicle: "germ_tiger", Start: 30, End: 35, Kills: 2, Ended: LifeEndRespawn},
+		{Vehicle: "germ_panther", Start: 60, End: 60, Kills: 1, Ended: LifeEndRespawn},
+		{Vehicle: "germ_tiger", Start: 90, End: 90, Kills: 1, Ended: LifeEndUnknown},
+	}, {
+		{Vehicle: "us_m4", Eid: 7, Start: 100, End: 200, Kills: 1, Ended: LifeEndDestroyed},
+		{Vehicle: "us_m10", Eid: 8, Start: 250, End: 400, Ended: LifeEndBattleEnd},
+	}}
+	for i, l := range lineups {
+		if len(l.Lives) != len(want[i]) {
+			t.Fatalf("%s: want %d lives, got %d", l.Participant, len(want[i]), len(l.Lives))
+		}
+		for j, life := range l.Lives {
+			if *life != want[i][j] {
+				t.Errorf("%s life %d: want %+v got %+v", l.Participant, j, want[i][j], *life)
+			}
+		}
+	}
+	if !slices.Equal(lineups[1].Unobserved, []string{"us_m18"}) {
+		t.Errorf("unobserved vehicles: %v", lineups[1].Unobserved)
+	}
+	if d := lineups[1].Lives[0].Duration(); d.Milliseconds() != 100 {
+		t.Errorf("duration: %s", d)
+	}
+}
@@ -43,17 +43,61 @@ const (
 	PacketTypeReplayHeaderInfo PacketType = 8
 )
 
+// Name is lowercase name of packet type as accepted by ParsePacketType,
+// empty for unknown types
+func (t PacketType) Name() string {
+	switch t {
+	case PacketTypeEndMarker:
+		return "endmarker"
+	case PacketTypeStartMarker:
+		return "startmarker"
+	case PacketTypeAircraftSmall:
+		return "aircraftsmall"
+	case PacketTypeChat:
+		return "chat"
+	case PacketTypeMPI:
+		return "mpi"
+	case PacketTypeNextSegment:
+		return "nextsegment"
+	case PacketTypeECS:
+		return "ecs"
+	case PacketTypeSnapshot:
+		return "snapshot"
+	case PacketTypeReplayHeaderInfo:
+		return "replayheaderinfo"
+	default:
+		return ""
+	}
+}
+
+// MaxPacketSize limits size of one packet in the packet stream, real
+// packets are way smaller, anything bigger means corrupted stream
+const MaxPacketSize = 16 << 20
+
 var (
 	ErrUnknownPacket = errors.New("unknown packet")
+	ErrParserPanic   = errors.New("packet parser panic")
+	// ErrDecompressionLimit is returned when compressed blob expands past
+	// the limit set for its kind, real data is way smaller
+	ErrDecompressionLimit = errors.New("decompressed data is over the limit")
 )
 
 type ParsedPacket struct {
-	Name string
-	Data any
+	Name  string
+	Data  any
+	Spans []FieldSpan `json:",omitempty"`
 }
 
 func ParsePacket(rpl *WRPL, pk *WRPLRawPacket) (*ParsedPacket, error) {
 	switch PacketType(pk.PacketType) {
+	case PacketTypeStartMarker:
+		return parsePacketStartMarker(pk)
+	case PacketTypeNextSegment:
+		return parsePacketNextSegment(pk)
+	case PacketTypeSnapshot:
+		return parsePacketSnapshot(pk)
+	case PacketTypeReplayHeaderInfo:
+		return parsePacketReplayHeaderInfo(pk)
 	case PacketTypeChat:
 		return parsePacketChat(rpl, pk)
 	case PacketTypeMPI:
@@ -18,40 +18,50 @@
 
 package wrpl
 
-import "bytes"
-
 type ParsedPacketChat struct {
 	CurrentTime uint32
 	Sender      string
 	Content     string
 	ChannelType byte
 	IsEnemy     byte
+	Channel     ChatChannel
+	// Player is sender resolved after the whole stream is parsed, entry of
+	// Parsed.Players of the slot sender had or one of the roster if none
+	Player *Player
 }
 
 func parsePacketChat(rpl *WRPL, pk *WRPLRawPacket) (ret *ParsedPacket, err error) {
-	r := bytes.NewReader(pk.PacketPayload)
+	r := newSpanReader(pk.PacketPayload)
 	parsed := ParsedPacketChat{}
 	ret = &ParsedPacket{
 		Name: "chat",
 		Data: parsed,
 	}
+	defer func() {
+		ret.Spans = r.Spans
+	}()
 	parsed.CurrentTime = pk.CurrentTime
-	parsed.Sender, err = PacketReadLenString(r)
+	parsed.Sender, err = PacketReadLenString(r.Reader)
 	if err != nil {
 		return
 	}
-	parsed.Content, err = PacketReadLenString(r)
+	r.field("Sender")
+	parsed.Content, err = PacketReadLenString(r.Reader)
 	if err != nil {
 		return
 	}
+	r.field("Content")
 	parsed.ChannelType, err = r.ReadByte()
 	if err != nil {
 		return
 	}
+	r.field("ChannelType")
+	parsed.Channel = ChatChannel(parsed.ChannelType)
 	parsed.IsEnemy, err = r.ReadByte()
 	if err != nil {
 		return
 	}
+	r.field("IsEnemy")
 	ret.Data = parsed
 	rpl.Parsed.Chat = append(rpl.Parsed.Chat, &parsed)
 	return
@@ -2,8 +2,8 @@ package wrpl
 
 import (
 	"encoding/binary"
+	"errors"
 	"fmt"
-	"io"
 
 	"github.com/maxsupermanhd/wrpl-inspector/danet"
 	"github.com/pierrec/lz4/v4"
@@ -11,19 +11,54 @@ import (
 
 // ID_CONNECTION_REQUEST_ACCEPTED = 0x11
 // ID_DISCONNECT = 0x13
-// ID_ENTITY_MSG = 0x20
-// ID_ENTITY_MSG_COMPRESSED = 0x21
-// ID_ENTITY_REPLICATION = 0x22
-// ID_ENTITY_REPLICATION_COMPRESSED = 0x23
-// ID_ENTITY_CREATION = 0x24
-// ID_ENTITY_CREATION_COMPRESSED = 0x25
-// ID_ENTITY_DESTRUCTION = 0x26
-// IS_COMPRESSED = [ID_ENTITY_MSG_COMPRESSED, ID_ENTITY_REPLICATION_COMPRESSED, ID_ENTITY_CREATION_COMPRESSED]
+const (
+	ECSControlEntityMsg                   byte = 0x20
+	ECSControlEntityMsgCompressed         byte = 0x21
+	ECSControlEntityReplication           byte = 0x22
+	ECSControlEntityReplicationCompressed byte = 0x23
+	ECSControlEntityCreation              byte = 0x24
+	ECSControlEntityCreationCompressed    byte = 0x25
+	ECSControlEntityDestruction           byte = 0x26
+)
 
+// ECSMessage is entity construction message
 type ECSMessage struct {
 	EID      uint64
 	Template ECSTemplateID
 	Data     []byte
+	// Values are keyed by component name hash, see ECS.NamedValues
+	Values      map[uint32]any
+	Rem         []byte
+	DecodeError string
+	// Player is resolved by the roster
+	Player string
+}
+
+// ECSReplication carries updated component values of already constructed entity
+type ECSReplication struct {
+	EID         uint64
+	Template    ECSTemplateID
+	Data        []byte
+	Values      map[uint32]any
+	Rem         []byte
+	DecodeError string
+	// Player is resolved by the roster
+	Player string
+}
+
+// ECSEntityMessage is an event addressed to the entity, payload is not decoded yet
+type ECSEntityMessage struct {
+	EID  uint64
+	Data []byte
+	// Player is resolved by the roster
+	Player string
+}
+
+// ECSDestruction marks entity as destroyed
+type ECSDestruction struct {
+	EID uint64
+	// Player is resolved by the roster
+	Player string
 }
 
 type ParsedPacketECS struct {
@@ -34,6 +69,9 @@ type ParsedPacketECS struct {
 	DecompressSize   int
 	MessageCount     byte
 	Messages         []*ECSMessage
+	Replications     []*ECSReplication
+	EntityMessages   []*ECSEntityMessage
+	Destructions     []*ECSDestruction
 }
 
 type ECSTemplateID uint16
@@ -42,6 +80,47 @@ type ECSComponentID uint16
 type ECS struct {
 	TemplateDefs  map[ECSTemplateID]*ECSTemplate
 	ComponentDefs map[ECSComponentID]*ECSComponent
+	// Entities holds templates of entities that are alive at the current point of parsing
+	Entities map[uint64]ECSTemplateID
+
+	// unresolvedBy is dictionary that counted unresolved hashes of this ECS
+	// in unresolvedCounted, so that resolving it again does not count them twice
+	unresolvedBy      *ECSNameDict
+	unresolvedCounted map[uint32]bool
+	// componentsByName indexes ComponentDefs by name hash as they are parsed
+	componentsByName map[uint32]*ECSComponent
+}
+
+// Component returns definition of component with given name hash
+func (ecs *ECS) Component(name uint32) *ECSComponent {
+	if c, ok := ecs.componentsByName[name]; ok {
+		return c
+	}
+	// definitions added without parsing (tests, tools) are not indexed
+	for _, c := range ecs.ComponentDefs {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// ComponentName is resolved name of component name hash or the hash in hex
+// if it is not resolved (see ECSNameDict.ResolveECS)
+func (ecs *ECS) ComponentName(name uint32) string {
+	if c := ecs.Component(name); c != nil && c.ResolvedName != "" {
+		return c.ResolvedName
+	}
+	return fmt.Sprintf("%08x", name)
+}
+
+// NamedValues keys component values of ECSMessage or ECSReplication by ComponentName
+func (ecs *ECS) NamedValues(values map[uint32]any) map[string]any {
+	ret := make(map[string]any, len(values))
+	for k, v := range values {
+		ret[ecs.ComponentName(k)] = v
+	}
+	return ret
 }
 
 type ECSTemplate struct {
@@ -51,8 +130,10 @@ type ECSTemplate struct {
 }
 
 type ECSComponent struct {
-	Name uint32
-	Type uint32
+	Name         uint32
+	Type         uint32
+	ResolvedName string
+	ResolvedType string
 }
 
 func parseECSTemplate(ecs *ECS, r *danet.BitReader) (*ECSTemplate, error) {
@@ -95,6 +176,10 @@ func parseECSTemplate(ecs *ECS, r *danet.BitReader) (*ECSTemplate, error) {
 				return nil, fmt.Errorf("reading component def type hash: %w", err)
 			}
 			ecs.ComponentDefs[compID] = comp
+			if ecs.componentsByName == nil {
+				ecs.componentsByName = map[uint32]*ECSComponent{}
+			}
+			ecs.componentsByName[comp.Name] = comp
 		}
 		templDef.Components = append(templDef.Components, compID)
 	}
@@ -108,14 +193,9 @@ func parseECSConstructMessage(rpl *WRPL, r *danet.BitReader) (ret *ECSMessage, e
 	if err != nil {
 		return ret, fmt.Errorf("reading eid: %w", err)
 	}
-	blockSize, err := r.ReadCompressed()
+	blockData, err := readECSBlock(r)
 	if err != nil {
-		return ret, fmt.Errorf("reading compressed block size: %w", err)
-	}
-	blockData := make([]byte, blockSize)
-	_, err = r.Read(blockData)
-	if err != nil {
-		return ret, fmt.Errorf("reading block (size %d): %w", blockSize, err)
+		return ret, err
 	}
 	br := danet.NewBitReader(blockData)
 	templ, err := parseECSTemplate(rpl.Parsed.ECS, br)
@@ -123,10 +203,98 @@ func parseECSConstructMessage(rpl *WRPL, r *danet.BitReader) (ret *ECSMessage, e
 		return ret, fmt.Errorf("reading template: %w", err)
 	}
 	ret.Template = templ.ID
-	ret.Data, err = io.ReadAll(br)
+	rpl.Parsed.ECS.Entities[ret.EID] = templ.ID
+	valuesOffset := br.BitOffset
+	ret.Data = br.ReadRemaining()
+	br.BitOffset = valuesOffset
+	var decodeErr error
+	ret.Values, ret.Rem, decodeErr = decodeECSComponentValues(rpl.Parsed.ECS, templ, br)
+	if decodeErr != nil {
+		ret.DecodeError = decodeErr.Error()
+	}
 	return
 }
 
+func readECSBlock(r *danet.BitReader) ([]byte, error) {
+	blockSize, err := r.ReadCompressed()
+	if err != nil {
+		return nil, fmt.Errorf("reading compressed block size: %w", err)
+	}
+	if blockSize > uint64(len(r.Data)) {
+		return nil, fmt.Errorf("block size %d is longer than the message", blockSize)
+	}
+	blockData := make([]byte, blockSize)
+	_, err = r.Read(blockData)
+	if err != nil {
+		return nil, fmt.Errorf("reading block (size %d): %w", blockSize, err)
+	}
+	return blockData, nil
+}
+
+func parseECSReplicationMessage(rpl *WRPL, r *danet.BitReader) (ret *ECSReplication, err error) {
+	ret = &ECSReplication{}
+	ret.EID, err = readEID(r)
+	if err != nil {
+		return ret, fmt.Errorf("reading eid: %w", err)
+	}
+	ret.Data, err = readECSBlock(r)
+	if err != nil {
+		return ret, err
+	}
+	templID, ok := rpl.Parsed.ECS.Entities[ret.EID]
+	if !ok {
+		ret.DecodeError = "entity was not constructed"
+		return ret, nil
+	}
+	ret.Template = templID
+	var decodeErr error
+	ret.Values, ret.Rem, decodeErr = decodeECSComponentValues(rpl.Parsed.ECS, rpl.Parsed.ECS.TemplateDefs[templID], danet.NewBitReader(ret.Data))
+	if decodeErr != nil {
+		ret.DecodeError = decodeErr.Error()
+	}
+	return ret, nil
+}
+
+func parseECSEntityMessage(r *danet.BitReader) (ret *ECSEntityMessage, err error) {
+	ret = &ECSEntityMessage{}
+	ret.EID, err = readEID(r)
+	if err != nil {
+		return ret, fmt.Errorf("reading eid: %w", err)
+	}
+	ret.Data, err = readECSBlock(r)
+	return ret, err
+}
+
+func parseECSDestructionMessage(rpl *WRPL, r *danet.BitReader) (ret *ECSDestruction, err error) {
+	ret = &ECSDestruction{}
+	ret.EID, err = readEID(r)
+	if err != nil {
+		return ret, fmt.Errorf("reading eid: %w", err)
+	}
+	delete(rpl.Parsed.ECS.Entities, ret.EID)
+	return ret, nil
+}
+
+// maxECSDecompressedSize bounds lz4 output of one compressed ECS packet
+const maxECSDecompressedSize = 4 << 20
+
+// uncompressECSBlock grows output buffer until the block fits, lz4 block
+// does not store decompressed size
+func uncompressECSBlock(src []byte) ([]byte, error) {
+	size := max(len(src)*8, 64)
+	for {
+		out := make([]byte, min(size, maxECSDecompressedSize))
+		n, err := lz4.UncompressBlock(src, out)
+		if err == nil {
+			return out[:n], nil
+		}
+		if !errors.Is(err, lz4.ErrInvalidSourceShortBuffer) || len(out) >= maxECSDecompressedSize {
+			return nil, err
+		}
+		size *= 4
+	}
+}
+
 func parsePacketECS(rpl *WRPL, pk *WRPLRawPacket) (*ParsedPacket, error) {
 	dat := ParsedPacketECS{}
 	ret := &ParsedPacket{
@@ -137,14 +305,22 @@ func parsePacketECS(rpl *WRPL, pk *WRPLRawPacket) (*ParsedPacket, error) {
 	}()
 	var err error
 	r := danet.NewBitReader(pk.PacketPayload)
+	spans := newBitSpanRecorder(r)
+	defer func() {
+		ret.Spans = spans.Spans
+	}()
 	dat.Control, err = r.ReadByte()
 	if err != nil {
 		return ret, fmt.Errorf("reading ecs control byte: %w", err)
 	}
+	spans.field("Control")
 
-	if dat.Control == 0x25 {
-		decomp := make([]byte, (len(pk.PacketPayload)-1)*8)
-		dat.DecompressSize, err = lz4.UncompressBlock(pk.PacketPayload[1:], decomp)
+	switch dat.Control {
+	case ECSControlEntityMsgCompressed, ECSControlEntityReplicationCompressed, ECSControlEntityCreationCompressed:
+		dat.WasCompressed = true
+		var decomp []byte
+		decomp, err = uncompressECSBlock(pk.PacketPayload[1:])
+		dat.DecompressSize = len(decomp)
 		if err != nil {
 			dat.DecompressFailed = true
 			dat.DecompressError = err.Error()
@@ -153,26 +329,55 @@ func parsePacketECS(rpl *WRPL, pk *WRPLRawPacket) (*ParsedPacket, error) {
 			}}
 			return ret, fmt.Errorf("reading compressed ecs blob: %w", err)
 		}
-		r = danet.NewBitReader(decomp[:dat.DecompressSize])
-		dat.Control = 0x24
+		r.IgnoreBytes(len(pk.PacketPayload) - 1)
+		spans.field("Compressed")
+		// offsets in decompressed data do not map to the payload
+		spans.r = nil
+		r = danet.NewBitReader(decomp)
+		dat.Control--
 	}
 
-	if dat.Control == 0x24 {
-		dat.MessageCount, err = r.ReadByte()
-		if err != nil {
-			return ret, err
-		}
-		for range uint64(dat.MessageCount) + 1 {
+	switch dat.Control {
+	case ECSControlEntityCreation, ECSControlEntityReplication, ECSControlEntityMsg, ECSControlEntityDestruction:
+	default:
+		return nil, nil
+	}
+
+	dat.MessageCount, err = r.ReadByte()
+	if err != nil {
+		return ret, err
+	}
+	spans.field("MessageCount")
+	for i := range uint64(dat.MessageCount) + 1 {
+		switch dat.Control {
+		case ECSControlEntityCreation:
 			msg, err := parseECSConstructMessage(rpl, r)
 			if err != nil {
 				return ret, fmt.Errorf("reading ecs construct message: %w", err)
 			}
 			dat.Messages = append(dat.Messages, msg)
+		case ECSControlEntityReplication:
+			msg, err := parseECSReplicationMessage(rpl, r)
+			if err != nil {
+				return ret, fmt.Errorf("reading ecs replication message: %w", err)
+			}
+			dat.Replications = append(dat.Replications, msg)
+		case ECSControlEntityMsg:
+			msg, err := parseECSEntityMessage(r)
+			if err != nil {
+				return ret, fmt.Errorf("reading ecs entity message: %w", err)
+			}
+			dat.EntityMessages = append(dat.EntityMessages, msg)
+		case ECSControlEntityDestruction:
+			msg, err := parseECSDestructionMessage(rpl, r)
+			if err != nil {
+				return ret, fmt.Errorf("reading ecs destruction message: %w", err)
+			}
+			dat.Destructions = append(dat.Destructions, msg)
 		}
-		return ret, nil
+		spans.field(fmt.Sprintf("Message%d", i))
 	}
-
-	return nil, nil
+	return ret, nil
 }
 
 type ECSMessageEntityInit struct {
@@ -27,13 +27,20 @@ import (
 )
 
 func parsePacketMPI(rpl *WRPL, pk *WRPLRawPacket) (pp *ParsedPacket, err error) {
-	r := bytes.NewReader(pk.PacketPayload)
+	r := newSpanReader(pk.PacketPayload)
 
 	signature := [4]byte{}
 	_, err = r.Read(signature[:])
 	if err != nil {
 		return nil, err
 	}
+	r.field("Signature")
+
+	defer func() {
+		if pp != nil && pp.Spans == nil {
+			pp.Spans = r.Spans
+		}
+	}()
 
 	switch {
 	case bytes.Equal(signature[:], []byte{0x00, 0x58, 0x22, 0xf0}): //    ^005822f0 zstd blobs (header 28b52ffd)
@@ -50,7 +57,7 @@ func parsePacketMPI(rpl *WRPL, pk *WRPLRawPacket) (pp *ParsedPacket, err error)
 		return parsePacketMPI_SlotMessage(rpl, pk, r)
 	// case bytes.Equal(signature[:], []byte{0x03, 0x58, 0x43, 0xf0}): // ^035843f0 model info (has turret angles)
 	case signature[0] == 0xff && signature[1] == 0x0f: //    ^ff0f movement
-		return parsePacketMPI_Movement(rpl, pk, r, signature)
+		return parsePacketMPI_Movement(rpl, pk, r.Reader, signature)
 	default:
 		return nil, nil
 	}
@@ -63,9 +70,11 @@ type ParsedPacketAward struct {
 	Player         byte
 	AwardName      string
 	Rem            string
+	// PlayerName is resolved by the roster
+	PlayerName string
 }
 
-func parsePacketMPI_Award(pk *WRPLRawPacket, r *bytes.Reader) (ret *ParsedPacket, err error) {
+func parsePacketMPI_Award(pk *WRPLRawPacket, r *spanReader) (ret *ParsedPacket, err error) {
 	parsed := ParsedPacketAward{}
 	ret = &ParsedPacket{
 		Name: "award",
@@ -78,26 +87,32 @@ func parsePacketMPI_Award(pk *WRPLRawPacket, r *bytes.Reader) (ret *ParsedPacket
 	if err != nil {
 		return
 	}
-	parsed.Always0x003E, err = ReadToHexStr(r, 2)
+	r.field("AwardType")
+	parsed.Always0x003E, err = ReadToHexStr(r.Reader, 2)
 	if err != nil {
 		return
 	}
+	r.field("Always0x003E")
 	parsed.Player, err = r.ReadByte()
 	if err != nil {
 		return
 	}
-	parsed.Always0x000000, err = ReadToHexStr(r, 3)
+	r.field("Player")
+	parsed.Always0x000000, err = ReadToHexStr(r.Reader, 3)
 	if err != nil {
 		return
 	}
-	parsed.AwardName, err = PacketReadLenString(r)
+	r.field("Always0x000000")
+	parsed.AwardName, err = PacketReadLenString(r.Reader)
 	if err != nil {
 		return
 	}
-	parsed.Rem, err = readToHexStrFull(r)
+	r.field("AwardName")
+	parsed.Rem, err = readToHexStrFull(r.Reader)
 	if err != nil {
 		return
 	}
+	r.field("Rem")
 
 	return
 }
@@ -110,9 +125,11 @@ type ParsedPacketKill struct {
 	Always0x000000 string `reflectViewHidden:"true"`
 	KillerVehicle  string
 	Rem            string
+	// Killer is resolved by the roster
+	Killer string
 }
 
-func parsePacketMPI_Kill(pk *WRPLRawPacket, r *bytes.Reader) (ret *ParsedPacket, err error) {
+func parsePacketMPI_Kill(pk *WRPLRawPacket, r *spanReader) (ret *ParsedPacket, err error) {
 	parsed := ParsedPacketKill{}
 	ret = &ParsedPacket{
 		Name: "kill",
@@ -125,27 +142,33 @@ func parsePacketMPI_Kill(pk *WRPLRawPacket, r *bytes.Reader) (ret *ParsedPacket,
 	if err != nil {
 		return
 	}
+	r.field("Control")
 	parsed.DamageType = parsed.Control & 0xF0
-	parsed.Always0x00FE3F, err = ReadToHexStr(r, 3)
+	parsed.Always0x00FE3F, err = ReadToHexStr(r.Reader, 3)
 	if err != nil {
 		return
 	}
+	r.field("Always0x00FE3F")
 	parsed.KillerID, err = r.ReadByte()
 	if err != nil {
 		return
 	}
-	parsed.Always0x000000, err = ReadToHexStr(r, 3)
+	r.field("KillerID")
+	parsed.Always0x000000, err = ReadToHexStr(r.Reader, 3)
 	if err != nil {
 		return
 	}
-	parsed.KillerVehicle, err = PacketReadLenString(r)
+	r.field("Always0x000000")
+	parsed.KillerVehicle, err = PacketReadLenString(r.Reader)
 	if err != nil {
 		return
 	}
-	parsed.Rem, err = readToHexStrFull(r)
+	r.field("KillerVehicle")
+	parsed.Rem, err = readToHexStrFull(r.Reader)
 	if err != nil {
 		return
 	}
+	r.field("Rem")
 	return
 }
 
@@ -156,7 +179,10 @@ type ParsedPacketCompressedBlobs struct {
 	Blob       string
 }
 
-func parsePacketMPI_CompressedBlobs(pk *WRPLRawPacket, r *bytes.Reader) (ret *ParsedPacket, err error) {
+// maxCompressedBlobSize bounds decompressed blob of one packet, it is hex dumped
+const maxCompressedBlobSize = 1 << 20
+
+func parsePacketMPI_CompressedBlobs(pk *WRPLRawPacket, r *spanReader) (ret *ParsedPacket, err error) {
 	parsed := ParsedPacketCompressedBlobs{}
 	ret = &ParsedPacket{
 		Name: "compressed",
@@ -165,11 +191,12 @@ func parsePacketMPI_CompressedBlobs(pk *WRPLRawPacket, r *bytes.Reader) (ret *Pa
 	defer func() {
 		ret.Data = parsed
 	}()
-	parsed.Unk0, err = ReadToHexStr(r, 2)
+	parsed.Unk0, err = ReadToHexStr(r.Reader, 2)
 	if err != nil {
 		return
 	}
-	parsed.Always0x01, err = ReadToHexStr(r, 1)
+	r.field("Unk0")
+	parsed.Always0x01, err = ReadToHexStr(r.Reader, 1)
 	if err != nil {
 		return
 	}
@@ -185,18 +212,23 @@ func parsePacketMPI_CompressedBlobs(pk *WRPLRawPacket, r *bytes.Reader) (ret *Pa
 	} else {
 		parsed.Always0x01 += "01"
 	}
-	parsed.Unk1, err = ReadToHexStr(r, 4)
+	r.field("Always0x01")
+	parsed.Unk1, err = ReadToHexStr(r.Reader, 4)
 	if err != nil {
 		return
 	}
-	dc, err := zstd.NewReader(r) // 28b52ffd
+	r.field("Unk1")
+	dc, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxMemory(maxCompressedBlobSize)) // 28b52ffd
 	if err != nil {
 		return
 	}
-	blob, err := io.ReadAll(dc)
+	defer dc.Close()
+	blob, err := readAllLimited(dc, maxCompressedBlobSize)
 	if err != nil {
 		return
 	}
 	parsed.Blob = hex.Dump(blob)
+	r.Seek(0, io.SeekEnd)
+	r.field("Blob")
 	return
 }
@@ -9,6 +9,8 @@ import (
 
 type ParsedPacketMovement struct {
 	EntityPosition
+	// Player is resolved by the roster
+	Player string
 }
 
 func parsePacketMPI_Movement(rpl *WRPL, pk *WRPLRawPacket, r *bytes.Reader, signature [4]byte) (ret *ParsedPacket, err error) {
@@ -29,7 +31,8 @@ func parsePacketMPI_Movement(rpl *WRPL, pk *WRPLRawPacket, r *bytes.Reader, sign
 		Name: "movement",
 		Data: nil,
 	}
-	parsed.EntityPosition.Eid, err = danet.NewBitReader(pk.PacketPayload[2:]).ReadCompressed()
+	eidReader := danet.NewBitReader(pk.PacketPayload[2:])
+	parsed.EntityPosition.Eid, err = eidReader.ReadCompressed()
 	if err != nil {
 		return ret, err
 	}
@@ -38,5 +41,12 @@ func parsePacketMPI_Movement(rpl *WRPL, pk *WRPLRawPacket, r *bytes.Reader, sign
 	binary.Decode(pk.PacketPayload[30:], binary.LittleEndian, &parsed.EntityPosition.Z)
 	parsed.EntityPosition.Time = pk.CurrentTime
 	ret.Data = parsed
+	ret.Spans = []FieldSpan{
+		{Name: "Signature", BitStart: 0, BitEnd: 2 * 8},
+		{Name: "Eid", BitStart: 2 * 8, BitEnd: 2*8 + eidReader.BitOffset},
+		{Name: "X", BitStart: 14 * 8, BitEnd: 22 * 8},
+		{Name: "Y", BitStart: 22 * 8, BitEnd: 30 * 8},
+		{Name: "Z", BitStart: 30 * 8, BitEnd: 38 * 8},
+	}
 	return ret, nil
 }
@@ -21,6 +21,7 @@ package wrpl
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"io"
 	"strings"
 
@@ -30,6 +31,8 @@ import (
 type SlotPrefixedMessage struct {
 	Slot    byte
 	Message []byte
+	// Player is resolved by the roster
+	Player string
 }
 
 type ParsedPacketSlotMessage struct {
@@ -41,7 +44,10 @@ type ParsedPacketSlotMessage struct {
 	Messages       []SlotPrefixedMessage
 }
 
-func parsePacketMPI_SlotMessage(rpl *WRPL, pk *WRPLRawPacket, r *bytes.Reader) (ret *ParsedPacket, err error) {
+// maxSlotMessagesSize bounds decompressed slot messages of one packet
+const maxSlotMessagesSize = 4 << 20
+
+func parsePacketMPI_SlotMessage(rpl *WRPL, pk *WRPLRawPacket, r *spanReader) (ret *ParsedPacket, err error) {
 	parsed := ParsedPacketSlotMessage{}
 	ret = &ParsedPacket{
 		Name: "slotMessage",
@@ -54,37 +60,50 @@ func parsePacketMPI_SlotMessage(rpl *WRPL, pk *WRPLRawPacket, r *bytes.Reader) (
 	if err != nil {
 		return
 	}
+	r.field("DataCompressed")
 	var r2 *bytes.Reader
 	if parsed.DataCompressed > 0 {
-		parsed.Unk0, err = ReadToHexStr(r, 1)
+		parsed.Unk0, err = ReadToHexStr(r.Reader, 1)
 		if err != nil {
 			return
 		}
+		r.field("Unk0")
 		parsed.Control, err = r.ReadByte()
 		if err != nil {
 			return
 		}
-		parsed.Unk1, err = ReadToHexStr(r, 2)
+		r.field("Control")
+		parsed.Unk1, err = ReadToHexStr(r.Reader, 2)
 		if err != nil {
 			return
 		}
+		r.field("Unk1")
 		if parsed.Control&0xF0 > 0 {
-			parsed.Unk2, err = ReadToHexStr(r, 1) // perhaps this 0x04 is blk type 4, slim zstd
+			parsed.Unk2, err = ReadToHexStr(r.Reader, 1) // perhaps this 0x04 is blk type 4, slim zstd
 			if err != nil {
 				return
 			}
+			r.field("Unk2")
 		}
-		dc, err2 := zstd.NewReader(r) // 28b52ffd
+		defer func() {
+			r.Seek(0, io.SeekEnd)
+			r.field("Messages")
+		}()
+		dc, err2 := zstd.NewReader(r, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxMemory(maxSlotMessagesSize)) // 28b52ffd
 		if err2 != nil {
+			err = err2
 			return
 		}
-		b, err2 := io.ReadAll(dc)
+		defer dc.Close()
+		b, err2 := readAllLimited(dc, maxSlotMessagesSize)
 		if err2 != nil {
+			err = err2
 			return
 		}
 		r2 = bytes.NewReader(b)
 	} else {
-		r2 = r
+		r2 = r.Reader
+		defer r.field("Messages")
 	}
 	messageCount := uint16(0)
 	err = binary.Read(r2, binary.LittleEndian, &messageCount)
@@ -97,12 +116,17 @@ func parsePacketMPI_SlotMessage(rpl *WRPL, pk *WRPLRawPacket, r *bytes.Reader) (
 		if err != nil {
 			return
 		}
+		if messageLen == 0 {
+			err = errors.New("slot message of zero length")
+			return
+		}
 		messageSlot, err2 := r2.ReadByte()
 		if err2 != nil {
+			err = err2
 			return
 		}
 		messageBuf := make([]byte, messageLen-1)
-		_, err = r2.Read(messageBuf)
+		_, err = io.ReadFull(r2, messageBuf)
 		if err != nil {
 			return
 		}
@@ -116,66 +140,84 @@ func parsePacketMPI_SlotMessage(rpl *WRPL, pk *WRPLRawPacket, r *bytes.Reader) (
 }
 
 func parseSlotMessage(rpl *WRPL, slot byte, msg []byte) {
-	if len(msg) < 5 {
-		return
+	if u := slotMessagePlayer(msg); u != nil {
+		rpl.Parsed.Players[slot] = u
 	}
-	r := bytes.NewReader(msg)
-	header := make([]byte, 5)
-	_, err := r.Read(header)
-	if err != nil {
-		return
+}
+
+// slotMessagePlayer returns player of player init message, nil for other messages
+func slotMessagePlayer(msg []byte) *Player {
+	if !isSlotMessagePlayerInit(msg) {
+		return nil
 	}
-	if header[0] != 0x70 || header[4] != 0x60 {
-		return
+	return parseSlotMessage_PlayerInit(bytes.NewReader(msg[slotMessageHeaderSize:]))
+}
+
+const slotMessageHeaderSize = 5
+
+// Layout of player init slot message after user id, for building samples
+const (
+	// SlotPlayerNameSize is zero padded name field
+	SlotPlayerNameSize = 64
+	// SlotPlayerSkipSize is unknown bytes between name and clan tag
+	SlotPlayerSkipSize = 20
+)
+
+func isSlotMessagePlayerInit(msg []byte) bool {
+	if len(msg) < slotMessageHeaderSize {
+		return false
 	}
-	if header[3] != 0x08 && header[3] != 0x30 {
-		return
+	if msg[0] != 0x70 || msg[4] != 0x60 {
+		return false
 	}
-	switch header[2] {
-	case 0x01:
-		parseSlotMessage_PlayerInit(rpl, slot, r)
-	case 0x02:
-		parseSlotMessage_PlayerInit(rpl, slot, r)
+	if msg[3] != 0x08 && msg[3] != 0x30 {
+		return false
 	}
+	return msg[2] == 0x01 || msg[2] == 0x02
 }
 
-func parseSlotMessage_PlayerInit(rpl *WRPL, slot byte, r *bytes.Reader) {
+// slotPlayerName decodes fixed size name field of player init
+func slotPlayerName(b []byte) string {
+	return strings.ToValidUTF8(strings.Trim(string(b), "\x00"), "?")
+}
+
+func parseSlotMessage_PlayerInit(r *bytes.Reader) *Player {
 	u := &Player{}
 	err := binary.Read(r, binary.LittleEndian, &u.UserID)
 	if err != nil {
-		return
+		return nil
 	}
 	var unk0 uint32
 	err = binary.Read(r, binary.LittleEndian, &unk0)
 	if err != nil {
-		return
+		return nil
 	}
 	if unk0 != 0 {
-		return
+		return nil
 	}
-	uName := make([]byte, 64)
+	uName := make([]byte, SlotPlayerNameSize)
 	_, err = r.Read(uName)
 	if err != nil {
-		return
+		return nil
 	}
-	u.Name = strings.ToValidUTF8(strings.Trim(string(uName), "\x00"), "?")
-	_, err = r.Seek(20, io.SeekCurrent)
+	u.Name = slotPlayerName(uName)
+	_, err = r.Seek(SlotPlayerSkipSize, io.SeekCurrent)
 	if err != nil {
-		return
+		return nil
 	}
 	clanTag, err := PacketReadLenString(r)
 	if err != nil {
-		return
+		return nil
 	}
 	if len(clanTag) > 0 {
 		u.ClanTag = clanTag
 	}
 	title, err := PacketReadLenString(r)
 	if err != nil {
-		return
+		return nil
 	}
 	if len(title) > 0 {
 		u.Title = title
 	}
-	rpl.Parsed.Players[slot] = u
+	return u
 }
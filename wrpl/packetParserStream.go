@@ -0,0 +1,148 @@
+/*
+	wrpl: War Thunder replay parsing library (golang)
+	Copyright (C) 2025 flexcoral
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published
+	by the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package wrpl
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/hex"
+	"errors"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+type ParsedPacketStartMarker struct {
+	Rem string
+}
+
+type ParsedPacketNextSegment struct {
+	Rem string
+}
+
+type ParsedPacketReplayHeaderInfo struct {
+	Info     map[string]any
+	BlkError string
+	Rem      string
+}
+
+type ParsedPacketSnapshot struct {
+	Prefix           string
+	Compression      string
+	CompressedSize   int
+	DecompressedSize int
+	DecompressError  string
+	Blob             []byte `reflectViewHidden:"true"`
+}
+
+var (
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+func parsePacketStartMarker(pk *WRPLRawPacket) (*ParsedPacket, error) {
+	return &ParsedPacket{
+		Name: "start marker",
+		Data: ParsedPacketStartMarker{
+			Rem: hex.EncodeToString(pk.PacketPayload),
+		},
+	}, nil
+}
+
+func parsePacketNextSegment(pk *WRPLRawPacket) (*ParsedPacket, error) {
+	return &ParsedPacket{
+		Name: "next segment",
+		Data: ParsedPacketNextSegment{
+			Rem: hex.EncodeToString(pk.PacketPayload),
+		},
+	}, nil
+}
+
+func parsePacketReplayHeaderInfo(pk *WRPLRawPacket) (*ParsedPacket, error) {
+	parsed := ParsedPacketReplayHeaderInfo{}
+	ret := &ParsedPacket{
+		Name: "replay header info",
+	}
+	var err error
+	parsed.Info, err = ParseBlk(pk.PacketPayload)
+	if err != nil {
+		parsed.BlkError = err.Error()
+		parsed.Rem = hex.EncodeToString(pk.PacketPayload)
+	}
+	ret.Data = parsed
+	return ret, nil
+}
+
+// maxSnapshotSize bounds decompressed snapshot of one packet
+const maxSnapshotSize = 32 << 20
+
+// parsePacketSnapshot looks for zstd frame in the first bytes of the payload
+// (like other compressed blobs in the stream), falls back to zlib and raw.
+// Contents of the snapshot are not decoded yet, decompressed bytes are
+// kept as Blob for the byte views.
+func parsePacketSnapshot(pk *WRPLRawPacket) (ret *ParsedPacket, err error) {
+	parsed := ParsedPacketSnapshot{}
+	ret = &ParsedPacket{
+		Name: "snapshot",
+	}
+	defer func() {
+		ret.Data = parsed
+	}()
+	payload := pk.PacketPayload
+	zstdAt := bytes.Index(payload[:min(len(payload), 16)], zstdMagic)
+	switch {
+	case zstdAt >= 0:
+		parsed.Prefix = hex.EncodeToString(payload[:zstdAt])
+		parsed.Compression = "zstd"
+		parsed.CompressedSize = len(payload) - zstdAt
+		dc, err := zstd.NewReader(bytes.NewReader(payload[zstdAt:]), zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxMemory(maxSnapshotSize))
+		if err != nil {
+			return ret, err
+		}
+		defer dc.Close()
+		parsed.Blob, err = readAllLimited(dc, maxSnapshotSize)
+		if errors.Is(err, ErrDecompressionLimit) {
+			parsed.DecompressError = err.Error()
+			return ret, err
+		} else if err != nil {
+			parsed.DecompressError = err.Error()
+		}
+	case len(payload) > 2 && payload[0] == 0x78:
+		parsed.Compression = "zlib"
+		parsed.CompressedSize = len(payload)
+		dc, err := zlib.NewReader(bytes.NewReader(payload))
+		if err != nil {
+			parsed.DecompressError = err.Error()
+			parsed.Blob = payload
+			break
+		}
+		defer dc.Close()
+		parsed.Blob, err = readAllLimited(dc, maxSnapshotSize)
+		if errors.Is(err, ErrDecompressionLimit) {
+			parsed.DecompressError = err.Error()
+			return ret, err
+		} else if err != nil {
+			parsed.DecompressError = err.Error()
+		}
+	default:
+		parsed.Compression = "none"
+		parsed.CompressedSize = len(payload)
+		parsed.Blob = payload
+	}
+	parsed.DecompressedSize = len(parsed.Blob)
+	return ret, nil
+}
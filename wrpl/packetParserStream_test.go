@@ -0,0 +1,75 @@
+/*
+	wrpl: War Thunder replay parsing library (golang)
+	Copyright (C) 2025 flexcoral
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published
+	by the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package wrpl
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func zstdBlob(t testing.TB, b []byte) []byte {
+	enc, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer enc.Close()
+	return enc.EncodeAll(b, nil)
+}
+
+func zlibBlob(t testing.TB, b []byte) []byte {
+	buf := &bytes.Buffer{}
+	zw, err := zlib.NewWriterLevel(buf, zlib.BestCompression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw.Write(b)
+	zw.Close()
+	return buf.Bytes()
+}
+
+func TestSnapshotDecompression(t *testing.T) {
+	small := []byte("snapshot contents")
+	bomb := make([]byte, maxSnapshotSize+1)
+	for _, tc := range []struct {
+		name    string
+		payload []byte
+		want    []byte
+		err     error
+	}{
+		{"zstd", append([]byte{0x01, 0x02}, zstdBlob(t, small)...), small, nil},
+		{"zlib", zlibBlob(t, small), small, nil},
+		{"zstd bomb", zstdBlob(t, bomb), nil, ErrDecompressionLimit},
+		{"zlib bomb", zlibBlob(t, bomb), nil, ErrDecompressionLimit},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			pp, err := parsePacketSnapshot(&WRPLRawPacket{PacketType: byte(PacketTypeSnapshot), PacketPayload: tc.payload})
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("want error %v, got %v", tc.err, err)
+			}
+			s := pp.Data.(ParsedPacketSnapshot)
+			if !bytes.Equal(s.Blob, tc.want) {
+				t.Fatalf("want blob %q, got %d bytes", tc.want, len(s.Blob))
+			}
+		})
+	}
+}
@@ -33,6 +33,8 @@ type WRPLRawPacket struct {
 	PacketPayload []byte
 	Parsed        *ParsedPacket
 	ParseError    error
+	// Encoding is only recorded by ReadWRPLExact
+	Encoding *PacketEncoding `json:"-"`
 }
 
 func (pk *WRPLRawPacket) Time() time.Duration {
@@ -40,19 +42,39 @@ func (pk *WRPLRawPacket) Time() time.Duration {
 }
 
 func ReadPacketStream(rpl *WRPL, r io.Reader) (ret []*WRPLRawPacket, err error) {
+	return readPacketStream(r, nil)
+}
+
+// readPacketStream records encoding of every packet and stream tail to
+// enc if it is not nil
+func readPacketStream(r io.Reader, enc *ReplayEncoding) (ret []*WRPLRawPacket, err error) {
 	ret = []*WRPLRawPacket{}
 	currentTime := uint32(0)
+	raw := &bytes.Buffer{}
+	if enc != nil {
+		r = io.TeeReader(r, raw)
+	}
+	skipped := []byte{}
 	for {
+		raw.Reset()
 		packetSize, err := readVariableLengthSize(r)
 		if err != nil {
 			if errors.Is(err, io.EOF) {
+				if enc != nil {
+					enc.StreamTail = skipped
+				}
 				break
 			}
 			return ret, fmt.Errorf("reading packet size: %w", err)
 		}
+		prefix := bytes.Clone(raw.Bytes())
 		if packetSize == 0 {
+			skipped = append(skipped, prefix...)
 			continue
 		}
+		if packetSize > MaxPacketSize {
+			return ret, fmt.Errorf("packet size %d is over the limit of %d", packetSize, MaxPacketSize)
+		}
 		packetBytes := make([]byte, packetSize)
 		_, err = io.ReadFull(r, packetBytes)
 		if err != nil {
@@ -62,6 +84,9 @@ func ReadPacketStream(rpl *WRPL, r io.Reader) (ret []*WRPLRawPacket, err error)
 		firstByte := packetBytes[0]
 		var packetType byte
 		var packetPayload []byte
+		if firstByte&0b00010000 != 0 && len(packetBytes) < 2 || firstByte&0b00010000 == 0 && len(packetBytes) < 6 {
+			return ret, fmt.Errorf("packet of size %d is too short for its header (type byte 0x%02x)", packetSize, firstByte)
+		}
 		if firstByte&0b00010000 != 0 {
 			packetType = firstByte ^ 0b00010000
 			packetPayload = packetBytes[2:]
@@ -74,6 +99,13 @@ func ReadPacketStream(rpl *WRPL, r io.Reader) (ret []*WRPLRawPacket, err error)
 			packetPayload = packetBytes[6:]
 		}
 		if packetType == 0 {
+			if enc != nil {
+				_, err = io.Copy(io.Discard, r)
+				if err != nil {
+					return ret, fmt.Errorf("reading stream tail: %w", err)
+				}
+				enc.StreamTail = append(skipped, raw.Bytes()...)
+			}
 			break
 		}
 		pk := &WRPLRawPacket{
@@ -81,6 +113,17 @@ func ReadPacketStream(rpl *WRPL, r io.Reader) (ret []*WRPLRawPacket, err error)
 			PacketType:    packetType,
 			PacketPayload: packetPayload,
 		}
+		if enc != nil {
+			pk.Encoding = &PacketEncoding{
+				Skipped:       skipped,
+				OmitTimestamp: firstByte&0b00010000 != 0,
+				HeaderByte:    packetBytes[1],
+			}
+			if !bytes.Equal(prefix, appendVariableLengthSize(nil, packetSize)) {
+				pk.Encoding.SizePrefix = prefix
+			}
+			skipped = []byte{}
+		}
 		ret = append(ret, pk)
 	}
 	return
@@ -88,48 +131,73 @@ func ReadPacketStream(rpl *WRPL, r io.Reader) (ret []*WRPLRawPacket, err error)
 
 func ParsePacketStream(rpl *WRPL) {
 	rpl.Parsed = &ParsedInfo{
-		Players: make([]*Player, 0xFF),
+		Players: make([]*Player, 0x100),
 		ECS: &ECS{
 			TemplateDefs:  map[ECSTemplateID]*ECSTemplate{},
 			ComponentDefs: map[ECSComponentID]*ECSComponent{},
+			Entities:      map[uint64]ECSTemplateID{},
 		},
 	}
 	for _, pk := range rpl.Packets {
-		pk.Parsed, pk.ParseError = ParsePacket(rpl, pk)
+		pk.Parsed, pk.ParseError = parsePacketRecover(rpl, pk)
 	}
+	rpl.Parsed.Roster = NewRoster(rpl)
+	resolveParsedNames(rpl)
+}
+
+// parsePacketRecover keeps one broken parser from taking the whole replay down
+func parsePacketRecover(rpl *WRPL, pk *WRPLRawPacket) (pp *ParsedPacket, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			pp = nil
+			err = fmt.Errorf("%w: %v", ErrParserPanic, r)
+		}
+	}()
+	return ParsePacket(rpl, pk)
 }
 
+// WritePackets encodes packets in minimal form, packets with recorded
+// Encoding are written the same way they were read
 func WritePackets(w io.Writer, packets []*WRPLRawPacket) error {
-	currentTime := int64(-1)
+	buf := []byte{}
+	currentTime := uint32(0)
+	hasTime := false
 	for _, p := range packets {
-		packetType := byte(p.PacketType)
-		packetSize := uint32(len(p.PacketPayload)) + 1
-		addTimestamp := currentTime != int64(p.CurrentTime)
-		if addTimestamp {
-			packetSize += 4
-		} else {
-			packetType |= 0b00010000
-		}
-		var err error
-		err = writeVariableLengthSize(w, packetSize)
+		buf = appendPacket(buf[:0], p, currentTime, hasTime)
+		_, err := w.Write(buf)
 		if err != nil {
 			return err
 		}
-		_, err = w.Write([]byte{packetType})
-		if err != nil {
-			return err
-		}
-		if addTimestamp {
-			err = binary.Write(w, binary.LittleEndian, p.CurrentTime)
-			if err != nil {
-				return err
-			}
-		}
-		_, err = w.Write(p.PacketPayload)
-		if err != nil {
-			return err
-		}
-		currentTime = int64(p.CurrentTime)
+		currentTime = p.CurrentTime
+		hasTime = true
 	}
 	return nil
 }
+
+func appendPacket(buf []byte, p *WRPLRawPacket, currentTime uint32, hasTime bool) []byte {
+	packetType := p.PacketType
+	headerByte := byte(0)
+	omitTimestamp := hasTime && currentTime == p.CurrentTime
+	if p.Encoding != nil {
+		buf = append(buf, p.Encoding.Skipped...)
+		headerByte = p.Encoding.HeaderByte
+		// reader starts with zero time, so first packet can omit it too
+		omitTimestamp = p.Encoding.OmitTimestamp && currentTime == p.CurrentTime
+	}
+	packetSize := uint32(len(p.PacketPayload)) + 2
+	if omitTimestamp {
+		packetType |= 0b00010000
+	} else {
+		packetSize += 4
+	}
+	if p.Encoding != nil && p.Encoding.SizePrefix != nil && prefixEncodesSize(p.Encoding.SizePrefix, packetSize) {
+		buf = append(buf, p.Encoding.SizePrefix...)
+	} else {
+		buf = appendVariableLengthSize(buf, packetSize)
+	}
+	buf = append(buf, packetType, headerByte)
+	if !omitTimestamp {
+		buf = binary.LittleEndian.AppendUint32(buf, p.CurrentTime)
+	}
+	return append(buf, p.PacketPayload...)
+}
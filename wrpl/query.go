@@ -0,0 +1,502 @@
+/*
+	wrpl: War Thunder replay parsing library (golang)
+	Copyright (C) 2025 flexcoral
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published
+	by the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package wrpl
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Query is a compiled packet filter expression:
+//
+//	type=mpi and name=kill and KillerVehicle ~ "tiger" and time between 5m and 10m
+//
+// Comparisons are FIELD OP VALUE with operators = != ~ !~ < <= > >= and
+// FIELD between A and B, combined with and, or, not and parentheses.
+// ~ is case-insensitive regex match, = on text is case-insensitive.
+//
+// Packet fields: type (number or name like mpi, ecs, chat), time (ms or
+// duration like 1m30s), len, name (parsed packet name), payload (hex),
+// error (parse error text), parsed (true/false).
+// Other fields are looked up in parsed packet data by name (case-insensitive,
+// nested fields separated with dots), packets without them do not match.
+type Query struct {
+	src  string
+	root queryNode
+}
+
+var (
+	ErrQuerySyntax = errors.New("query syntax error")
+
+	queryPacketTypeNames = map[string]PacketType{
+		"endmarker":        PacketTypeEndMarker,
+		"startmarker":      PacketTypeStartMarker,
+		"aircraftsmall":    PacketTypeAircraftSmall,
+		"chat":             PacketTypeChat,
+		"mpi":              PacketTypeMPI,
+		"nextsegment":      PacketTypeNextSegment,
+		"ecs":              PacketTypeECS,
+		"snapshot":         PacketTypeSnapshot,
+		"replayheaderinfo": PacketTypeReplayHeaderInfo,
+	}
+)
+
+// ParseQuery compiles query expression, empty query matches everything
+func ParseQuery(s string) (*Query, error) {
+	toks, err := lexQuery(s)
+	if err != nil {
+		return nil, err
+	}
+	q := &Query{src: s}
+	if len(toks) == 0 {
+		q.root = queryAll{}
+		return q, nil
+	}
+	p := &queryParser{toks: toks, end: len([]rune(s))}
+	q.root, err = p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.toks) {
+		return nil, queryErrorf(p.toks[p.pos].pos, "unexpected %q", p.toks[p.pos].text)
+	}
+	return q, nil
+}
+
+func (q *Query) String() string {
+	return q.src
+}
+
+func (q *Query) Match(pk *WRPLRawPacket) bool {
+	return q.root.match(pk)
+}
+
+// Filter returns packets matching the query
+func Filter(packets []*WRPLRawPacket, query string) ([]*WRPLRawPacket, error) {
+	q, err := ParseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	ret := []*WRPLRawPacket{}
+	for _, pk := range packets {
+		if pk != nil && q.Match(pk) {
+			ret = append(ret, pk)
+		}
+	}
+	return ret, nil
+}
+
+type queryToken struct {
+	text   string
+	quoted bool
+	pos    int
+}
+
+// queryErrorf reports syntax error at rune offset pos, shown as 1-based column
+func queryErrorf(pos int, format string, args ...any) error {
+	return fmt.Errorf("%w at column %d: %s", ErrQuerySyntax, pos+1, fmt.Sprintf(format, args...))
+}
+
+func lexQuery(s string) ([]queryToken, error) {
+	ret := []queryToken{}
+	rs := []rune(s)
+	for i := 0; i < len(rs); {
+		c := rs[i]
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '(' || c == ')':
+			ret = append(ret, queryToken{text: string(c), pos: i})
+			i++
+		case c == '"':
+			b := strings.Builder{}
+			start := i
+			i++
+			for ; i < len(rs) && rs[i] != '"'; i++ {
+				if rs[i] == '\\' && i+1 < len(rs) {
+					i++
+				}
+				b.WriteRune(rs[i])
+			}
+			if i >= len(rs) {
+				return nil, queryErrorf(start, "unterminated string")
+			}
+			i++
+			ret = append(ret, queryToken{text: b.String(), quoted: true, pos: start})
+		case strings.ContainsRune("=!~<>", c):
+			j := i + 1
+			if j < len(rs) && (rs[j] == '=' || rs[j] == '~') {
+				j++
+			}
+			ret = append(ret, queryToken{text: string(rs[i:j]), pos: i})
+			i = j
+		default:
+			j := i
+			for j < len(rs) && !unicode.IsSpace(rs[j]) && !strings.ContainsRune("()\"=!~<>", rs[j]) {
+				j++
+			}
+			ret = append(ret, queryToken{text: string(rs[i:j]), pos: i})
+			i = j
+		}
+	}
+	return ret, nil
+}
+
+type queryParser struct {
+	toks []queryToken
+	pos  int
+	end  int
+}
+
+// here is offset of the current token or end of query
+func (p *queryParser) here() int {
+	if p.pos < len(p.toks) {
+		return p.toks[p.pos].pos
+	}
+	return p.end
+}
+
+func (p *queryParser) peekWord(w string) bool {
+	return p.pos < len(p.toks) && !p.toks[p.pos].quoted && strings.EqualFold(p.toks[p.pos].text, w)
+}
+
+func (p *queryParser) next() (queryToken, error) {
+	if p.pos >= len(p.toks) {
+		return queryToken{}, queryErrorf(p.end, "unexpected end of query")
+	}
+	p.pos++
+	return p.toks[p.pos-1], nil
+}
+
+func (p *queryParser) parseOr() (queryNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekWord("or") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = queryOr{left, right}
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseAnd() (queryNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekWord("and") {
+		p.pos++
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = queryAnd{left, right}
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseUnary() (queryNode, error) {
+	if p.peekWord("not") {
+		p.pos++
+		n, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return queryNot{n}, nil
+	}
+	if p.peekWord("(") {
+		p.pos++
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.peekWord(")") {
+			return nil, queryErrorf(p.here(), "expected )")
+		}
+		p.pos++
+		return n, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *queryParser) parseComparison() (queryNode, error) {
+	field, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if field.quoted || field.text == "(" || field.text == ")" {
+		return nil, queryErrorf(field.pos, "expected field name, got %q", field.text)
+	}
+	name := strings.ToLower(field.text)
+	if p.peekWord("between") {
+		p.pos++
+		lo, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		if !p.peekWord("and") {
+			return nil, queryErrorf(p.here(), "expected and in between")
+		}
+		p.pos++
+		hi, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		lov, err := parseQueryNumber(name, lo)
+		if err != nil {
+			return nil, err
+		}
+		hiv, err := parseQueryNumber(name, hi)
+		if err != nil {
+			return nil, err
+		}
+		return queryCompare{field: name, op: "between", num: lov, num2: hiv}, nil
+	}
+	opTok, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	op := opTok.text
+	switch op {
+	case "=", "==", "!=", "~", "!~", "<", "<=", ">", ">=":
+	default:
+		return nil, queryErrorf(opTok.pos, "unknown operator %q", op)
+	}
+	val, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	c := queryCompare{field: name, op: op, text: val.text}
+	switch op {
+	case "~", "!~":
+		c.re, err = regexp.Compile("(?i)" + val.text)
+		if err != nil {
+			return nil, queryErrorf(val.pos, "%v", err)
+		}
+	case "<", "<=", ">", ">=":
+		c.num, err = parseQueryNumber(name, val)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		c.num, err = parseQueryNumber(name, val)
+		c.isNum = err == nil
+	}
+	return c, nil
+}
+
+// parseQueryNumber accepts numbers, durations for time and packet type names for type
+func parseQueryNumber(field string, t queryToken) (float64, error) {
+	if field == "type" {
+		if pt, ok := queryPacketTypeNames[strings.ToLower(t.text)]; ok {
+			return float64(pt), nil
+		}
+	}
+	if v, err := strconv.ParseFloat(t.text, 64); err == nil {
+		return v, nil
+	}
+	if v, err := strconv.ParseInt(t.text, 0, 64); err == nil {
+		return float64(v), nil
+	}
+	if d, err := time.ParseDuration(t.text); err == nil {
+		if field == "time" {
+			return float64(d.Milliseconds()), nil
+		}
+		return d.Seconds(), nil
+	}
+	return 0, queryErrorf(t.pos, "%q is not a number", t.text)
+}
+
+type queryNode interface {
+	match(pk *WRPLRawPacket) bool
+}
+
+type queryAll struct{}
+
+func (queryAll) match(*WRPLRawPacket) bool { return true }
+
+type queryAnd struct{ a, b queryNode }
+
+func (q queryAnd) match(pk *WRPLRawPacket) bool { return q.a.match(pk) && q.b.match(pk) }
+
+type queryOr struct{ a, b queryNode }
+
+func (q queryOr) match(pk *WRPLRawPacket) bool { return q.a.match(pk) || q.b.match(pk) }
+
+type queryNot struct{ a queryNode }
+
+func (q queryNot) match(pk *WRPLRawPacket) bool { return !q.a.match(pk) }
+
+type queryCompare struct {
+	field string
+	op    string
+	text  string
+	num   float64
+	num2  float64
+	isNum bool
+	re    *regexp.Regexp
+}
+
+type queryValue struct {
+	text  string
+	num   float64
+	isNum bool
+}
+
+func (q queryCompare) match(pk *WRPLRawPacket) bool {
+	v, ok := queryField(pk, q.field)
+	if !ok {
+		return false
+	}
+	switch q.op {
+	case "=", "==":
+		return q.equal(v)
+	case "!=":
+		return !q.equal(v)
+	case "~":
+		return q.re.MatchString(v.text)
+	case "!~":
+		return !q.re.MatchString(v.text)
+	}
+	if !v.isNum {
+		return false
+	}
+	switch q.op {
+	case "<":
+		return v.num < q.num
+	case "<=":
+		return v.num <= q.num
+	case ">":
+		return v.num > q.num
+	case ">=":
+		return v.num >= q.num
+	case "between":
+		return v.num >= q.num && v.num <= q.num2
+	}
+	return false
+}
+
+func (q queryCompare) equal(v queryValue) bool {
+	if q.isNum && v.isNum {
+		return v.num == q.num
+	}
+	return strings.EqualFold(v.text, q.text)
+}
+
+func queryField(pk *WRPLRawPacket, field string) (queryValue, bool) {
+	switch field {
+	case "type":
+		return queryValue{text: strconv.Itoa(int(pk.PacketType)), num: float64(pk.PacketType), isNum: true}, true
+	case "time":
+		return queryValue{text: pk.Time().String(), num: float64(pk.CurrentTime), isNum: true}, true
+	case "len":
+		return queryValue{text: strconv.Itoa(len(pk.PacketPayload)), num: float64(len(pk.PacketPayload)), isNum: true}, true
+	case "payload":
+		return queryValue{text: hex.EncodeToString(pk.PacketPayload)}, true
+	case "error":
+		if pk.ParseError == nil {
+			return queryValue{}, true
+		}
+		return queryValue{text: pk.ParseError.Error()}, true
+	case "parsed":
+		if pk.Parsed != nil {
+			return queryValue{text: "true", num: 1, isNum: true}, true
+		}
+		return queryValue{text: "false", num: 0, isNum: true}, true
+	}
+	if pk.Parsed == nil {
+		return queryValue{}, false
+	}
+	if field == "name" {
+		return queryValue{text: pk.Parsed.Name}, true
+	}
+	v := reflect.ValueOf(pk.Parsed.Data)
+	for _, part := range strings.Split(field, ".") {
+		for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+			if v.IsNil() {
+				return queryValue{}, false
+			}
+			v = v.Elem()
+		}
+		if v.Kind() != reflect.Struct {
+			return queryValue{}, false
+		}
+		v = v.FieldByNameFunc(func(n string) bool {
+			return strings.EqualFold(n, part)
+		})
+		if !v.IsValid() {
+			return queryValue{}, false
+		}
+	}
+	return queryReflectValue(v)
+}
+
+func queryReflectValue(v reflect.Value) (queryValue, bool) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return queryValue{}, false
+		}
+		v = v.Elem()
+	}
+	if !v.CanInterface() {
+		return queryValue{}, false
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return queryValue{text: v.String()}, true
+	case reflect.Bool:
+		if v.Bool() {
+			return queryValue{text: "true", num: 1, isNum: true}, true
+		}
+		return queryValue{text: "false", num: 0, isNum: true}, true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return queryValue{text: queryStringer(v, strconv.FormatInt(v.Int(), 10)), num: float64(v.Int()), isNum: true}, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return queryValue{text: queryStringer(v, strconv.FormatUint(v.Uint(), 10)), num: float64(v.Uint()), isNum: true}, true
+	case reflect.Float32, reflect.Float64:
+		return queryValue{text: strconv.FormatFloat(v.Float(), 'g', -1, 64), num: v.Float(), isNum: true}, true
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(b), v)
+			return queryValue{text: hex.EncodeToString(b)}, true
+		}
+	}
+	return queryValue{text: fmt.Sprint(v.Interface())}, true
+}
+
+// queryStringer prefers String() of enum-like types so they can be compared by name
+func queryStringer(v reflect.Value, def string) string {
+	if s, ok := v.Interface().(fmt.Stringer); ok {
+		return s.String()
+	}
+	return def
+}
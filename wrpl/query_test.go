@@ -0,0 +1,152 @@
+/*
+	wrpl: War Thunder replay parsing library (golang)
+	Copyright (C) 2025 flexcoral
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published
+	by the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package wrpl
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLexQuery(t *testing.T) {
+	for _, tc := range []struct {
+		src  string
+		want []queryToken
+	}{
+		{"", []queryToken{}},
+		{"len>=10", []queryToken{{text: "len", pos: 0}, {text: ">=", pos: 3}, {text: "10", pos: 5}}},
+		{`name ~ "a \"b\""`, []queryToken{{text: "name", pos: 0}, {text: "~", pos: 5}, {text: `a "b"`, quoted: true, pos: 7}}},
+		{"not(a!~b)", []queryToken{{text: "not", pos: 0}, {text: "(", pos: 3}, {text: "a", pos: 4}, {text: "!~", pos: 5}, {text: "b", pos: 7}, {text: ")", pos: 8}}},
+		{"ä = 1", []queryToken{{text: "ä", pos: 0}, {text: "=", pos: 2}, {text: "1", pos: 4}}},
+	} {
+		got, err := lexQuery(tc.src)
+		if err != nil {
+			t.Errorf("lexQuery(%q): %v", tc.src, err)
+			continue
+		}
+		if len(got) != len(tc.want) {
+			t.Errorf("lexQuery(%q) = %+v, want %+v", tc.src, got, tc.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Errorf("lexQuery(%q) token %d = %+v, want %+v", tc.src, i, got[i], tc.want[i])
+			}
+		}
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	for _, tc := range []struct {
+		src  string
+		want string
+	}{
+		{`name = "abc`, "at column 8: unterminated string"},
+		{"len >", "at column 6: unexpected end of query"},
+		{"len > 1 )", `at column 9: unexpected ")"`},
+		{"(len > 1", "at column 9: expected )"},
+		{"(len > 1 len", "at column 10: expected )"},
+		{`"len" > 1`, `at column 1: expected field name, got "len"`},
+		{"len between 1 or 2", "at column 15: expected and in between"},
+		{"len between x and 2", `at column 13: "x" is not a number`},
+		{"len ! 1", `at column 5: unknown operator "!"`},
+		{"len < abc", `at column 7: "abc" is not a number`},
+		{"name ~ (", "at column 8: error parsing regexp"},
+		{"name ~ a(", `at column 9: unexpected "("`},
+		{`name ~ "a("`, "at column 8: error parsing regexp"},
+		{"type = mpi and", "at column 15: unexpected end of query"},
+	} {
+		_, err := ParseQuery(tc.src)
+		if err == nil {
+			t.Errorf("ParseQuery(%q) succeeded", tc.src)
+			continue
+		}
+		if !errors.Is(err, ErrQuerySyntax) || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("ParseQuery(%q) = %v, want %q", tc.src, err, tc.want)
+		}
+	}
+}
+
+func TestQueryMatch(t *testing.T) {
+	kill := &WRPLRawPacket{
+		CurrentTime:   90500,
+		PacketType:    byte(PacketTypeMPI),
+		PacketPayload: []byte{0x02, 0x58, 0x78, 0xf0},
+		Parsed:        &ParsedPacket{Name: "kill", Data: ParsedPacketKill{KillerID: 0x1f, KillerVehicle: "germ_pzkpfw_vi_ausf_h1_tiger"}},
+	}
+	chat := &WRPLRawPacket{
+		CurrentTime:   20,
+		PacketType:    byte(PacketTypeChat),
+		PacketPayload: []byte{0x01},
+		ParseError:    errors.New("truncated"),
+	}
+	for _, tc := range []struct {
+		src        string
+		kill, chat bool
+	}{
+		{"", true, true},
+		// type names, numbers and hex literals
+		{"type = mpi", true, false},
+		{"type = MPI", true, false},
+		{"type = chat", false, true},
+		{"type = 3", false, true},
+		{"type == 0x3", false, true},
+		{"type != ecs", true, true},
+		{"len = 4", true, false},
+		{"len < 0x2", false, true},
+		{"killerid = 0x1f", true, false},
+		{"killerid = 31", true, false},
+		{"killerid >= 3.1e1", true, false},
+		// durations are milliseconds for time
+		{"time between 1m30s and 91s", true, false},
+		{"time < 1s", false, true},
+		{"time = 20", false, true},
+		// text fields
+		{`name = KILL`, true, false},
+		{`killervehicle ~ "tiger$"`, true, false},
+		{`KillerVehicle !~ tiger`, false, false},
+		{"payload ~ ^025878", true, false},
+		{"error ~ trunc", false, true},
+		{"error = \"\"", true, false},
+		{"parsed = true", true, false},
+		{"parsed = 0", false, true},
+		{"missing = 1", false, false},
+		{"not missing = 1", true, true},
+		// and binds tighter than or, not tighter than and
+		{"type = chat or type = mpi and len = 1", false, true},
+		{"(type = chat or type = mpi) and len = 1", false, true},
+		{"type = mpi or type = chat and len = 4", true, false},
+		{"(type = mpi or type = chat) and len = 4", true, false},
+		{"not type = mpi and len = 1", false, true},
+		{"not (type = mpi and len = 1)", true, true},
+		{"not not parsed = true", true, false},
+	} {
+		q, err := ParseQuery(tc.src)
+		if err != nil {
+			t.Errorf("ParseQuery(%q): %v", tc.src, err)
+			continue
+		}
+		if got := q.Match(kill); got != tc.kill {
+			t.Errorf("%q on kill = %v, want %v", tc.src, got, tc.kill)
+		}
+		if got := q.Match(chat); got != tc.chat {
+			t.Errorf("%q on chat = %v, want %v", tc.src, got, tc.chat)
+		}
+	}
+}
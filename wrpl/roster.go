@@ -0,0 +1,401 @@
+/*
+	wrpl: War Thunder replay parsing library (golang)
+	Copyright (C) 2025 flexcoral
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published
+	by the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package wrpl
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Participant is one player of the battle with every reference to them
+// found in the replay
+type Participant struct {
+	Player
+	// ID is index in Roster.Participants
+	ID int
+	// Slots player was assigned to, in order of assignment
+	Slots []byte
+	// Eids of entities that carry player name or user id
+	Eids []uint64
+	// Team and Squad are taken from results, 0 if unknown
+	Team  int
+	Squad int
+	// Vehicles in order of first appearance
+	Vehicles []string
+}
+
+func (p *Participant) String() string {
+	switch {
+	case p == nil:
+		return ""
+	case p.Name != "" && p.ClanTag != "":
+		return p.ClanTag + " " + p.Name
+	case p.Name != "":
+		return p.Name
+	case p.UserID != 0:
+		return "user " + strconv.FormatUint(uint64(p.UserID), 10)
+	case len(p.Slots) > 0:
+		return "slot " + strconv.Itoa(int(p.Slots[0]))
+	default:
+		return "participant " + strconv.Itoa(p.ID)
+	}
+}
+
+func (p *Participant) addVehicle(v string) {
+	if v != "" && !slices.Contains(p.Vehicles, v) {
+		p.Vehicles = append(p.Vehicles, v)
+	}
+}
+
+type rosterSlotAssignment struct {
+	time uint32
+	p    *Participant
+}
+
+// Roster resolves slot, eid, user id and name references to participants
+type Roster struct {
+	Participants []*Participant
+	slots        map[byte][]rosterSlotAssignment
+	eids         map[uint64]*Participant
+	userIDs      map[uint32]*Participant
+	names        map[string]*Participant
+}
+
+func newRoster() *Roster {
+	return &Roster{
+		slots:   map[byte][]rosterSlotAssignment{},
+		eids:    map[uint64]*Participant{},
+		userIDs: map[uint32]*Participant{},
+		names:   map[string]*Participant{},
+	}
+}
+
+// BySlot returns participant that was in the slot at given time, slot
+// assigned later is used for references that come before the assignment
+func (r *Roster) BySlot(slot byte, time uint32) *Participant {
+	as := r.slots[slot]
+	if len(as) == 0 {
+		return nil
+	}
+	i := sort.Search(len(as), func(i int) bool { return as[i].time > time })
+	return as[max(i-1, 0)].p
+}
+
+func (r *Roster) ByEid(eid uint64) *Participant {
+	return r.eids[eid]
+}
+
+func (r *Roster) ByUserID(id uint32) *Participant {
+	return r.userIDs[id]
+}
+
+// ByName accepts name alone or with clan tag
+func (r *Roster) ByName(name string) *Participant {
+	return r.names[name]
+}
+
+// participant finds participant by user id then by name, creating new one
+func (r *Roster) participant(u Player) *Participant {
+	p := r.userIDs[u.UserID]
+	if p == nil && u.Name != "" {
+		p = r.names[u.Name]
+		if p != nil && p.UserID != 0 && u.UserID != 0 && p.UserID != u.UserID {
+			// same name with different id is a different player
+			p = nil
+		}
+	}
+	if p == nil {
+		p = &Participant{ID: len(r.Participants)}
+		r.Participants = append(r.Participants, p)
+	}
+	if p.UserID == 0 && u.UserID != 0 {
+		p.UserID = u.UserID
+	}
+	if p.UserID != 0 {
+		r.userIDs[p.UserID] = p
+	}
+	if p.Name == "" {
+		p.Name = u.Name
+	}
+	if p.ClanTag == "" {
+		p.ClanTag = u.ClanTag
+	}
+	if p.Title == "" {
+		p.Title = u.Title
+	}
+	if p.Name != "" {
+		r.names[p.Name] = p
+		if p.ClanTag != "" {
+			r.names[p.ClanTag+" "+p.Name] = p
+		}
+	}
+	return p
+}
+
+func (r *Roster) assignSlot(slot byte, time uint32, p *Participant) {
+	as := r.slots[slot]
+	if len(as) > 0 && as[len(as)-1].p == p {
+		return
+	}
+	r.slots[slot] = append(as, rosterSlotAssignment{time: time, p: p})
+	if !slices.Contains(p.Slots, slot) {
+		p.Slots = append(p.Slots, slot)
+	}
+}
+
+func (r *Roster) linkEid(eid uint64, p *Participant) {
+	if _, ok := r.eids[eid]; ok {
+		return
+	}
+	r.eids[eid] = p
+	p.Eids = append(p.Eids, eid)
+}
+
+// NewRoster collects participants from player init slot messages, results
+// BLK (records with user id key, their name, team, squad and vehicle keys),
+// kills (killer vehicle) and ECS entities that have component value equal
+// to player name or user id. Packets have to be parsed.
+func NewRoster(rpl *WRPL) *Roster {
+	r := newRoster()
+	for _, pk := range rpl.Packets {
+		if pk.Parsed == nil {
+			continue
+		}
+		sm, ok := pk.Parsed.Data.(ParsedPacketSlotMessage)
+		if !ok {
+			continue
+		}
+		for _, m := range sm.Messages {
+			if u := slotMessagePlayer(m.Message); u != nil {
+				r.assignSlot(m.Slot, pk.CurrentTime, r.participant(*u))
+			}
+		}
+	}
+	if rpl.Results != nil {
+		r.addResults(rpl.Results)
+	}
+	for _, pk := range rpl.Packets {
+		if pk.Parsed == nil {
+			continue
+		}
+		switch d := pk.Parsed.Data.(type) {
+		case ParsedPacketKill:
+			if p := r.BySlot(d.KillerID, pk.CurrentTime); p != nil {
+				p.addVehicle(d.KillerVehicle)
+			}
+		case ParsedPacketECS:
+			for _, m := range d.Messages {
+				r.linkValues(m.EID, m.Values)
+			}
+			for _, m := range d.Replications {
+				r.linkValues(m.EID, m.Values)
+			}
+		}
+	}
+	return r
+}
+
+// addResults walks results BLK looking for player records, keys are
+// visited in sorted order so that participant ids do not change between runs
+func (r *Roster) addResults(v any) {
+	switch v := v.(type) {
+	case []any:
+		for _, e := range v {
+			r.addResults(e)
+		}
+	case map[string]any:
+		keys := slices.Sorted(maps.Keys(v))
+		var id uint32
+		for _, k := range keys {
+			if isUserIDKey(k) {
+				if n, ok := rosterNumber(v[k]); ok && n > 0 && n <= 0xffffffff {
+					id = uint32(n)
+				}
+			}
+		}
+		if id != 0 {
+			u := Player{UserID: id}
+			u.Name, _ = v["name"].(string)
+			if u.Name == "" {
+				u.Name, _ = v["nick"].(string)
+			}
+			u.ClanTag, _ = v["clanTag"].(string)
+			p := r.participant(u)
+			for _, k := range keys {
+				f := v[k]
+				lk := strings.ToLower(k)
+				n, isNum := rosterNumber(f)
+				switch {
+				case lk == "team" && isNum:
+					p.Team = int(n)
+				case (lk == "squad" || lk == "squadid") && isNum:
+					p.Squad = int(n)
+				case strings.Contains(lk, "vehicle") || strings.Contains(lk, "unit"):
+					for _, s := range rosterStrings(f) {
+						p.addVehicle(s)
+					}
+				}
+			}
+		}
+		for _, k := range keys {
+			r.addResults(v[k])
+		}
+	}
+}
+
+func (r *Roster) linkValues(eid uint64, values map[uint32]any) {
+	for _, v := range values {
+		if s, ok := v.(string); ok {
+			if p := r.names[s]; p != nil {
+				r.linkEid(eid, p)
+			}
+		} else if n, ok := rosterNumber(v); ok && n > 0 && n <= 0xffffffff {
+			if p := r.userIDs[uint32(n)]; p != nil {
+				r.linkEid(eid, p)
+			}
+		}
+	}
+}
+
+func rosterNumber(v any) (int64, bool) {
+	switch v := v.(type) {
+	case int64:
+		return v, true
+	case int32:
+		return int64(v), true
+	case uint32:
+		return int64(v), true
+	case uint64:
+		return int64(v), v <= 1<<63-1
+	case string:
+		n, err := strconv.ParseInt(v, 10, 64)
+		return n, err == nil
+	default:
+		return 0, false
+	}
+}
+
+func rosterStrings(v any) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		ret := []string{}
+		for _, e := range v {
+			ret = append(ret, rosterStrings(e)...)
+		}
+		return ret
+	case map[string]any:
+		// blocks of vehicles are keyed by vehicle name
+		ret := []string{}
+		for k := range v {
+			ret = append(ret, k)
+		}
+		slices.Sort(ret)
+		return ret
+	default:
+		return nil
+	}
+}
+
+// resolveParsedNames fills resolved player names of parsed packets
+func resolveParsedNames(rpl *WRPL) {
+	r := rpl.Parsed.Roster
+	for _, pk := range rpl.Packets {
+		if pk.Parsed == nil {
+			continue
+		}
+		switch d := pk.Parsed.Data.(type) {
+		case ParsedPacketKill:
+			d.Killer = r.BySlot(d.KillerID, pk.CurrentTime).String()
+			pk.Parsed.Data = d
+		case ParsedPacketAward:
+			d.PlayerName = r.BySlot(d.Player, pk.CurrentTime).String()
+			pk.Parsed.Data = d
+		case ParsedPacketMovement:
+			d.Player = r.ByEid(d.Eid).String()
+			pk.Parsed.Data = d
+		case ParsedPacketChat:
+			d.Player = chatPlayer(rpl, r.ByName(d.Sender), pk.CurrentTime)
+			pk.Parsed.Data = d
+		case ParsedPacketSlotMessage:
+			for i := range d.Messages {
+				d.Messages[i].Player = r.BySlot(d.Messages[i].Slot, pk.CurrentTime).String()
+			}
+		case ParsedPacketECS:
+			for _, m := range d.Messages {
+				m.Player = r.ByEid(m.EID).String()
+			}
+			for _, m := range d.Replications {
+				m.Player = r.ByEid(m.EID).String()
+			}
+			for _, m := range d.EntityMessages {
+				m.Player = r.ByEid(m.EID).String()
+			}
+			for _, m := range d.Destructions {
+				m.Player = r.ByEid(m.EID).String()
+			}
+		}
+	}
+	for _, c := range rpl.Parsed.Chat {
+		c.Player = chatPlayer(rpl, r.ByName(c.Sender), c.CurrentTime)
+	}
+}
+
+// chatPlayer is entry of Parsed.Players in the slot participant had when
+// the message was sent, participants seen only in results have no slot
+func chatPlayer(rpl *WRPL, p *Participant, time uint32) *Player {
+	if p == nil {
+		return nil
+	}
+	for _, slot := range p.Slots {
+		if u := rpl.Parsed.Players[slot]; u != nil && u.Name == p.Name && rpl.Parsed.Roster.BySlot(slot, time) == p {
+			return u
+		}
+	}
+	return &p.Player
+}
+
+// Describe lists references of the participant
+func (p *Participant) Describe() string {
+	parts := []string{p.String()}
+	if p.UserID != 0 {
+		parts = append(parts, fmt.Sprintf("user id %d", p.UserID))
+	}
+	if len(p.Slots) > 0 {
+		parts = append(parts, fmt.Sprintf("slots %v", p.Slots))
+	}
+	if len(p.Eids) > 0 {
+		parts = append(parts, fmt.Sprintf("eids %v", p.Eids))
+	}
+	if p.Team != 0 {
+		parts = append(parts, fmt.Sprintf("team %d", p.Team))
+	}
+	if p.Squad != 0 {
+		parts = append(parts, fmt.Sprintf("squad %d", p.Squad))
+	}
+	if len(p.Vehicles) > 0 {
+		parts = append(parts, "vehicles "+strings.Join(p.Vehicles, ", "))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,100 @@
+/*
+	wrpl: War Thunder replay parsing library (golang)
+	Copyright (C) 2025 flexcoral
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published
+	by the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package wrpl
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestRoster(t *testing.T) {
+	move := []byte{0xff, 0x0f, 0x01, 0x00, 0x00, 0xa3, 0xf0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x14}
+	move = append(move, make([]byte, 28)...)
+	rpl := newSampleBuilder().
+		player(0, 1, 123456, "RealName", "[TAG]", "Legend").
+		player(10, 2, 654321, "OtherGuy", "", "").
+		chat(20, "RealName", "gg OtherGuy", ChatChannelTeam, false).
+		kill(30, 1, "germ_tiger").
+		award(40, 2, "first_blood").
+		packet(50, PacketTypeMPI, move).
+		read(t)
+	r := rpl.Parsed.Roster
+
+	if len(r.Participants) != 2 {
+		t.Fatalf("expected 2 participants, got %d", len(r.Participants))
+	}
+	p := r.ByUserID(123456)
+	if p == nil || r.BySlot(1, 0) != p || r.ByName("RealName") != p || r.ByName("[TAG] RealName") != p {
+		t.Fatalf("references of the first player do not resolve to one participant: %+v", p)
+	}
+	if !slices.Equal(p.Vehicles, []string{"germ_tiger"}) {
+		t.Errorf("vehicles from kills: %v", p.Vehicles)
+	}
+	if d := rpl.Packets[3].Parsed.Data.(ParsedPacketKill); d.Killer != "[TAG] RealName" {
+		t.Errorf("kill: %+v", d)
+	}
+	if d := rpl.Packets[4].Parsed.Data.(ParsedPacketAward); d.PlayerName != "OtherGuy" {
+		t.Errorf("award: %+v", d)
+	}
+	if d := rpl.Packets[1].Parsed.Data.(ParsedPacketSlotMessage); d.Messages[0].Player != "OtherGuy" {
+		t.Errorf("slot message: %+v", d.Messages[0])
+	}
+	if c := rpl.Parsed.Chat[0]; c.Player != rpl.Parsed.Players[1] {
+		t.Errorf("chat sender: %+v", c.Player)
+	}
+
+	// eids are linked by component values, results add team, squad and vehicles
+	r.linkValues(1, map[uint32]any{0x1234: "OtherGuy"})
+	r.addResults(map[string]any{"players": []any{
+		map[string]any{"userId": "654321", "team": int64(2), "squadId": int64(3), "vehicles": map[string]any{"us_m4": map[string]any{}}},
+		map[string]any{"userId": int64(777), "name": "Late", "team": int64(1)},
+	}})
+	resolveParsedNames(rpl)
+	other := r.ByName("OtherGuy")
+	if r.ByEid(1) != other || other.Team != 2 || other.Squad != 3 || !slices.Equal(other.Vehicles, []string{"us_m4"}) {
+		t.Errorf("second player: %s", other.Describe())
+	}
+	if d := rpl.Packets[5].Parsed.Data.(ParsedPacketMovement); d.Player != "OtherGuy" {
+		t.Errorf("movement: %+v", d)
+	}
+	if late := r.ByUserID(777); late == nil || late.Name != "Late" || len(r.Participants) != 3 {
+		t.Errorf("player known only from results: %+v", late)
+	}
+}
+
+func TestRosterResultsOrder(t *testing.T) {
+	results := map[string]any{}
+	for i, name := range []string{"Echo", "Delta", "Charlie", "Bravo", "Alpha"} {
+		results[name] = map[string]any{"userId": int64(i + 1), "name": name, "vehicles": map[string]any{"v2": nil, "v1": nil}}
+	}
+	for range 20 {
+		r := newRoster()
+		r.addResults(results)
+		names := []string{}
+		for i, p := range r.Participants {
+			if p.ID != i || !slices.Equal(p.Vehicles, []string{"v1", "v2"}) {
+				t.Fatalf("participant %d: %s", i, p.Describe())
+			}
+			names = append(names, p.Name)
+		}
+		if !slices.Equal(names, []string{"Alpha", "Bravo", "Charlie", "Delta", "Echo"}) {
+			t.Fatalf("participants are not in results key order: %v", names)
+		}
+	}
+}
@@ -0,0 +1,283 @@
+/*
+	wrpl: War Thunder replay parsing library (golang)
+	Copyright (C) 2025 flexcoral
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published
+	by the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package wrpl
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// PacketEncoding is how packet was stored in the packet stream, everything
+// that is lost by decoding and needed to write it back bit-identically
+type PacketEncoding struct {
+	// Skipped are raw zero size entries preceding the packet
+	Skipped []byte
+	// SizePrefix is the original size prefix when it is not minimal
+	SizePrefix []byte
+	// OmitTimestamp is set when packet reused time of the previous one
+	OmitTimestamp bool
+	// HeaderByte follows packet type, meaning is unknown
+	HeaderByte byte
+}
+
+// ReplayEncoding keeps parts of the file that can't be rebuilt from
+// decoded replay, recorded by ReadWRPLExact
+type ReplayEncoding struct {
+	// PacketsCompressed is zlib stream as stored in the file, reused
+	// by WriteWRPL as long as packets encode to the same stream
+	PacketsCompressed []byte
+	// StreamSum is sha256 of decompressed packet stream
+	StreamSum [sha256.Size]byte
+	// StreamTail is end marker and anything after it, plus trailing zero size entries
+	StreamTail []byte
+	// Level is zlib level guessed from the stream header
+	Level int
+}
+
+// ReadWRPLExact reads everything and records original encoding so that
+// WriteWRPL of unmodified replay gives back the same bytes.
+// Settings and results BLK that fail to parse are kept as raw blobs.
+func ReadWRPLExact(r io.ReadSeeker) (*WRPL, error) {
+	_, err := r.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	ret, _, err := readWRPLExact(b)
+	return ret, err
+}
+
+// wrplLayout is where parts of the replay are in the file
+type wrplLayout struct {
+	settingsStart, packetsStart, packetsEnd int
+}
+
+func readWRPLExact(b []byte) (*WRPL, wrplLayout, error) {
+	ret := &WRPL{Encoding: &ReplayEncoding{}}
+	lay := wrplLayout{settingsStart: binary.Size(ret.Header)}
+	err := binary.Read(bytes.NewReader(b), binary.LittleEndian, &ret.Header)
+	if err != nil {
+		return nil, lay, fmt.Errorf("parsing header: %w", err)
+	}
+	if !bytes.Equal(ret.Header.Magic[:], []byte{0xe5, 0xac, 0x00, 0x10}) {
+		return nil, lay, fmt.Errorf("wrong magic (got %v)", ret.Header.Magic)
+	}
+	lay.packetsStart = lay.settingsStart + int(ret.Header.SettingsBLKSize)
+	if lay.packetsStart > len(b) {
+		return nil, lay, fmt.Errorf("settings blk of size %d is truncated", ret.Header.SettingsBLKSize)
+	}
+	if ret.Header.SettingsBLKSize > 0 {
+		ret.SettingsBLK = b[lay.settingsStart:lay.packetsStart]
+		ret.Settings, err = ParseBlk(ret.SettingsBLK)
+		if err == nil {
+			settingsReadableBytes, _ := json.MarshalIndent(ret.Settings, "", "\t")
+			ret.SettingsJSON = string(settingsReadableBytes)
+		}
+	}
+	lay.packetsEnd = len(b)
+	if ret.Header.ResultsBlkOffset > 0 {
+		if int(ret.Header.ResultsBlkOffset) < lay.packetsStart || int(ret.Header.ResultsBlkOffset) > len(b) {
+			return nil, lay, fmt.Errorf("results blk offset %d is out of the file", ret.Header.ResultsBlkOffset)
+		}
+		lay.packetsEnd = int(ret.Header.ResultsBlkOffset)
+	}
+
+	enc := ret.Encoding
+	enc.PacketsCompressed = b[lay.packetsStart:lay.packetsEnd]
+	enc.Level = zlib.DefaultCompression
+	if len(enc.PacketsCompressed) > 1 {
+		enc.Level = []int{zlib.BestSpeed, 3, 6, zlib.BestCompression}[enc.PacketsCompressed[1]>>6]
+	}
+	packetsStream, err := zlib.NewReader(bytes.NewReader(enc.PacketsCompressed))
+	if err != nil {
+		return nil, lay, fmt.Errorf("opening zlib packets stream: %w", err)
+	}
+	defer packetsStream.Close()
+	sum := sha256.New()
+	ret.Packets, err = readPacketStream(io.TeeReader(packetsStream, sum), enc)
+	if err != nil {
+		return nil, lay, fmt.Errorf("reading packet stream: %w", err)
+	}
+	sum.Sum(enc.StreamSum[:0])
+	ParsePacketStream(ret)
+
+	if ret.Header.ResultsBlkOffset > 0 {
+		ret.ResultsBLK = b[lay.packetsEnd:]
+		ret.Results, err = ParseBlk(ret.ResultsBLK)
+		if err == nil {
+			resultsReadableBytes, _ := json.MarshalIndent(ret.Results, "", "\t")
+			ret.ResultsJSON = string(resultsReadableBytes)
+		}
+	}
+	return ret, lay, nil
+}
+
+// encodePacketStream returns decompressed packet stream and offset of
+// every packet in it
+func encodePacketStream(packets []*WRPLRawPacket, tail []byte) ([]byte, []int) {
+	ret := []byte{}
+	offsets := make([]int, 0, len(packets))
+	currentTime := uint32(0)
+	hasTime := false
+	for _, p := range packets {
+		offsets = append(offsets, len(ret))
+		ret = appendPacket(ret, p, currentTime, hasTime)
+		currentTime = p.CurrentTime
+		hasTime = true
+	}
+	return append(ret, tail...), offsets
+}
+
+// writePacketsExact reuses original compressed stream if packets were
+// not changed, otherwise compresses them with the original level
+func writePacketsExact(w io.Writer, rpl *WRPL) error {
+	stream, _ := encodePacketStream(rpl.Packets, rpl.Encoding.StreamTail)
+	if sha256.Sum256(stream) == rpl.Encoding.StreamSum {
+		_, err := w.Write(rpl.Encoding.PacketsCompressed)
+		return err
+	}
+	zw, err := zlib.NewWriterLevel(w, rpl.Encoding.Level)
+	if err != nil {
+		return err
+	}
+	_, err = zw.Write(stream)
+	if err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// RoundTripReport describes first difference between replay file and its rewrite
+type RoundTripReport struct {
+	Size          int
+	RewrittenSize int
+	// Offset of first divergent byte, -1 if rewrite is identical
+	Offset int
+	// Region of the file Offset is in: header, settings, packets or results
+	Region string
+	// Want and Got are bytes at Offset, -1 past the end of file
+	Want, Got int
+	// StreamOffset is first divergent byte of decompressed packet stream
+	// and Packet is index of packet containing it, both -1 if streams match
+	StreamOffset int
+	Packet       int
+}
+
+func (r *RoundTripReport) Identical() bool {
+	return r.Offset < 0
+}
+
+func (r *RoundTripReport) String() string {
+	if r.Identical() {
+		return fmt.Sprintf("identical (%d bytes)", r.Size)
+	}
+	ret := fmt.Sprintf("differs at byte %d (%s): want %s got %s, size %d rewritten %d",
+		r.Offset, r.Region, formatRoundTripByte(r.Want), formatRoundTripByte(r.Got), r.Size, r.RewrittenSize)
+	if r.StreamOffset >= 0 {
+		ret += fmt.Sprintf(", packet stream differs at byte %d (packet %d)", r.StreamOffset, r.Packet)
+	}
+	return ret
+}
+
+func formatRoundTripByte(b int) string {
+	if b < 0 {
+		return "eof"
+	}
+	return fmt.Sprintf("%02x", b)
+}
+
+// VerifyRoundTrip reads replay file with ReadWRPLExact, writes it back with
+// WriteWRPL and reports first divergent byte
+func VerifyRoundTrip(original []byte) (*RoundTripReport, error) {
+	rpl, lay, err := readWRPLExact(original)
+	if err != nil {
+		return nil, err
+	}
+	stream, offsets := encodePacketStream(rpl.Packets, rpl.Encoding.StreamTail)
+	rewritten, err := WriteWRPL(rpl)
+	if err != nil {
+		return nil, err
+	}
+	ret := &RoundTripReport{
+		Size:          len(original),
+		RewrittenSize: len(rewritten),
+		Offset:        firstDifference(original, rewritten),
+		StreamOffset:  -1,
+		Packet:        -1,
+	}
+	if ret.Offset >= 0 {
+		ret.Want, ret.Got = byteAt(original, ret.Offset), byteAt(rewritten, ret.Offset)
+		switch {
+		case ret.Offset < lay.settingsStart:
+			ret.Region = "header"
+		case ret.Offset < lay.packetsStart:
+			ret.Region = "settings"
+		case ret.Offset < lay.packetsEnd:
+			ret.Region = "packets"
+		default:
+			ret.Region = "results"
+		}
+	}
+	zr, err := zlib.NewReader(bytes.NewReader(rpl.Encoding.PacketsCompressed))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	originalStream, err := io.ReadAll(zr)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, err
+	}
+	ret.StreamOffset = firstDifference(originalStream, stream)
+	if ret.StreamOffset >= len(stream)-len(rpl.Encoding.StreamTail) {
+		// in the tail, past the last packet
+		ret.Packet = len(offsets)
+	} else if ret.StreamOffset >= 0 {
+		ret.Packet = sort.SearchInts(offsets, ret.StreamOffset+1) - 1
+	}
+	return ret, nil
+}
+
+func firstDifference(a, b []byte) int {
+	for i := range min(len(a), len(b)) {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	if len(a) != len(b) {
+		return min(len(a), len(b))
+	}
+	return -1
+}
+
+func byteAt(b []byte, i int) int {
+	if i < len(b) {
+		return int(b[i])
+	}
+	return -1
+}
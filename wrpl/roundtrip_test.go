@@ -0,0 +1,162 @@
+/*
+	wrpl: War Thunder replay parsing library (golang)
+	Copyright (C) 2025 flexcoral
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published
+	by the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package wrpl
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// roundTripSample builds replay file with every encoding choice that
+// minimal writer would not make on its own
+func roundTripSample(t *testing.T) []byte {
+	stream := []byte{
+		0x80,                                                       // zero size entry
+		0x40, 0x08, 0x14, 0x07, 0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff, // non-minimal size, no timestamp on first packet
+		0x88, 0x04, 0x00, 0x10, 0x00, 0x00, 0x00, 0x02, 0x58, // timestamp 16
+		0x80, 0x80, // zero size entries
+		0x84, 0x14, 0x01, 0x03, 0x04, // same time, timestamp omitted
+		0x86, 0x04, 0x00, 0x10, 0x00, 0x00, 0x00, // same time, timestamp written anyway
+		0x86, 0x00, 0x00, 0x10, 0x00, 0x00, 0x00, 0xde, 0xad, // end marker and garbage after it
+	}
+	zbuf := &bytes.Buffer{}
+	zw, err := zlib.NewWriterLevel(zbuf, zlib.BestCompression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw.Write(stream)
+	zw.Close()
+
+	h := WRPLHeader{}
+	copy(h.Magic[:], []byte{0xe5, 0xac, 0x00, 0x10})
+	copy(h.Raw_Level[:], "levels/avg_stalingrad.bin")
+	h.SettingsBLKSize = uint16(len(fuzzFatBlk))
+	h.ResultsBlkOffset = int32(binary.Size(h) + len(fuzzFatBlk) + zbuf.Len())
+	buf := &bytes.Buffer{}
+	binary.Write(buf, binary.LittleEndian, h)
+	buf.Write(fuzzFatBlk)
+	buf.Write(zbuf.Bytes())
+	buf.Write(fuzzFatBlk)
+	return buf.Bytes()
+}
+
+func TestRoundTripExact(t *testing.T) {
+	sample := roundTripSample(t)
+	report, err := VerifyRoundTrip(sample)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Identical() || report.StreamOffset >= 0 {
+		t.Fatal(report)
+	}
+
+	rpl, err := ReadWRPLExact(bytes.NewReader(sample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rpl.Packets) != 4 {
+		t.Fatalf("expected 4 packets, got %d", len(rpl.Packets))
+	}
+	if rpl.Packets[2].CurrentTime != 16 || !rpl.Packets[2].Encoding.OmitTimestamp {
+		t.Fatalf("third packet should reuse time 16: %+v %+v", rpl.Packets[2], rpl.Packets[2].Encoding)
+	}
+
+	// edited packet is written in recorded form and is read back as is
+	rpl.Packets[1].PacketPayload = []byte{0x02, 0x58, 0x78, 0xf0}
+	out, err := WriteWRPL(rpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(out, sample) {
+		t.Fatal("edited replay is identical to the original")
+	}
+	rpl2, err := ReadWRPLExact(bytes.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, pk := range rpl2.Packets {
+		want := rpl.Packets[i]
+		if pk.CurrentTime != want.CurrentTime || pk.PacketType != want.PacketType || !bytes.Equal(pk.PacketPayload, want.PacketPayload) {
+			t.Fatalf("packet %d: want %+v got %+v", i, want, pk)
+		}
+	}
+	if !bytes.Equal(rpl2.Encoding.StreamTail, rpl.Encoding.StreamTail) {
+		t.Fatalf("stream tail changed: %x", rpl2.Encoding.StreamTail)
+	}
+}
+
+func TestRoundTripMinimal(t *testing.T) {
+	rpl, err := ReadWRPL(bytes.NewReader(roundTripSample(t)), true, true, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := WriteWRPL(rpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rpl2, err := ReadWRPL(bytes.NewReader(out), true, true, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rpl2.Packets) != len(rpl.Packets) {
+		t.Fatalf("want %d packets, got %d", len(rpl.Packets), len(rpl2.Packets))
+	}
+	for i, pk := range rpl2.Packets {
+		want := rpl.Packets[i]
+		if pk.CurrentTime != want.CurrentTime || pk.PacketType != want.PacketType || !bytes.Equal(pk.PacketPayload, want.PacketPayload) {
+			t.Fatalf("packet %d: want %+v got %+v", i, want, pk)
+		}
+	}
+}
+
+func TestCorpusRoundTrip(t *testing.T) {
+	found := false
+	err := filepath.WalkDir(*corpusDir, func(p string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(p, ".wrpl") {
+			return err
+		}
+		found = true
+		t.Run(strings.TrimPrefix(p, *corpusDir), func(t *testing.T) {
+			b, err := os.ReadFile(p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			report, err := VerifyRoundTrip(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !report.Identical() {
+				t.Error(report)
+			}
+		})
+		return nil
+	})
+	if errors.Is(err, os.ErrNotExist) || err == nil && !found {
+		t.Skipf("no replays in corpus at %s (set with -corpus)", *corpusDir)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+}
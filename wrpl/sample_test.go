@@ -0,0 +1,122 @@
+/*
+	wrpl: War Thunder replay parsing library (golang)
+	Copyright (C) 2025 flexcoral
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published
+	by the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package wrpl
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// samplePlayerInit is slot message introducing player
+func samplePlayerInit(userID uint32, name, clanTag, title string) []byte {
+	msg := []byte{0x70, 0x00, 0x01, 0x08, 0x60}
+	msg = binary.LittleEndian.AppendUint32(msg, userID)
+	msg = binary.LittleEndian.AppendUint32(msg, 0)
+	nameField := make([]byte, SlotPlayerNameSize)
+	copy(nameField, name)
+	msg = append(msg, nameField...)
+	msg = append(msg, make([]byte, SlotPlayerSkipSize)...)
+	msg = appendLenString(msg, clanTag)
+	msg = appendLenString(msg, title)
+	return append(msg, 0xaa, 0xbb)
+}
+
+// sampleSlotMessages is body of slot messages packet with one message
+func sampleSlotMessages(slot byte, msg []byte) []byte {
+	body := binary.LittleEndian.AppendUint16(nil, 1)
+	body = binary.LittleEndian.AppendUint16(body, uint16(len(msg)+1))
+	body = append(append(body, slot), msg...)
+	return body
+}
+
+// sampleKill is kill MPI packet of player in slot driving vehicle
+func sampleKill(slot byte, vehicle string) []byte {
+	return append([]byte{0x02, 0x58, 0x58, 0xf0, 0x10, 0x00, 0xfe, 0x3f, slot, 0x00, 0x00, 0x00}, appendLenString(nil, vehicle)...)
+}
+
+// sampleAward is award MPI packet of player in slot
+func sampleAward(slot byte, award string) []byte {
+	return append([]byte{0x02, 0x58, 0x78, 0xf0, 0x01, 0x00, 0x3e, slot, 0x00, 0x00, 0x00}, appendLenString(nil, award)...)
+}
+
+// sampleChat is chat packet payload
+func sampleChat(sender, content string, channel ChatChannel, enemy bool) []byte {
+	b := appendLenString(nil, sender)
+	b = appendLenString(b, content)
+	b = append(b, byte(channel), 0)
+	if enemy {
+		b[len(b)-1] = 1
+	}
+	return b
+}
+
+// sampleBuilder assembles replay for tests packet by packet
+type sampleBuilder struct {
+	rpl *WRPL
+}
+
+func newSampleBuilder() *sampleBuilder {
+	rpl := &WRPL{SettingsBLK: fuzzFatBlk}
+	copy(rpl.Header.Magic[:], []byte{0xe5, 0xac, 0x00, 0x10})
+	return &sampleBuilder{rpl: rpl}
+}
+
+func (b *sampleBuilder) packet(time uint32, typ PacketType, payload []byte) *sampleBuilder {
+	b.rpl.Packets = append(b.rpl.Packets, &WRPLRawPacket{CurrentTime: time, PacketType: byte(typ), PacketPayload: payload})
+	return b
+}
+
+func (b *sampleBuilder) player(time uint32, slot byte, userID uint32, name, clanTag, title string) *sampleBuilder {
+	return b.packet(time, PacketTypeMPI, append([]byte{0x02, 0x58, 0x2d, 0xf0, 0x00}, sampleSlotMessages(slot, samplePlayerInit(userID, name, clanTag, title))...))
+}
+
+func (b *sampleBuilder) kill(time uint32, slot byte, vehicle string) *sampleBuilder {
+	return b.packet(time, PacketTypeMPI, sampleKill(slot, vehicle))
+}
+
+func (b *sampleBuilder) award(time uint32, slot byte, award string) *sampleBuilder {
+	return b.packet(time, PacketTypeMPI, sampleAward(slot, award))
+}
+
+func (b *sampleBuilder) chat(time uint32, sender, content string, channel ChatChannel, enemy bool) *sampleBuilder {
+	return b.packet(time, PacketTypeChat, sampleChat(sender, content, channel, enemy))
+}
+
+// end puts start marker that closes the last segment at time
+func (b *sampleBuilder) end(time uint32) *sampleBuilder {
+	return b.packet(time, PacketTypeStartMarker, nil)
+}
+
+func (b *sampleBuilder) bytes(t *testing.T) []byte {
+	ret, err := WriteWRPL(b.rpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ret
+}
+
+// read writes the replay and parses it back like a file
+func (b *sampleBuilder) read(t *testing.T) *WRPL {
+	rpl, err := ReadWRPL(bytes.NewReader(b.bytes(t)), true, true, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rpl
+}
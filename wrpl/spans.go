@@ -0,0 +1,79 @@
+/*
+	wrpl: War Thunder replay parsing library (golang)
+	Copyright (C) 2025 flexcoral
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published
+	by the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package wrpl
+
+import (
+	"bytes"
+
+	"github.com/maxsupermanhd/wrpl-inspector/danet"
+)
+
+// FieldSpan is location of parsed field in the packet payload, in bits
+type FieldSpan struct {
+	Name     string
+	BitStart int
+	BitEnd   int
+}
+
+// spanReader is bytes.Reader that records spans of the fields read from it,
+// parser calls field() after each field is read and span is recorded from
+// the end of the previous field
+type spanReader struct {
+	*bytes.Reader
+	Spans []FieldSpan
+	mark  int
+}
+
+func newSpanReader(b []byte) *spanReader {
+	return &spanReader{Reader: bytes.NewReader(b)}
+}
+
+func (r *spanReader) pos() int {
+	return int(r.Size()) - r.Len()
+}
+
+func (r *spanReader) field(name string) {
+	p := r.pos()
+	if p > r.mark {
+		r.Spans = append(r.Spans, FieldSpan{Name: name, BitStart: r.mark * 8, BitEnd: p * 8})
+	}
+	r.mark = p
+}
+
+// bitSpanRecorder does the same for danet.BitReader
+type bitSpanRecorder struct {
+	r     *danet.BitReader
+	Spans []FieldSpan
+	mark  int
+}
+
+func newBitSpanRecorder(r *danet.BitReader) *bitSpanRecorder {
+	return &bitSpanRecorder{r: r, mark: r.BitOffset}
+}
+
+func (s *bitSpanRecorder) field(name string) {
+	if s == nil || s.r == nil {
+		return
+	}
+	p := s.r.BitOffset
+	if p > s.mark {
+		s.Spans = append(s.Spans, FieldSpan{Name: name, BitStart: s.mark, BitEnd: p})
+	}
+	s.mark = p
+}
@@ -0,0 +1,546 @@
+/*
+	wrpl: War Thunder replay parsing library (golang)
+	Copyright (C) 2025 flexcoral
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published
+	by the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package wrpl
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BattleResult is outcome of the battle for the replay author
+type BattleResult byte
+
+const (
+	BattleResultUnknown BattleResult = iota
+	BattleResultWin
+	BattleResultLoss
+)
+
+func (r BattleResult) String() string {
+	switch r {
+	case BattleResultWin:
+		return "win"
+	case BattleResultLoss:
+		return "loss"
+	default:
+		return "unknown"
+	}
+}
+
+func (r BattleResult) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// battleResultValues are values of top level status or result key of
+// results BLK, anything else leaves result unknown
+var battleResultValues = map[string]BattleResult{
+	"success": BattleResultWin,
+	"win":     BattleResultWin,
+	"victory": BattleResultWin,
+	"fail":    BattleResultLoss,
+	"failed":  BattleResultLoss,
+	"loss":    BattleResultLoss,
+	"defeat":  BattleResultLoss,
+}
+
+// PlayerBattle is one participant of a summarized battle. Kills and deaths
+// come from kills and per-player kills/deaths in results when present,
+// otherwise from lineup, every life that ended destroyed or with respawn
+// counts as a death.
+type PlayerBattle struct {
+	Name   string
+	UserID uint32
+	Team   int
+	Kills  int
+	// Deaths are destroyed vehicle entities, or deaths from results when
+	// they are listed there. Respawn in other vehicle is not counted.
+	Deaths int
+	Lives  []*VehicleLife
+	// Vehicles are all vehicles of the player, seen or listed in results
+	Vehicles []string
+}
+
+// Key is how player is told apart across replays: name, or user id if
+// name is not known
+func (p *PlayerBattle) Key() string {
+	if p.Name != "" {
+		return p.Name
+	}
+	return "user" + strconv.FormatUint(uint64(p.UserID), 10)
+}
+
+// BattleSummary is what aggregated statistics need from one replay
+type BattleSummary struct {
+	Path       string
+	Session    string
+	Map        string
+	BattleType string
+	Start      time.Time
+	Duration   time.Duration
+	Result     BattleResult
+	// Author is the recording player if results name one
+	Author  *PlayerBattle
+	Players []*PlayerBattle
+	// KillerVehicles counts kill packets by killer vehicle
+	KillerVehicles map[string]int
+}
+
+// SummarizeBattle collects map, battle type, outcome and per-player
+// results of parsed replay
+func SummarizeBattle(p string, rpl *WRPL) *BattleSummary {
+	ret := &BattleSummary{
+		Path:           p,
+		Session:        rpl.Header.SessionHEX(),
+		Map:            strings.TrimSuffix(path.Base(rpl.Header.Level()), ".bin"),
+		BattleType:     rpl.Header.BattleType(),
+		Start:          time.Unix(int64(rpl.Header.StartTime), 0),
+		KillerVehicles: map[string]int{},
+	}
+	if len(rpl.Packets) > 0 {
+		ret.Duration = rpl.Packets[len(rpl.Packets)-1].Time()
+	}
+	for k, v := range rpl.Results {
+		lk := strings.ToLower(k)
+		if s, ok := v.(string); ok && (lk == "status" || lk == "result") {
+			ret.Result = battleResultValues[strings.ToLower(s)]
+		}
+	}
+	for _, pk := range rpl.Packets {
+		if pk.Parsed == nil {
+			continue
+		}
+		if k, ok := pk.Parsed.Data.(ParsedPacketKill); ok && k.KillerVehicle != "" {
+			ret.KillerVehicles[k.KillerVehicle]++
+		}
+	}
+	if rpl.Parsed == nil || rpl.Parsed.Roster == nil {
+		return ret
+	}
+	author := battleAuthor(rpl.Parsed.Roster, rpl.Results)
+	for _, l := range NewLineups(rpl) {
+		p := l.Participant
+		pb := &PlayerBattle{Name: p.Name, UserID: p.UserID, Team: p.Team, Lives: l.Lives}
+		for _, life := range l.Lives {
+			pb.Kills += life.Kills
+			if life.Ended == LifeEndDestroyed {
+				pb.Deaths++
+			}
+			if !slices.Contains(pb.Vehicles, life.Vehicle) {
+				pb.Vehicles = append(pb.Vehicles, life.Vehicle)
+			}
+		}
+		pb.Vehicles = append(pb.Vehicles, l.Unobserved...)
+		if p.UserID != 0 {
+			n := resultsPlayerNumbers(rpl.Results, p.UserID)
+			if v, ok := n["kills"]; ok {
+				pb.Kills = int(v)
+			}
+			if v, ok := n["deaths"]; ok {
+				pb.Deaths = int(v)
+			}
+		}
+		if p == author {
+			ret.Author = pb
+		}
+		ret.Players = append(ret.Players, pb)
+	}
+	return ret
+}
+
+// battleAuthor finds participant named by top level results key
+// mentioning author, by user id or by name
+func battleAuthor(r *Roster, results map[string]any) *Participant {
+	for k, v := range results {
+		if !strings.Contains(strings.ToLower(k), "author") {
+			continue
+		}
+		if n, ok := rosterNumber(v); ok && n > 0 && n <= 0xffffffff {
+			if p := r.ByUserID(uint32(n)); p != nil {
+				return p
+			}
+		}
+		if s, ok := v.(string); ok {
+			if p := r.ByName(s); p != nil {
+				return p
+			}
+		}
+	}
+	return nil
+}
+
+// resultsPlayerNumbers returns numeric fields (lowercased keys) of results
+// blocks belonging to given user id
+func resultsPlayerNumbers(v any, id uint32) map[string]int64 {
+	ret := map[string]int64{}
+	var walk func(v any)
+	walk = func(v any) {
+		switch v := v.(type) {
+		case []any:
+			for _, e := range v {
+				walk(e)
+			}
+		case map[string]any:
+			own := false
+			for k, f := range v {
+				if n, ok := rosterNumber(f); ok && isUserIDKey(k) && n == int64(id) {
+					own = true
+				}
+			}
+			for k, f := range v {
+				if n, ok := rosterNumber(f); own && ok && !isUserIDKey(k) {
+					ret[strings.ToLower(k)] = n
+				}
+				walk(f)
+			}
+		}
+	}
+	walk(v)
+	return ret
+}
+
+// StatsGroup is aggregate of battles sharing map, battle type, vehicle,
+// player or day. Wins and losses are only counted from battles with
+// known result, for vehicles and players only from battles they recorded.
+type StatsGroup struct {
+	Key      string
+	Battles  int
+	Wins     int
+	Losses   int
+	Kills    int
+	Deaths   int
+	Duration time.Duration
+}
+
+// WinRate is percent of wins among battles with known result
+func (g *StatsGroup) WinRate() float64 {
+	if g.Wins+g.Losses == 0 {
+		return 0
+	}
+	return float64(g.Wins) * 100 / float64(g.Wins+g.Losses)
+}
+
+// KD is kills per death, deathless players count as dying once
+func (g *StatsGroup) KD() float64 {
+	return float64(g.Kills) / float64(max(g.Deaths, 1))
+}
+
+func (g *StatsGroup) AvgDuration() time.Duration {
+	if g.Battles == 0 {
+		return 0
+	}
+	return g.Duration / time.Duration(g.Battles)
+}
+
+func (g *StatsGroup) add(b *BattleSummary, result BattleResult, kills, deaths int) {
+	g.Battles++
+	switch result {
+	case BattleResultWin:
+		g.Wins++
+	case BattleResultLoss:
+		g.Losses++
+	}
+	g.Kills += kills
+	g.Deaths += deaths
+	g.Duration += b.Duration
+}
+
+// Stats aggregates summaries of many battles
+type Stats struct {
+	StatsGroup
+	// Failed counts replays that could not be read
+	Failed int
+
+	maps           map[string]*StatsGroup
+	battleTypes    map[string]*StatsGroup
+	vehicles       map[string]*StatsGroup
+	players        map[string]*StatsGroup
+	days           map[string]map[string]*StatsGroup
+	killerVehicles map[string]int
+}
+
+func NewStats() *Stats {
+	return &Stats{
+		StatsGroup:     StatsGroup{Key: "all"},
+		maps:           map[string]*StatsGroup{},
+		battleTypes:    map[string]*StatsGroup{},
+		vehicles:       map[string]*StatsGroup{},
+		players:        map[string]*StatsGroup{},
+		days:           map[string]map[string]*StatsGroup{},
+		killerVehicles: map[string]int{},
+	}
+}
+
+func statsGroupOf(groups map[string]*StatsGroup, key string) *StatsGroup {
+	g, ok := groups[key]
+	if !ok {
+		g = &StatsGroup{Key: key}
+		groups[key] = g
+	}
+	return g
+}
+
+// Add counts battle in every group it belongs to
+func (s *Stats) Add(b *BattleSummary) {
+	s.StatsGroup.add(b, b.Result, 0, 0)
+	statsGroupOf(s.maps, b.Map).add(b, b.Result, 0, 0)
+	statsGroupOf(s.battleTypes, b.BattleType).add(b, b.Result, 0, 0)
+	day := b.Start.UTC().Format(time.DateOnly)
+	for _, p := range b.Players {
+		result := BattleResultUnknown
+		if p == b.Author {
+			result = b.Result
+		}
+		s.Kills += p.Kills
+		s.Deaths += p.Deaths
+		statsGroupOf(s.players, p.Key()).add(b, result, p.Kills, p.Deaths)
+		days, ok := s.days[p.Key()]
+		if !ok {
+			days = map[string]*StatsGroup{}
+			s.days[p.Key()] = days
+		}
+		statsGroupOf(days, day).add(b, result, p.Kills, p.Deaths)
+		for _, v := range p.Vehicles {
+			kills, deaths := 0, 0
+			for _, life := range p.Lives {
+				if life.Vehicle != v {
+					continue
+				}
+				kills += life.Kills
+				if life.Ended == LifeEndDestroyed {
+					deaths++
+				}
+			}
+			statsGroupOf(s.vehicles, v).add(b, result, kills, deaths)
+		}
+	}
+	for v, n := range b.KillerVehicles {
+		s.killerVehicles[v] += n
+	}
+}
+
+// sortedGroups returns groups ordered by given field descending, then by key
+func sortedGroups(groups map[string]*StatsGroup, by func(*StatsGroup) int) []*StatsGroup {
+	ret := make([]*StatsGroup, 0, len(groups))
+	for _, g := range groups {
+		ret = append(ret, g)
+	}
+	slices.SortFunc(ret, func(a, b *StatsGroup) int {
+		return cmp.Or(cmp.Compare(by(b), by(a)), strings.Compare(a.Key, b.Key))
+	})
+	return ret
+}
+
+func byBattles(g *StatsGroup) int { return g.Battles }
+func byKills(g *StatsGroup) int   { return g.Kills }
+
+// Maps are groups by map, most played first
+func (s *Stats) Maps() []*StatsGroup {
+	return sortedGroups(s.maps, byBattles)
+}
+
+// BattleTypes are groups by battle type, most played first
+func (s *Stats) BattleTypes() []*StatsGroup {
+	return sortedGroups(s.battleTypes, byBattles)
+}
+
+// Vehicles are groups by vehicle, most played first
+func (s *Stats) Vehicles() []*StatsGroup {
+	return sortedGroups(s.vehicles, byBattles)
+}
+
+// Players are groups by player, most kills first
+func (s *Stats) Players() []*StatsGroup {
+	return sortedGroups(s.players, byKills)
+}
+
+// PlayerTimeline is groups of one player keyed by day, oldest first
+func (s *Stats) PlayerTimeline(player string) []*StatsGroup {
+	ret := make([]*StatsGroup, 0, len(s.days[player]))
+	for _, g := range s.days[player] {
+		ret = append(ret, g)
+	}
+	slices.SortFunc(ret, func(a, b *StatsGroup) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+	return ret
+}
+
+// KillerVehicles are vehicles with kill counts, most kills first
+func (s *Stats) KillerVehicles() []*StatsGroup {
+	groups := map[string]*StatsGroup{}
+	for v, n := range s.killerVehicles {
+		groups[v] = &StatsGroup{Key: v, Kills: n}
+	}
+	return sortedGroups(groups, byKills)
+}
+
+// StatsTable is one report of aggregated statistics as text cells
+type StatsTable struct {
+	Name    string
+	Columns []string
+	Rows    [][]string
+}
+
+func (t *StatsTable) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write(t.Columns)
+	cw.WriteAll(t.Rows)
+	return cw.Error()
+}
+
+func formatStatsDuration(d time.Duration) string {
+	return d.Round(time.Second).String()
+}
+
+func groupsTable(name, key string, groups []*StatsGroup) *StatsTable {
+	ret := &StatsTable{
+		Name:    name,
+		Columns: []string{key, "battles", "wins", "losses", "win rate", "kills", "deaths", "k/d", "avg length"},
+	}
+	for _, g := range groups {
+		ret.Rows = append(ret.Rows, []string{
+			g.Key,
+			strconv.Itoa(g.Battles),
+			strconv.Itoa(g.Wins),
+			strconv.Itoa(g.Losses),
+			strconv.FormatFloat(g.WinRate(), 'f', 1, 64),
+			strconv.Itoa(g.Kills),
+			strconv.Itoa(g.Deaths),
+			strconv.FormatFloat(g.KD(), 'f', 2, 64),
+			formatStatsDuration(g.AvgDuration()),
+		})
+	}
+	return ret
+}
+
+// Tables returns every report: summary, maps, battle types, vehicles,
+// players, killer vehicles and k/d of players by day
+func (s *Stats) Tables() []*StatsTable {
+	summary := groupsTable("summary", "scope", []*StatsGroup{&s.StatsGroup})
+	summary.Columns = append(summary.Columns, "failed")
+	summary.Rows[0] = append(summary.Rows[0], strconv.Itoa(s.Failed))
+	killers := &StatsTable{Name: "killer vehicles", Columns: []string{"vehicle", "kills"}}
+	for _, g := range s.KillerVehicles() {
+		killers.Rows = append(killers.Rows, []string{g.Key, strconv.Itoa(g.Kills)})
+	}
+	timeline := &StatsTable{Name: "kd over time", Columns: []string{"player", "day", "battles", "kills", "deaths", "k/d"}}
+	players := s.Players()
+	for _, p := range players {
+		for _, g := range s.PlayerTimeline(p.Key) {
+			timeline.Rows = append(timeline.Rows, []string{
+				p.Key, g.Key, strconv.Itoa(g.Battles), strconv.Itoa(g.Kills), strconv.Itoa(g.Deaths), strconv.FormatFloat(g.KD(), 'f', 2, 64),
+			})
+		}
+	}
+	return []*StatsTable{
+		summary,
+		groupsTable("maps", "map", s.Maps()),
+		groupsTable("battle types", "battle type", s.BattleTypes()),
+		groupsTable("vehicles", "vehicle", s.Vehicles()),
+		groupsTable("players", "player", players),
+		killers,
+		timeline,
+	}
+}
+
+// LibraryBattles walks dir for replay files and groups them into battles:
+// parts of server replay by session, every client replay on its own
+func LibraryBattles(dir string) ([][]string, error) {
+	ret := [][]string{}
+	sessions := map[uint64]int{}
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(d.Name(), ".wrpl") {
+			return err
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		rpl, err := ReadWRPL(f, false, false, false)
+		if err != nil || !rpl.Header.IsServer() {
+			// unreadable files are reported when battle is read
+			ret = append(ret, []string{p})
+			return nil
+		}
+		if i, ok := sessions[rpl.Header.SessionID]; ok {
+			ret[i] = append(ret[i], p)
+		} else {
+			sessions[rpl.Header.SessionID] = len(ret)
+			ret = append(ret, []string{p})
+		}
+		return nil
+	})
+	return ret, err
+}
+
+// ReadBattle reads single replay file or joins server replay parts
+func ReadBattle(paths []string) (*WRPL, error) {
+	parts := make([][]byte, 0, len(paths))
+	for _, p := range paths {
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, b)
+	}
+	switch len(parts) {
+	case 0:
+		return nil, fmt.Errorf("no replay files")
+	case 1:
+		return ReadWRPL(bytes.NewReader(parts[0]), true, true, true)
+	default:
+		return ReadPartedWRPL(parts)
+	}
+}
+
+// BattleReport is outcome of reading one battle of the library
+type BattleReport struct {
+	Paths   []string
+	Summary *BattleSummary
+	Err     error
+}
+
+// AggregateLibrary reads battles (see LibraryBattles) with given amount of
+// workers and reports their summaries, report is never called concurrently.
+// Returns ctx.Err() if aggregation was cancelled.
+func AggregateLibrary(ctx context.Context, battles [][]string, workers int, report func(BattleReport)) error {
+	return runLibraryJobs(ctx, battles, workers, func(paths []string) BattleReport {
+		ret := BattleReport{Paths: paths}
+		rpl, err := ReadBattle(paths)
+		if err != nil {
+			ret.Err = err
+			return ret
+		}
+		ret.Summary = SummarizeBattle(paths[0], rpl)
+		return ret
+	}, report)
+}
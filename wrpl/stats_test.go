@@ -0,0 +1,162 @@
+/*
+	wrpl: War Thunder replay parsing library (golang)
+	Copyright (C) 2025 flexcoral
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU Affero General Public License as published
+	by the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU Affero General Public License for more details.
+
+	You should have received a copy of the GNU Affero General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package wrpl
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// statsSample is two minute battle recorded by Alpha on given map,
+// who got two kills in a tiger
+func statsSample(t *testing.T, level string, status string) *WRPL {
+	b := newSampleBuilder().
+		player(0, 1, 1001, "Alpha", "", "").
+		player(0, 2, 1002, "Bravo", "", "").
+		kill(30, 1, "germ_tiger").
+		kill(60, 1, "germ_tiger").
+		end(120000)
+	copy(b.rpl.Header.Raw_Level[:], level)
+	copy(b.rpl.Header.Raw_BattleType[:], "domination")
+	b.rpl.Header.StartTime = uint32(time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC).Unix())
+	rpl := b.read(t)
+	rpl.Results = map[string]any{"status": status, "authorUserId": int64(1001)}
+	return rpl
+}
+
+func TestSummarizeBattle(t *testing.T) {
+	b := SummarizeBattle("a.wrpl", statsSample(t, "levels/avg_stalingrad.bin", "success"))
+	if b.Map != "avg_stalingrad" || b.BattleType != "domination" || b.Result != BattleResultWin || b.Duration != 2*time.Minute {
+		t.Fatalf("wrong summary: %+v", b)
+	}
+	if b.Author == nil || b.Author.Name != "Alpha" || b.Author.Kills != 2 || b.Author.Vehicles[0] != "germ_tiger" {
+		t.Fatalf("wrong author: %+v", b.Author)
+	}
+	if b.KillerVehicles["germ_tiger"] != 2 {
+		t.Fatalf("killer vehicles: %v", b.KillerVehicles)
+	}
+}
+
+func TestSummarizeBattleDeaths(t *testing.T) {
+	// switching vehicle is only seen from kills, it is not a death
+	rpl := newSampleBuilder().
+		player(0, 1, 1001, "Alpha", "", "").
+		kill(30, 1, "germ_tiger").
+		kill(60000, 1, "ussr_t_34_1941").
+		end(120000).
+		read(t)
+	b := SummarizeBattle("a.wrpl", rpl)
+	if len(b.Players) != 1 || len(b.Players[0].Lives) != 2 {
+		t.Fatalf("players: %+v", b.Players)
+	}
+	if p := b.Players[0]; p.Kills != 2 || p.Deaths != 0 {
+		t.Fatalf("kills %d deaths %d, lives %v %v", p.Kills, p.Deaths, p.Lives[0].Ended, p.Lives[1].Ended)
+	}
+	s := NewStats()
+	s.Add(b)
+	for _, v := range s.Vehicles() {
+		if v.Deaths != 0 {
+			t.Errorf("vehicle %s has %d deaths", v.Key, v.Deaths)
+		}
+	}
+}
+
+func TestStats(t *testing.T) {
+	s := NewStats()
+	s.Add(SummarizeBattle("a.wrpl", statsSample(t, "levels/avg_stalingrad.bin", "success")))
+	s.Add(SummarizeBattle("b.wrpl", statsSample(t, "levels/avg_stalingrad.bin", "fail")))
+	s.Add(SummarizeBattle("c.wrpl", statsSample(t, "levels/avg_berlin.bin", "left")))
+
+	maps := s.Maps()
+	if len(maps) != 2 || maps[0].Key != "avg_stalingrad" || maps[0].Battles != 2 || maps[0].WinRate() != 50 {
+		t.Fatalf("maps: %+v", maps)
+	}
+	if maps[1].Wins+maps[1].Losses != 0 || maps[1].AvgDuration() != 2*time.Minute {
+		t.Fatalf("unknown result counted: %+v", maps[1])
+	}
+	players := s.Players()
+	if players[0].Key != "Alpha" || players[0].Kills != 6 || players[0].KD() != 6 {
+		t.Fatalf("players: %+v", players[0])
+	}
+	if tl := s.PlayerTimeline("Alpha"); len(tl) != 1 || tl[0].Key != "2025-03-01" || tl[0].Battles != 3 {
+		t.Fatalf("timeline: %+v", tl)
+	}
+	vehicles := s.Vehicles()
+	if len(vehicles) != 1 || vehicles[0].Wins != 1 || vehicles[0].Losses != 1 || vehicles[0].Kills != 6 {
+		t.Fatalf("vehicles: %+v", vehicles)
+	}
+
+	buf := &bytes.Buffer{}
+	for _, tab := range s.Tables() {
+		if tab.Name != "maps" {
+			continue
+		}
+		if err := tab.WriteCSV(buf); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := "map,battles,wins,losses,win rate,kills,deaths,k/d,avg length\navg_stalingrad,2,1,1,50.0,0,0,0.00,2m0s\navg_berlin,1,0,0,0.0,0,0,0.00,2m0s\n"
+	if buf.String() != want {
+		t.Fatalf("csv:\n%s", buf)
+	}
+}
+
+func TestAggregateLibrary(t *testing.T) {
+	dir := t.TempDir()
+	// results are written from ResultsBLK, so outcome is not in the file
+	b, err := WriteWRPL(statsSample(t, "levels/avg_stalingrad.bin", "success"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "a.wrpl"), b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "broken.wrpl"), []byte("nope"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	battles, err := LibraryBattles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(battles) != 2 {
+		t.Fatalf("battles: %v", battles)
+	}
+	s := NewStats()
+	err = AggregateLibrary(context.Background(), battles, 2, func(r BattleReport) {
+		if r.Err != nil {
+			s.Failed++
+			return
+		}
+		s.Add(r.Summary)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Battles != 1 || s.Failed != 1 || s.Kills != 2 {
+		t.Fatalf("stats: %+v", s.StatsGroup)
+	}
+	if !strings.HasSuffix(s.Maps()[0].Key, "stalingrad") {
+		t.Fatalf("maps: %+v", s.Maps())
+	}
+}
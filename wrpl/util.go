@@ -19,13 +19,33 @@
 package wrpl
 
 import (
+	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 
+	"github.com/klauspost/compress/zstd"
 	"github.com/maxsupermanhd/wrpl-inspector/danet"
 )
 
+// readAllLimited reads r to the end, reading no more than limit+1 bytes
+// and failing with ErrDecompressionLimit if there are more than limit
+// or zstd decoder refused the frame for being over its max memory.
+// Like io.ReadAll, data read before other errors is returned.
+func readAllLimited(r io.Reader, limit int) ([]byte, error) {
+	b, err := io.ReadAll(io.LimitReader(r, int64(limit)+1))
+	if isZstdLimit(err) || err == nil && len(b) > limit {
+		return nil, fmt.Errorf("%w of %d bytes", ErrDecompressionLimit, limit)
+	}
+	return b, err
+}
+
+// isZstdLimit tells if zstd decoder stopped at WithDecoderMaxMemory
+func isZstdLimit(err error) bool {
+	return errors.Is(err, zstd.ErrDecoderSizeExceeded) || errors.Is(err, zstd.ErrWindowSizeExceeded)
+}
+
 func readVariableLengthSize(r io.Reader) (uint32, error) {
 	var b [1]byte
 
@@ -94,50 +114,45 @@ func readVariableLengthSize(r io.Reader) (uint32, error) {
 }
 
 func writeVariableLengthSize(w io.Writer, size uint32) error {
-	var buf [5]byte
+	_, err := w.Write(appendVariableLengthSize(nil, size))
+	return err
+}
 
+// appendVariableLengthSize appends minimal encoding of the size
+func appendVariableLengthSize(buf []byte, size uint32) []byte {
 	// 1-byte: 10xxxxxx -> values 0..0x3F (6 bits)
 	if size <= 0x3F {
-		buf[0] = 0x80 | byte(size&0x7F) // set high bit, ensure 0x40 is clear
-		_, err := w.Write(buf[:1])
-		return err
+		return append(buf, 0x80|byte(size&0x7F)) // set high bit, ensure 0x40 is clear
 	}
 
 	// 2-byte: 01xxxxxx xxxxxxxx -> values 0x40..0x3FFF (14 bits)
 	if size <= 0x3FFF {
 		v := int64(size) ^ 0x4000
-		buf[0] = byte((v >> 8) & 0xFF)
-		buf[1] = byte(v & 0xFF)
-		_, err := w.Write(buf[:2])
-		return err
+		return append(buf, byte((v>>8)&0xFF), byte(v&0xFF))
 	}
 
 	// 3-byte: 001xxxxx xxxxxxxx xxxxxxxx -> values up to 0x1FFFFF (21 bits)
 	if size <= 0x1FFFFF {
 		v := int64(size) ^ 0x200000
-		buf[0] = byte((v >> 16) & 0xFF)
-		buf[1] = byte((v >> 8) & 0xFF)
-		buf[2] = byte(v & 0xFF)
-		_, err := w.Write(buf[:3])
-		return err
+		return append(buf, byte((v>>16)&0xFF), byte((v>>8)&0xFF), byte(v&0xFF))
 	}
 
 	// 4-byte: 0001xxxx xxxxxxxx xxxxxxxx xxxxxxxx -> values up to 0x0FFFFFFF (28 bits)
 	if size <= 0x0FFFFFFF {
 		v := int64(size) ^ 0x10000000
-		buf[0] = byte((v >> 24) & 0xFF)
-		buf[1] = byte((v >> 16) & 0xFF)
-		buf[2] = byte((v >> 8) & 0xFF)
-		buf[3] = byte(v & 0xFF)
-		_, err := w.Write(buf[:4])
-		return err
+		return append(buf, byte((v>>24)&0xFF), byte((v>>16)&0xFF), byte((v>>8)&0xFF), byte(v&0xFF))
 	}
 
 	// 5-byte: 0000xxxx followed by little-endian u32 -> values > 0x0FFFFFFF up to uint32 max
-	buf[0] = 0x00
-	binary.LittleEndian.PutUint32(buf[1:], size)
-	_, err := w.Write(buf[:5])
-	return err
+	return binary.LittleEndian.AppendUint32(append(buf, 0x00), size)
+}
+
+// prefixEncodesSize checks that recorded (possibly non-minimal) size prefix
+// still holds the size
+func prefixEncodesSize(prefix []byte, size uint32) bool {
+	r := bytes.NewReader(prefix)
+	v, err := readVariableLengthSize(r)
+	return err == nil && r.Len() == 0 && v == size
 }
 
 func readEID(r *danet.BitReader) (uint64, error) {
@@ -27,6 +27,7 @@ import (
 	"fmt"
 	"io"
 	"maps"
+	"math"
 	"os"
 	"path/filepath"
 	"slices"
@@ -50,6 +51,8 @@ type ParsedInfo struct {
 	Chat    []*ParsedPacketChat
 	Players []*Player
 	ECS     *ECS
+	// Roster links players referenced by slot, eid, user id and name
+	Roster *Roster
 }
 
 type WRPL struct {
@@ -62,9 +65,42 @@ type WRPL struct {
 	Results      map[string]any
 	ResultsJSON  string
 	ResultsBLK   []byte
+	// ContinuityIssues lists problems found while joining server replay parts
+	ContinuityIssues []string
+	// Encoding is only recorded by ReadWRPLExact
+	Encoding *ReplayEncoding `json:"-"`
+}
+
+// OpenReplay reads replay file or folder with parts of server replay
+func OpenReplay(p string) (*WRPL, error) {
+	st, err := os.Stat(p)
+	if err != nil {
+		return nil, err
+	}
+	if st.IsDir() {
+		rpl, err := ReadPartedWRPLFolder(p)
+		if err == nil && rpl == nil {
+			err = fmt.Errorf("no replay files in %s", p)
+		}
+		return rpl, err
+	}
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	return ReadWRPL(bytes.NewReader(b), true, true, true)
 }
 
 func ReadPartedWRPLFolder(folderPath string) (ret *WRPL, err error) {
+	parts, err := readPartsFolder(folderPath)
+	if err != nil {
+		return nil, err
+	}
+	return ReadPartedWRPL(parts)
+}
+
+// readPartsFolder reads every .wrpl file of the folder
+func readPartsFolder(folderPath string) ([][]byte, error) {
 	rplsDir, err := os.ReadDir(folderPath)
 	if err != nil {
 		return nil, err
@@ -83,7 +119,7 @@ func ReadPartedWRPLFolder(folderPath string) (ret *WRPL, err error) {
 		}
 		parts = append(parts, part)
 	}
-	return ReadPartedWRPL(parts)
+	return parts, nil
 }
 
 func ReadPartedWRPL(replayBytes [][]byte) (ret *WRPL, err error) {
@@ -131,15 +167,54 @@ func ReadPartedWRPL(replayBytes [][]byte) (ret *WRPL, err error) {
 		Header:       parts[0].Header,
 		Settings:     parts[0].Settings,
 		SettingsJSON: parts[0].SettingsJSON,
+		SettingsBLK:  parts[0].SettingsBLK,
 		Packets:      []*WRPLRawPacket{},
 	}
-	for _, k := range keys {
+	ret.Header.ResultsBlkOffset = 0
+	for i, k := range keys {
+		if i > 0 {
+			ret.ContinuityIssues = append(ret.ContinuityIssues, checkPartsContinuity(keys[i-1], parts[keys[i-1]], k, parts[k])...)
+		}
 		ret.Packets = append(ret.Packets, parts[k].Packets...)
+		if len(parts[k].ResultsBLK) > 0 {
+			ret.Results = parts[k].Results
+			ret.ResultsJSON = parts[k].ResultsJSON
+			ret.ResultsBLK = parts[k].ResultsBLK
+		}
 	}
 	ParsePacketStream(ret)
 	return
 }
 
+// checkPartsContinuity verifies that previous part was closed with NextSegment
+// marker and that time does not go backwards across the parts. The marker is
+// looked for among all packets of the last tick of the previous part since
+// server may flush any number of packets alongside it.
+func checkPartsContinuity(prevNum int, prev *WRPL, nextNum int, next *WRPL) (issues []string) {
+	if len(prev.Packets) == 0 {
+		return []string{fmt.Sprintf("part %d has no packets", prevNum)}
+	}
+	if len(next.Packets) == 0 {
+		return []string{fmt.Sprintf("part %d has no packets", nextNum)}
+	}
+	hasMarker := false
+	lastTime := prev.Packets[len(prev.Packets)-1].CurrentTime
+	for i := len(prev.Packets) - 1; i >= 0 && prev.Packets[i].CurrentTime >= lastTime; i-- {
+		if PacketType(prev.Packets[i].PacketType) == PacketTypeNextSegment {
+			hasMarker = true
+			break
+		}
+	}
+	if !hasMarker {
+		issues = append(issues, fmt.Sprintf("part %d does not end with next segment marker before part %d", prevNum, nextNum))
+	}
+	nextTime := next.Packets[0].CurrentTime
+	if nextTime < lastTime {
+		issues = append(issues, fmt.Sprintf("part %d starts at %d but part %d ends at %d", nextNum, nextTime, prevNum, lastTime))
+	}
+	return
+}
+
 func ReadWRPL(r io.ReadSeeker, parseSettings, parsePackets, parseResults bool) (ret *WRPL, err error) {
 	ret = &WRPL{}
 	err = binary.Read(r, binary.LittleEndian, &ret.Header)
@@ -203,34 +278,44 @@ func ReadWRPL(r io.ReadSeeker, parseSettings, parsePackets, parseResults bool) (
 	return
 }
 
+// WriteWRPL encodes replay, SettingsBLKSize and ResultsBlkOffset of the
+// header are set to match written blobs
 func WriteWRPL(rpl *WRPL) ([]byte, error) {
+	if rpl.Header.SettingsBLKSize > 0 && rpl.SettingsBLK == nil {
+		return nil, errors.New("settings size present but blob not provided, can't write blk on my own")
+	}
+	if len(rpl.SettingsBLK) > math.MaxUint16 {
+		return nil, fmt.Errorf("settings blk of size %d does not fit in the header", len(rpl.SettingsBLK))
+	}
+	rpl.Header.SettingsBLKSize = uint16(len(rpl.SettingsBLK))
 	buf := &bytes.Buffer{}
 	err := binary.Write(buf, binary.LittleEndian, rpl.Header)
 	if err != nil {
 		return nil, err
 	}
-	if rpl.Header.SettingsBLKSize > 0 {
-		if rpl.SettingsBLK == nil {
-			return nil, errors.New("settings size present but blob not provided, can't write blk on my own")
+	_, err = buf.Write(rpl.SettingsBLK)
+	if err != nil {
+		return nil, err
+	}
+	if rpl.Encoding != nil {
+		err = writePacketsExact(buf, rpl)
+		if err != nil {
+			return nil, err
 		}
-		n, err := buf.Write(rpl.SettingsBLK)
+	} else {
+		pkw, err := zlib.NewWriterLevel(buf, 3)
 		if err != nil {
 			return nil, err
 		}
-		if n != int(rpl.Header.SettingsBLKSize) {
-			return nil, fmt.Errorf("missmatch of blk size, header %d provided blob %d", rpl.Header.SettingsBLKSize, n)
+		err = WritePackets(pkw, rpl.Packets)
+		if err != nil {
+			return nil, err
 		}
+		pkw.Close()
 	}
-	pkw, err := zlib.NewWriterLevel(buf, 3)
-	if err != nil {
-		return nil, err
-	}
-	err = WritePackets(pkw, rpl.Packets)
-	if err != nil {
-		return nil, err
+	if rpl.Header.ResultsBlkOffset != 0 || len(rpl.ResultsBLK) > 0 {
+		rpl.Header.ResultsBlkOffset = int32(buf.Len())
 	}
-	pkw.Close()
-	rpl.Header.ResultsBlkOffset = int32(buf.Len())
 	buf2 := &bytes.Buffer{}
 	err = binary.Write(buf2, binary.LittleEndian, rpl.Header)
 	if err != nil {
@@ -245,5 +330,5 @@ func WriteWRPL(rpl *WRPL) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	return ret, nil
+	return buf.Bytes(), nil
 }